@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kelein/trove-fiber/pkg/config"
+)
+
+// configStatus is the result of config validate
+type configStatus struct {
+	Valid   bool     `json:"valid"`
+	Profile string   `json:"profile"`
+	Files   []string `json:"files"`
+}
+
+func configCmd(x *cli) *cobra.Command {
+	return group(&cobra.Command{Use: "config", Short: "Check and show the effective config"},
+		&cobra.Command{
+			Use:   "validate",
+			Short: "Validate the config, failing with every problem found",
+			Args:  exactArgs(0),
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				if _, err := x.config(); err != nil {
+					return err
+				}
+				status := configStatus{Valid: true, Profile: x.src.Profile(), Files: x.src.Files()}
+				tb := newTable("valid", "profile", "files")
+				for _, file := range status.Files {
+					tb.add(status.Valid, status.Profile, file)
+				}
+				return x.print(cmd.OutOrStdout(), status, tb)
+			},
+		},
+		&cobra.Command{
+			Use:   "print",
+			Short: "Show the effective config with secrets masked and where each value came from",
+			Args:  exactArgs(0),
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				c, err := x.config()
+				if err != nil {
+					return err
+				}
+				redacted := c.Redacted()
+				settings := config.Flatten(redacted)
+				keys := make([]string, 0, len(settings))
+				for key := range settings {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+
+				tb := newTable("key", "origin", "value")
+				for _, key := range keys {
+					origin := x.src.Origin(key)
+					if origin == "" {
+						origin = "default"
+					}
+					tb.add(key, origin, settings[key])
+				}
+				return x.print(cmd.OutOrStdout(), redacted, tb)
+			},
+		},
+	)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kelein/trove-fiber/pkg/migrate"
+)
+
+func migrateCmd(x *cli) *cobra.Command {
+	return group(&cobra.Command{Use: "migrate", Short: "Migrate the database schema with the versioned migrations"},
+		migrateUpCmd(x),
+		migrateDownCmd(x),
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show the state of every migration",
+			Args:  exactArgs(0),
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return x.migrator(func(m *migrate.Migrator) error {
+					status, err := m.Status(cmd.Context())
+					if err != nil {
+						return err
+					}
+					tb := newTable("version", "name", "state", "applied_at")
+					for _, s := range status {
+						var applied any = "-"
+						if s.AppliedAt != nil {
+							applied = *s.AppliedAt
+						}
+						tb.add(s.Version, s.Name, s.State, applied)
+					}
+					return x.print(cmd.OutOrStdout(), status, tb)
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "drift",
+			Short: "Compare the live schema with the models, fails on any drift",
+			Args:  exactArgs(0),
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return x.migrator(func(m *migrate.Migrator) error {
+					drift, err := m.Drift(cmd.Context())
+					if err != nil {
+						return err
+					}
+					tb := newTable("table", "kind", "name", "detail")
+					for _, d := range drift {
+						tb.add(d.Table, d.Kind, d.Name, d.Detail)
+					}
+					if err = x.print(cmd.OutOrStdout(), drift, tb); err != nil {
+						return err
+					}
+					if len(drift) > 0 {
+						return withCode(exitConflict, fmt.Errorf("schema drifted from the models, %d differences", len(drift)))
+					}
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "unlock",
+			Short: "Release the migration lock left by a crashed migration",
+			Args:  exactArgs(0),
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return x.migrator(func(m *migrate.Migrator) error { return m.Unlock(cmd.Context()) })
+			},
+		},
+	)
+}
+
+func migrateUpCmd(x *cli) *cobra.Command {
+	var to int64
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply the pending migrations",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return x.migrator(func(m *migrate.Migrator) error {
+				return x.runSteps(cmd, dryRun,
+					func(ctx context.Context) ([]migrate.Step, error) { return m.PlanUp(ctx, to) },
+					func(ctx context.Context) ([]migrate.Step, error) { return m.Up(ctx, to) })
+			})
+		},
+	}
+	cmd.Flags().Int64Var(&to, "to", 0, "target version, the latest when zero")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the SQL without applying it")
+	return cmd
+}
+
+func migrateDownCmd(x *cli) *cobra.Command {
+	var steps int
+	var dryRun, yes bool
+	cmd := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the last applied migrations",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if steps < 1 {
+				return usageErrorf("--steps must be positive")
+			}
+			if !yes && !dryRun {
+				return usageErrorf("migrate down may drop tables and their data, confirm with --yes")
+			}
+			return x.migrator(func(m *migrate.Migrator) error {
+				return x.runSteps(cmd, dryRun,
+					func(ctx context.Context) ([]migrate.Step, error) { return m.PlanDown(ctx, steps) },
+					func(ctx context.Context) ([]migrate.Step, error) { return m.Down(ctx, steps) })
+			})
+		},
+	}
+	cmd.Flags().IntVar(&steps, "steps", 1, "number of migrations to roll back")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the SQL without running it")
+	cmd.Flags().BoolVar(&yes, "yes", false, "confirm the rollback")
+	return cmd
+}
+
+// migrator runs fn with the migrator of the user database
+func (x *cli) migrator(fn func(m *migrate.Migrator) error) error {
+	t, cleanup, err := x.wire()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return fn(t.Migrator)
+}
+
+// runSteps prints the planned SQL on a dry run, otherwise it runs the
+// steps and prints them.
+func (x *cli) runSteps(cmd *cobra.Command, dryRun bool, plan, run func(context.Context) ([]migrate.Step, error)) error {
+	if dryRun {
+		steps, err := plan(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if x.output == outputJSON {
+			return printJSON(cmd.OutOrStdout(), steps)
+		}
+		return writeSQL(cmd.OutOrStdout(), steps)
+	}
+
+	steps, err := run(cmd.Context())
+	tb := newTable("version", "name", "direction")
+	for _, s := range steps {
+		tb.add(s.Version, s.Name, s.Direction)
+	}
+	if perr := x.print(cmd.OutOrStdout(), steps, tb); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// writeSQL writes the scripts of the steps, each headed by a comment
+func writeSQL(w io.Writer, steps []migrate.Step) error {
+	if len(steps) == 0 {
+		_, err := fmt.Fprintln(w, "-- nothing to migrate")
+		return err
+	}
+	for _, s := range steps {
+		if _, err := fmt.Fprintf(w, "-- %d_%s %s\n%s\n\n", s.Version, s.Name, s.Direction, strings.TrimSpace(s.SQL)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/kelein/trove-fiber/docs"
+)
+
+func openapiCmd() *cobra.Command {
+	var file, format string
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Export the API spec as JSON or YAML",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			spec := []byte(docs.SwaggerInfo.ReadDoc())
+			switch format {
+			case "json":
+			case "yaml":
+				// * JSON is YAML, the node keeps the key order of the spec.
+				var node yaml.Node
+				if err := yaml.Unmarshal(spec, &node); err != nil {
+					return err
+				}
+				blockStyle(&node)
+				out, err := yaml.Marshal(&node)
+				if err != nil {
+					return err
+				}
+				spec = out
+			default:
+				return usageErrorf("unknown spec format %q, want json or yaml", format)
+			}
+
+			if file == "" || file == "-" {
+				_, err := cmd.OutOrStdout().Write(spec)
+				return err
+			}
+			return os.WriteFile(file, spec, 0o644)
+		},
+	}
+	export.Flags().StringVarP(&file, "file", "f", "", "file to write the spec to, stdout when empty")
+	export.Flags().StringVar(&format, "format", "json", "spec format, json or yaml")
+
+	return group(&cobra.Command{Use: "openapi", Short: "Work with the API spec"}, export)
+}
+
+// blockStyle drops the flow style the nodes got from JSON
+func blockStyle(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
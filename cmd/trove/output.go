@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output Formats
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+)
+
+// table is the tabular form of a command result
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func newTable(header ...string) *table {
+	return &table{header: header}
+}
+
+// add appends a row, times are formatted as RFC 3339
+func (t *table) add(cells ...any) {
+	row := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case time.Time:
+			row[i] = v.Format(time.RFC3339)
+		default:
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	t.rows = append(t.rows, row)
+}
+
+func (t *table) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(t.header) > 0 {
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(t.header, "\t")))
+	}
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (x *cli) checkOutput() error {
+	if x.output != outputJSON && x.output != outputTable {
+		return usageErrorf("unknown output format %q, want %s or %s", x.output, outputJSON, outputTable)
+	}
+	return nil
+}
+
+// print writes the result as indented JSON or else as the table
+func (x *cli) print(w io.Writer, v any, t *table) error {
+	if x.output == outputJSON {
+		return printJSON(w, v)
+	}
+	return t.write(w)
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/kelein/trove-fiber/internal/server"
+)
+
+// routeInfo is a route of the listener it is served on
+type routeInfo struct {
+	Server string `json:"server"`
+	server.Route
+}
+
+func routesCmd(x *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "routes",
+		Short: "Show the route tables of the API and admin servers",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			t, cleanup, err := x.wire()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			var list []routeInfo
+			for _, r := range server.Routes(t.HTTP.App) {
+				list = append(list, routeInfo{Server: "api", Route: r})
+			}
+			for _, r := range server.Routes(t.Admin.App) {
+				list = append(list, routeInfo{Server: "admin", Route: r})
+			}
+			tb := newTable("server", "method", "path", "name")
+			for _, r := range list {
+				tb.add(r.Server, r.Method, r.Path, r.Name)
+			}
+			return x.print(cmd.OutOrStdout(), list, tb)
+		},
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/pkg/vault"
+)
+
+func secretsCmd(x *cli) *cobra.Command {
+	return group(&cobra.Command{Use: "secrets", Short: "Manage the secrets of the local vault"},
+		&cobra.Command{
+			Use:   "get <key>",
+			Short: "Print a secret",
+			Args:  exactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				vlt, err := x.vault()
+				if err != nil {
+					return err
+				}
+				secret, ok := vlt.Get(args[0])
+				if !ok {
+					return withCode(exitNotFound, fmt.Errorf("no secret %q in vault %s", args[0], vlt.Path()))
+				}
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), secret)
+				return err
+			},
+		},
+		&cobra.Command{
+			Use:   "set <key> [value]",
+			Short: "Set a secret, the value is read from stdin when omitted",
+			Args:  rangeArgs(1, 2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				vlt, err := x.vault()
+				if err != nil {
+					return err
+				}
+				secret, err := readValue(cmd.InOrStdin(), args[1:])
+				if err != nil {
+					return err
+				}
+				vlt.Set(args[0], secret)
+				return vlt.Save()
+			},
+		},
+		&cobra.Command{
+			Use:   "edit",
+			Short: "Edit all secrets with $EDITOR",
+			Args:  exactArgs(0),
+			RunE: func(*cobra.Command, []string) error {
+				vlt, err := x.vault()
+				if err != nil {
+					return err
+				}
+				return editSecrets(vlt)
+			},
+		},
+	)
+}
+
+// vault opens the vault of the config, the secrets commands work
+// before the placeholders of the config can be resolved.
+func (x *cli) vault() (*vault.Vault, error) {
+	src, err := x.source()
+	if err != nil {
+		return nil, err
+	}
+	vc, err := conf.LoadVault(src.Viper)
+	if err != nil {
+		return nil, withCode(exitConfig, err)
+	}
+	return vc.Open()
+}
+
+// readValue returns the value argument, or else the first line of
+// the input, so that secrets stay out of the shell history.
+func readValue(r io.Reader, args []string) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// editSecrets opens the decrypted secrets as YAML in $EDITOR, the
+// plaintext only lives in a private temp file removed afterwards.
+func editSecrets(vlt *vault.Vault) error {
+	data, err := yaml.Marshal(vlt.All())
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp("", "trove-secrets-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command(editor, tmp.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", editor, err)
+	}
+
+	if data, err = os.ReadFile(tmp.Name()); err != nil {
+		return err
+	}
+	secrets := map[string]string{}
+	if err = yaml.Unmarshal(data, &secrets); err != nil {
+		return fmt.Errorf("edited secrets are not a YAML map of strings: %w", err)
+	}
+	vlt.Replace(secrets)
+	return vlt.Save()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	stdout "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+func serveCmd(x *cli) *cobra.Command {
+	var migrate bool
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the API and admin servers",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := x.config()
+			if err != nil {
+				return err
+			}
+			closeLog := log.SetupSlog(c.Log)
+			defer closeLog()
+			slog.Info("config loaded", "profile", x.src.Profile(), "files", x.src.Files())
+
+			provider, err := initTracerProvider()
+			if err != nil {
+				return err
+			}
+			defer provider.Shutdown(context.Background())
+
+			t, cleanup, err := x.wire()
+			if err != nil {
+				slog.Error("wire injection failed", "error", err)
+				return err
+			}
+			defer cleanup()
+
+			if migrate || c.Data.DB[conf.DBUser].Migrate {
+				steps, err := t.Migrator.Up(cmd.Context(), 0)
+				for _, step := range steps {
+					slog.Info("migration applied", "version", step.Version, "name", step.Name)
+				}
+				if err != nil {
+					slog.Error("migration failed", "error", err)
+					return err
+				}
+			}
+
+			addr := fmt.Sprintf("http://%s:%d", c.HTTP.Host, c.HTTP.Port)
+			admin := fmt.Sprintf("http://%s:%d", c.Admin.Host, c.Admin.Port)
+			slog.Info("server start listen on", "addr", addr)
+			slog.Info("admin server listen on", "addr", admin)
+			slog.Info("swagger docs", "addr", fmt.Sprintf("%s/swagger/index.html", admin))
+			if err = t.App.Run(cmd.Context()); err != nil {
+				slog.Error("server run failed", "error", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&migrate, "migrate", false, "apply the pending migrations before serving")
+	return cmd
+}
+
+func initTracerProvider() (*sdktrace.TracerProvider, error) {
+	exporter, err := stdout.New(stdout.WithPrettyPrint())
+	if err != nil {
+		return nil, fmt.Errorf("initialize otel exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(
+			resource.NewWithAttributes(
+				semconv.SchemaURL,
+				semconv.ServiceNameKey.String(version.AppName),
+				semconv.ServiceVersionKey.String(version.AppVersion),
+			),
+		),
+	)
+	otel.SetTracerProvider(provider)
+	prop := propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{})
+	otel.SetTextMapPropagator(prop)
+	return provider, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kelein/trove-fiber/internal/inject"
+)
+
+// tokenInfo is the result of the token commands
+type tokenInfo struct {
+	Token     string    `json:"token,omitempty"`
+	UserID    string    `json:"user_id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (ti tokenInfo) table() *table {
+	tb := newTable("user_id", "issued_at", "expires_at")
+	tb.add(ti.UserID, ti.IssuedAt, ti.ExpiresAt)
+	if ti.Token != "" {
+		tb.header = append(tb.header, "token")
+		tb.rows[0] = append(tb.rows[0], ti.Token)
+	}
+	return tb
+}
+
+func tokenCmd(x *cli) *cobra.Command {
+	var ttl time.Duration
+	issue := &cobra.Command{
+		Use:   "issue <user-id>",
+		Short: "Issue an access token of an active user",
+		Args:  exactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t, cleanup, err := x.wire()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			token, err := t.Users.IssueToken(cmd.Context(), args[0], ttl)
+			if err != nil {
+				return err
+			}
+			return x.inspect(cmd, t, token, true)
+		},
+	}
+	issue.Flags().DurationVar(&ttl, "ttl", 0, "lifetime of the token, the login token lifetime when zero")
+
+	return group(&cobra.Command{Use: "token", Short: "Issue and inspect access tokens"},
+		issue,
+		&cobra.Command{
+			Use:   "inspect <token>",
+			Short: "Verify a token and show its claims",
+			Args:  exactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t, cleanup, err := x.wire()
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+				return x.inspect(cmd, t, args[0], false)
+			},
+		},
+	)
+}
+
+// inspect verifies the token with the configured key and prints its claims
+func (x *cli) inspect(cmd *cobra.Command, t *inject.Trove, token string, show bool) error {
+	claims, err := t.JWT.ParseToken(token)
+	if err != nil {
+		return err
+	}
+
+	info := tokenInfo{UserID: claims.UserId}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if show {
+		info.Token = token
+	}
+	return x.print(cmd.OutOrStdout(), info, info.table())
+}
@@ -1,87 +1,197 @@
 package main
 
 import (
-	"context"
-	"flag"
+	"cmp"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 
-	"go.opentelemetry.io/otel"
-	stdout "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"github.com/spf13/cobra"
 
 	"github.com/kelein/trove-fiber/docs"
+	"github.com/kelein/trove-fiber/internal/conf"
 	"github.com/kelein/trove-fiber/internal/inject"
+	"github.com/kelein/trove-fiber/internal/service"
 	"github.com/kelein/trove-fiber/pkg/config"
 	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/migrate"
 	"github.com/kelein/trove-fiber/pkg/version"
 )
 
-var (
-	v   = flag.Bool("v", false, "show the binary version")
-	ver = flag.Bool("version", false, "show the binary version")
-	cfg = flag.String("conf", "config/dev.yaml", "config file path")
+// Exit Codes
+const (
+	exitOK = iota
+	exitError
+	exitConfig
+	exitUsage
+	exitNotFound
+	exitConflict
 )
 
 func init() {
-	initTracerProvider()
 	docs.InitSwaggerInfo()
 }
 
-func initTracerProvider() *sdktrace.TracerProvider {
-	exporter, err := stdout.New(stdout.WithPrettyPrint())
-	if err != nil {
-		slog.Error("initialize otel exporter failed", "error", err)
-		os.Exit(1)
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitCode(err))
 	}
-	provider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String(version.AppName),
-				semconv.ServiceVersionKey.String(version.AppVersion),
-			),
-		),
+}
+
+// cli holds the global flags, the config and the object graph are
+// loaded by the commands needing them.
+type cli struct {
+	conf    string
+	profile string
+	output  string
+
+	src *config.Source
+	c   *conf.Config
+}
+
+func newRootCmd() *cobra.Command {
+	x := &cli{}
+	serve := serveCmd(x)
+	root := group(&cobra.Command{
+		Use:           "trove",
+		Short:         "Trove API server and admin commands",
+		Version:       cmp.Or(version.AppVersion, "dev"),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			// * Admin commands only log warnings, serve sets up the logs.
+			slog.SetLogLoggerLevel(slog.LevelWarn)
+			if err := log.SetLevel("warn"); err != nil {
+				return err
+			}
+			return x.checkOutput()
+		},
+	},
+		serve,
+		migrateCmd(x),
+		userCmd(x),
+		tokenCmd(x),
+		configCmd(x),
+		routesCmd(x),
+		openapiCmd(),
+		versionCmd(x),
+		secretsCmd(x),
 	)
-	otel.SetTracerProvider(provider)
-	prop := propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{}, propagation.Baggage{})
-	otel.SetTextMapPropagator(prop)
-	return provider
+	// * Without a command trove serves, as it did before the subcommands.
+	root.RunE = serve.RunE
+	root.Flags().AddFlagSet(serve.Flags())
+	root.SetVersionTemplate(version.String() + "\n")
+	root.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return withCode(exitUsage, err)
+	})
+
+	flags := root.PersistentFlags()
+	flags.StringVarP(&x.conf, "conf", "c", "config", "config directory or base config file path")
+	flags.StringVarP(&x.profile, "profile", "p", "", "config profile overlay, APP_ENV or the env key when empty")
+	flags.StringVarP(&x.output, "output", "o", outputTable, "output format, json or table")
+	return root
 }
 
-func main() {
-	flag.Parse()
-	showVersion()
+// source loads the layered config files
+func (x *cli) source() (*config.Source, error) {
+	if x.src == nil {
+		src, err := config.NewConfig(x.conf, x.profile)
+		if err != nil {
+			return nil, withCode(exitConfig, err)
+		}
+		x.src = src
+	}
+	return x.src, nil
+}
 
-	conf := config.NewConfig(*cfg)
-	log.SetupSlog(conf)
+// config loads and validates the typed config
+func (x *cli) config() (*conf.Config, error) {
+	if x.c != nil {
+		return x.c, nil
+	}
+	src, err := x.source()
+	if err != nil {
+		return nil, err
+	}
+	if x.c, err = conf.Load(src.Viper); err != nil {
+		return nil, withCode(exitConfig, err)
+	}
+	return x.c, nil
+}
 
-	app, cleanup, err := inject.NewWire(conf)
-	defer cleanup()
+// wire builds the object graph shared with the server
+func (x *cli) wire() (*inject.Trove, func(), error) {
+	c, err := x.config()
 	if err != nil {
-		slog.Error("wire injection failed", "error", err)
-		os.Exit(1)
+		return nil, nil, err
+	}
+	return inject.NewWire(x.src, c)
+}
+
+// group makes a parent command print its help, it fails with a usage
+// error on unknown subcommands.
+func group(cmd *cobra.Command, subs ...*cobra.Command) *cobra.Command {
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return usageErrorf("unknown command %q for %q", args[0], cmd.CommandPath())
+		}
+		return nil
 	}
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error { return cmd.Help() }
+	cmd.AddCommand(subs...)
+	return cmd
+}
+
+// exactArgs requires n positional arguments
+func exactArgs(n int) cobra.PositionalArgs {
+	return rangeArgs(n, n)
+}
 
-	addr := fmt.Sprintf("http://%s:%d", conf.GetString("http.host"), conf.GetInt("http.port"))
-	slog.Info("server start listen on", "addr", addr)
-	slog.Info("swagger docs", "addr", fmt.Sprintf("%s/swagger/index.html", addr))
-	if err = app.Run(context.Background()); err != nil {
-		slog.Error("server run failed", "error", err)
-		os.Exit(1)
+// rangeArgs requires between least and most positional arguments
+func rangeArgs(least, most int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := cobra.RangeArgs(least, most)(cmd, args); err != nil {
+			return withCode(exitUsage, fmt.Errorf("%w, usage: %s", err, cmd.UseLine()))
+		}
+		return nil
 	}
 }
 
-func showVersion() {
-	if *v || *ver {
-		fmt.Println(version.String())
-		os.Exit(0)
+// codeError is a command failure with its exit code
+type codeError struct {
+	code int
+	err  error
+}
+
+func (e *codeError) Error() string { return e.err.Error() }
+func (e *codeError) Unwrap() error { return e.err }
+
+func withCode(code int, err error) error {
+	return &codeError{code: code, err: err}
+}
+
+func usageErrorf(format string, args ...any) error {
+	return withCode(exitUsage, fmt.Errorf(format, args...))
+}
+
+// exitCode maps the command errors to the exit codes
+func exitCode(err error) int {
+	var ce *codeError
+	switch {
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, service.ErrUserNotFound):
+		return exitNotFound
+	case errors.Is(err, service.ErrUserYetExist),
+		errors.Is(err, service.ErrUserConflict),
+		errors.Is(err, migrate.ErrModified),
+		errors.Is(err, migrate.ErrMissing),
+		errors.Is(err, migrate.ErrLocked):
+		return exitConflict
+	case errors.Is(err, service.ErrInvalidRole):
+		return exitUsage
 	}
+	return exitError
 }
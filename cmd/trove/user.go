@@ -0,0 +1,121 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	v1 "github.com/kelein/trove-fiber/internal/api/v1"
+	"github.com/kelein/trove-fiber/internal/model"
+)
+
+func userCmd(x *cli) *cobra.Command {
+	return group(&cobra.Command{Use: "user", Short: "Manage the users"},
+		userCreateCmd(x),
+		userListCmd(x),
+		&cobra.Command{
+			Use:   "disable <user-id>",
+			Short: "Disable a user, the user can no longer log in",
+			Args:  exactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t, cleanup, err := x.wire()
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+				user, err := t.Users.DisableUser(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				return x.print(cmd.OutOrStdout(), user, userTable(*user))
+			},
+		},
+		&cobra.Command{
+			Use:   "set-role <user-id> <role>",
+			Short: "Set the role of a user, user or admin",
+			Args:  exactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t, cleanup, err := x.wire()
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+				user, err := t.Users.SetRole(cmd.Context(), args[0], args[1])
+				if err != nil {
+					return err
+				}
+				return x.print(cmd.OutOrStdout(), user, userTable(*user))
+			},
+		},
+	)
+}
+
+func userCreateCmd(x *cli) *cobra.Command {
+	var req v1.CreateUserRequest
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an active user, the password is read from stdin unless given",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if req.Email == "" {
+				return usageErrorf("--email is required")
+			}
+			if req.Password == "" {
+				password, err := readValue(cmd.InOrStdin(), nil)
+				if err != nil {
+					return err
+				}
+				if req.Password = password; password == "" {
+					return usageErrorf("password is empty")
+				}
+			}
+
+			t, cleanup, err := x.wire()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			user, err := t.Users.CreateUser(cmd.Context(), &req)
+			if err != nil {
+				return err
+			}
+			return x.print(cmd.OutOrStdout(), user, userTable(*user))
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&req.Email, "email", "", "email of the user")
+	flags.StringVar(&req.Nickname, "nickname", "", "nickname of the user")
+	flags.StringVar(&req.Role, "role", model.RoleUser, "role of the user, user or admin")
+	flags.StringVar(&req.Password, "password", "", "password of the user, prefer stdin to keep it out of the shell history")
+	return cmd
+}
+
+func userListCmd(x *cli) *cobra.Command {
+	var req v1.ListUsersRequest
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the users",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			t, cleanup, err := x.wire()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			data, err := t.Users.ListUsers(cmd.Context(), &req)
+			if err != nil {
+				return err
+			}
+			return x.print(cmd.OutOrStdout(), data, userTable(data.Users...))
+		},
+	}
+	cmd.Flags().IntVar(&req.Offset, "offset", 0, "users to skip")
+	cmd.Flags().IntVar(&req.Limit, "limit", 50, "users to list at most")
+	return cmd
+}
+
+func userTable(users ...v1.UserInfo) *table {
+	tb := newTable("user_id", "email", "nickname", "role", "status", "created_at")
+	for _, u := range users {
+		tb.add(u.UserId, u.Email, u.Nickname, u.Role, u.Status, u.CreatedAt)
+	}
+	return tb
+}
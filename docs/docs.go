@@ -92,11 +92,47 @@ const docTemplate = `{
                     "用户模块"
                 ],
                 "summary": "修改用户信息",
-                "responses": {}
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag of the profile read",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "409": {
+                        "description": "changed concurrently",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ServerResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "changed since the If-Match version",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ServerResponse"
+                        }
+                    }
+                }
             }
         }
     },
     "definitions": {
+        "handler.ServerResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "data": {},
+                "message": {
+                    "type": "string"
+                },
+                "reqid": {
+                    "type": "string"
+                }
+            }
+        },
         "v1.RegisterRequest": {
             "type": "object",
             "required": [
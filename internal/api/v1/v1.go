@@ -1,5 +1,7 @@
 package v1
 
+import "time"
+
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email" example:"1234@gmail.com"`
 	Password string `json:"password" binding:"required" example:"123456"`
@@ -27,9 +29,36 @@ type UpdateProfileRequest struct {
 type GetProfileResponseData struct {
 	UserId   string `json:"userId"`
 	Nickname string `json:"nickname" example:"alan"`
+	Version  int64  `json:"version" example:"1"`
 }
 
 type GetProfileResponse struct {
 	// Response
 	Data GetProfileResponseData
 }
+
+type CreateUserRequest struct {
+	Email    string `json:"email" binding:"required,email" example:"1234@gmail.com"`
+	Password string `json:"password" binding:"required" example:"123456"`
+	Nickname string `json:"nickname" example:"alan"`
+	Role     string `json:"role" example:"user"`
+}
+
+type ListUsersRequest struct {
+	Offset int `json:"offset" example:"0"`
+	Limit  int `json:"limit" example:"20"`
+}
+
+type UserInfo struct {
+	UserId    string    `json:"userId"`
+	Email     string    `json:"email" example:"1234@gmail.com"`
+	Nickname  string    `json:"nickname" example:"alan"`
+	Role      string    `json:"role" example:"user"`
+	Status    string    `json:"status" example:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ListUsersResponseData struct {
+	Total int64      `json:"total"`
+	Users []UserInfo `json:"users"`
+}
@@ -0,0 +1,440 @@
+package conf
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/kelein/trove-fiber/pkg/config"
+	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
+)
+
+// Operational endpoints the public router may expose, they are
+// served on the admin listener and hidden from the public one by default.
+const (
+	ExposePprof   = "pprof"
+	ExposeHealth  = "health"
+	ExposeMetrics = "metrics"
+	ExposeSwagger = "swagger"
+)
+
+// Database Drivers
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// SQLite Journal Modes
+const (
+	JournalDelete = "delete"
+	JournalWAL    = "wal"
+)
+
+// Replica Policies
+const (
+	PolicyRoundRobin = "round_robin"
+	PolicyLeastConn  = "least_conn"
+	PolicyRandom     = "random"
+)
+
+// Cache Drivers
+const (
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+	CacheTiered = "tiered"
+)
+
+// Lock Drivers
+const (
+	LockDB    = "db"
+	LockRedis = "redis"
+)
+
+// DBUser is the name of the user database connection
+const DBUser = "user"
+
+// Config is the typed configuration of the application
+type Config struct {
+	Env       string     `mapstructure:"env"`
+	HTTP      HTTP       `mapstructure:"http"`
+	Admin     Admin      `mapstructure:"admin"`
+	Security  Security   `mapstructure:"security"`
+	Data      Data       `mapstructure:"data"`
+	Outbox    Outbox     `mapstructure:"outbox"`
+	Jobs      Jobs       `mapstructure:"jobs"`
+	Scheduler Scheduler  `mapstructure:"scheduler"`
+	Metrics   Metrics    `mapstructure:"metrics"`
+	SLO       SLO        `mapstructure:"slo"`
+	Health    Health     `mapstructure:"health"`
+	Log       log.Config `mapstructure:"log"`
+	Secrets   Secrets    `mapstructure:"secrets"`
+
+	// secrets are the resolved values of the secret placeholders
+	secrets []string
+}
+
+// HTTP is the public listener
+type HTTP struct {
+	Host      string    `mapstructure:"host"`
+	Port      int       `mapstructure:"port"`
+	Expose    []string  `mapstructure:"expose"`
+	CORS      CORS      `mapstructure:"cors"`
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+}
+
+// CORS is the cross-origin policy of the public listener
+type CORS struct {
+	AllowOrigins     []string `mapstructure:"allow_origins"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	MaxAge           int      `mapstructure:"max_age"`
+}
+
+// RateLimit limits the requests per client IP, within each expiration
+// window a client gets at most max requests.
+type RateLimit struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Max        int           `mapstructure:"max"`
+	Expiration time.Duration `mapstructure:"expiration"`
+}
+
+// Admin is the listener of the operational endpoints
+type Admin struct {
+	Host      string    `mapstructure:"host"`
+	Port      int       `mapstructure:"port"`
+	AllowIPs  []string  `mapstructure:"allow_ips"`
+	Token     string    `mapstructure:"token"`
+	BasicAuth BasicAuth `mapstructure:"basic_auth"`
+}
+
+// BasicAuth is a pair of basic auth credentials
+type BasicAuth struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// Security holds the signing keys
+type Security struct {
+	APISign APISign `mapstructure:"api_sign"`
+	JWT     JWT     `mapstructure:"jwt"`
+}
+
+// APISign is the API signature credentials
+type APISign struct {
+	AppKey      string `mapstructure:"app_key"`
+	AppSecurity string `mapstructure:"app_security"`
+}
+
+// JWT is the HMAC key signing the tokens
+type JWT struct {
+	Key string `mapstructure:"key"`
+}
+
+// Data is the data layer connections
+type Data struct {
+	DB    map[string]Database `mapstructure:"db"`
+	Redis Redis               `mapstructure:"redis"`
+	Cache Cache               `mapstructure:"cache"`
+}
+
+// Database is a named database connection, migrate applies the
+// pending migrations at startup. Reads are spread over the replicas by
+// the policy, they stay on the primary for the sticky window after a
+// write of the same request.
+type Database struct {
+	Driver       string        `mapstructure:"driver"`
+	DSN          string        `mapstructure:"dsn"`
+	Migrate      bool          `mapstructure:"migrate"`
+	Replicas     []Replica     `mapstructure:"replicas"`
+	Policy       string        `mapstructure:"policy"`
+	StickyWindow time.Duration `mapstructure:"sticky_window"`
+	Pool         Pool          `mapstructure:"pool"`
+	Connect      Connect       `mapstructure:"connect"`
+	SQLite       SQLite        `mapstructure:"sqlite"`
+	Postgres     Postgres      `mapstructure:"postgres"`
+	MySQL        MySQL         `mapstructure:"mysql"`
+	Log          log.OrmConfig `mapstructure:"log"`
+}
+
+// Pool is the connection pool of a database and of each of its replicas,
+// zero leaves a limit unset.
+type Pool struct {
+	MaxOpen     int           `mapstructure:"max_open"`
+	MaxIdle     int           `mapstructure:"max_idle"`
+	MaxLifetime time.Duration `mapstructure:"max_lifetime"`
+	MaxIdleTime time.Duration `mapstructure:"max_idle_time"`
+}
+
+// Connect retries the first connection at startup, the backoff doubles
+// up to max_backoff until the timeout.
+type Connect struct {
+	Timeout    time.Duration `mapstructure:"timeout"`
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// SQLite is the settings of the sqlite driver
+type SQLite struct {
+	JournalMode string        `mapstructure:"journal_mode"`
+	ForeignKeys bool          `mapstructure:"foreign_keys"`
+	BusyTimeout time.Duration `mapstructure:"busy_timeout"`
+}
+
+// Postgres is the settings of the postgres driver, zero disables the timeout
+type Postgres struct {
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+}
+
+// MySQL is the settings of the mysql driver, zero disables the timeouts
+type MySQL struct {
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+// Replica is a read replica of a database connection
+type Replica struct {
+	DSN string `mapstructure:"dsn"`
+}
+
+// Redis is the Redis client
+type Redis struct {
+	Addr         string        `mapstructure:"addr"`
+	Password     string        `mapstructure:"password"`
+	DB           int           `mapstructure:"db"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+// Cache is the cache-aside of the repositories, the memory driver keeps
+// at most size entries per instance, the redis one shares them on
+// data.redis. The not found lookups are cached for the negative ttl.
+type Cache struct {
+	Driver      string        `mapstructure:"driver"`
+	Size        int           `mapstructure:"size"`
+	TTL         time.Duration `mapstructure:"ttl"`
+	NegativeTTL time.Duration `mapstructure:"negative_ttl"`
+	Tiered      Tiered        `mapstructure:"tiered"`
+}
+
+// Tiered is the tiered cache driver, it keeps size near copies of the
+// redis entries per instance and evicts them on the invalidations
+// broadcast over the channel. The copies live for the near ttl, or the
+// fallback ttl while the channel is down.
+type Tiered struct {
+	Channel     string        `mapstructure:"channel"`
+	NearTTL     time.Duration `mapstructure:"near_ttl"`
+	FallbackTTL time.Duration `mapstructure:"fallback_ttl"`
+}
+
+// Outbox is the relay of the domain events, a failed event is retried
+// after the backoff doubling up to max_backoff and dead lettered after
+// max_attempts.
+type Outbox struct {
+	Interval    time.Duration `mapstructure:"interval"`
+	Batch       int           `mapstructure:"batch"`
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Backoff     time.Duration `mapstructure:"backoff"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+}
+
+// Jobs is the queue of the background jobs, a job running past the lease
+// is canceled and claimed again. A failed job is retried after the backoff
+// doubling up to max_backoff and dead after max_attempts.
+type Jobs struct {
+	Workers     int           `mapstructure:"workers"`
+	Poll        time.Duration `mapstructure:"poll"`
+	Lease       time.Duration `mapstructure:"lease"`
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Backoff     time.Duration `mapstructure:"backoff"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+}
+
+// Scheduler is the periodic tasks, only the instance holding the leader
+// lock runs them. The leader renews the lock every third of leader_ttl.
+type Scheduler struct {
+	Lock       string        `mapstructure:"lock"`
+	LeaderTTL  time.Duration `mapstructure:"leader_ttl"`
+	History    int           `mapstructure:"history"`
+	PurgeUsers PurgeTask     `mapstructure:"purge_users"`
+}
+
+// Task is the schedule of a periodic task, an empty spec disables it. The
+// overlap policy skips or queues a run due while the previous one runs.
+type Task struct {
+	Spec    string        `mapstructure:"spec"`
+	Jitter  time.Duration `mapstructure:"jitter"`
+	Overlap string        `mapstructure:"overlap"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// PurgeTask purges the records soft deleted for longer than after
+type PurgeTask struct {
+	Task  `mapstructure:",squash"`
+	After time.Duration `mapstructure:"after"`
+}
+
+// Metrics is the prometheus metrics
+type Metrics struct {
+	Path                        string    `mapstructure:"path"`
+	Buckets                     []float64 `mapstructure:"buckets"`
+	SizeBuckets                 []float64 `mapstructure:"size_buckets"`
+	NativeHistogramBucketFactor float64   `mapstructure:"native_histogram_bucket_factor"`
+}
+
+// SLO is the service level objectives
+type SLO struct {
+	Groups []SLOGroup `mapstructure:"groups"`
+}
+
+// SLOGroup is the objective of a route group
+type SLOGroup struct {
+	Name             string        `mapstructure:"name"`
+	Routes           []string      `mapstructure:"routes"`
+	Availability     float64       `mapstructure:"availability"`
+	Latency          float64       `mapstructure:"latency"`
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold"`
+}
+
+// Health is the health probes
+type Health struct {
+	Timeout     time.Duration `mapstructure:"timeout"`
+	CacheTTL    time.Duration `mapstructure:"cache_ttl"`
+	DrainDelay  time.Duration `mapstructure:"drain_delay"`
+	DiskMinFree uint64        `mapstructure:"disk_min_free"`
+}
+
+// Default returns the config used for the keys missing from the file
+func Default() *Config {
+	return &Config{
+		Env: "local",
+		HTTP: HTTP{
+			Host:      "0.0.0.0",
+			Port:      7080,
+			CORS:      CORS{AllowOrigins: []string{"*"}},
+			RateLimit: RateLimit{Max: 100, Expiration: time.Minute},
+		},
+		Admin: Admin{Host: "127.0.0.1", Port: 7081},
+		Data: Data{
+			Redis: Redis{
+				ReadTimeout:  time.Millisecond * 200,
+				WriteTimeout: time.Millisecond * 200,
+			},
+			Cache: Cache{
+				Driver:      CacheMemory,
+				Size:        10000,
+				TTL:         time.Minute * 5,
+				NegativeTTL: time.Second * 30,
+				Tiered: Tiered{
+					Channel:     "cache:invalidate",
+					NearTTL:     time.Minute,
+					FallbackTTL: time.Second * 5,
+				},
+			},
+		},
+		Outbox: Outbox{
+			Interval:    time.Second,
+			Batch:       100,
+			MaxAttempts: 10,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute * 5,
+		},
+		Jobs: Jobs{
+			Workers:     4,
+			Poll:        time.Second,
+			Lease:       time.Minute * 5,
+			MaxAttempts: 5,
+			Backoff:     time.Second * 10,
+			MaxBackoff:  time.Hour,
+		},
+		Scheduler: Scheduler{
+			Lock:      LockDB,
+			LeaderTTL: time.Second * 30,
+			History:   100,
+			PurgeUsers: PurgeTask{
+				Task:  Task{Spec: "0 3 * * *", Jitter: time.Minute * 5, Overlap: scheduler.OverlapSkip, Timeout: time.Minute * 10},
+				After: time.Hour * 24 * 30,
+			},
+		},
+		Metrics: Metrics{Path: "/metrics"},
+		Health: Health{
+			Timeout:     time.Second * 2,
+			CacheTTL:    time.Second * 2,
+			DiskMinFree: 100 << 20,
+		},
+		Log: log.Config{
+			Encoding: log.EncodingConsole,
+			Level:    "info",
+			Rotation: log.Rotation{MaxAge: 30, MaxSize: 1024, MaxBackups: 30},
+		},
+		Secrets: Secrets{Vault: Vault{Path: "config/secrets.vault"}},
+	}
+}
+
+// DefaultDatabase returns the defaults of every named database connection
+func DefaultDatabase() Database {
+	return Database{
+		Policy:       PolicyRoundRobin,
+		StickyWindow: time.Second * 2,
+		Pool: Pool{
+			MaxOpen:     100,
+			MaxIdle:     10,
+			MaxLifetime: time.Hour,
+		},
+		Connect: Connect{
+			Timeout:    time.Second * 30,
+			Backoff:    time.Millisecond * 500,
+			MaxBackoff: time.Second * 5,
+		},
+		SQLite: SQLite{
+			JournalMode: JournalWAL,
+			ForeignKeys: true,
+			BusyTimeout: time.Second * 5,
+		},
+		Log: log.OrmConfig{
+			Level:          "warn",
+			SlowThreshold:  time.Millisecond * 300,
+			Parameterized:  true,
+			IgnoreNotFound: true,
+		},
+	}
+}
+
+// Load unmarshals the config with the defaults and the APP_ prefixed
+// environment overrides applied, then resolves the secret placeholders.
+// It fails with every problem found.
+func Load(v *viper.Viper) (*Config, error) {
+	config.SetDefaults(v, "", Default())
+	for name := range v.GetStringMap("data.db") {
+		config.SetDefaults(v, "data.db."+name, DefaultDatabase())
+	}
+	config.BindEnv(v, config.EnvPrefix)
+
+	c := new(Config)
+	if err := v.Unmarshal(c); err != nil {
+		return nil, err
+	}
+	if err := c.resolveSecrets(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Redacted returns the settings of the config with the secrets masked,
+// both the secret keys and the resolved secret values.
+func (c *Config) Redacted() map[string]any {
+	return config.Redact(config.ToMap(c), c.secrets...)
+}
+
+// Exposed reports whether the public router exposes the endpoint
+func (c *Config) Exposed(endpoint string) bool {
+	for _, name := range c.HTTP.Expose {
+		if name == endpoint {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/kelein/trove-fiber/pkg/config"
+	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/vault"
+)
+
+const validYAML = `
+security:
+  jwt:
+    key: QQYnRFerJTSEcrfB89fw8prOaObmrch8
+data:
+  db:
+    user:
+      driver: sqlite
+      dsn: store/trove.db?_busy_timeout=5000
+`
+
+func newViper(t *testing.T, content string) *viper.Viper {
+	t.Helper()
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(content)); err != nil {
+		t.Fatalf("read config error = %v", err)
+	}
+	return v
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := Load(newViper(t, validYAML))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.HTTP.Port != 7080 || c.Admin.Port != 7081 || c.Metrics.Path != "/metrics" {
+		t.Errorf("listeners = %+v %+v, want defaults", c.HTTP, c.Admin)
+	}
+	if c.Health.Timeout != 2*time.Second {
+		t.Errorf("health.timeout = %v, want 2s", c.Health.Timeout)
+	}
+	if db := c.Data.DB[DBUser]; db.Log != DefaultDatabase().Log {
+		t.Errorf("data.db.user.log = %+v, want defaults", db.Log)
+	}
+}
+
+func TestLoad_Env(t *testing.T) {
+	t.Setenv("APP_HTTP_PORT", "9090")
+	t.Setenv("APP_HTTP_EXPOSE", "health,metrics")
+	t.Setenv("APP_DATA_DB_USER_DSN", "/tmp/trove.db")
+	t.Setenv("APP_DATA_DB_USER_LOG_LEVEL", "silent")
+
+	c, err := Load(newViper(t, validYAML))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.HTTP.Port != 9090 {
+		t.Errorf("http.port = %d, want 9090", c.HTTP.Port)
+	}
+	if !c.Exposed(ExposeMetrics) || !c.Exposed(ExposeHealth) {
+		t.Errorf("http.expose = %v, want health and metrics", c.HTTP.Expose)
+	}
+	if db := c.Data.DB[DBUser]; db.DSN != "/tmp/trove.db" || db.Log.Level != "silent" {
+		t.Errorf("data.db.user = %+v, want env overrides", db)
+	}
+}
+
+func TestLoad_Problems(t *testing.T) {
+	_, err := Load(newViper(t, `
+http:
+  port: 70800
+  expose: [health, debug]
+  cors:
+    allow_origins: [example.com]
+security:
+  jwt:
+    key: short
+data:
+  db:
+    user:
+      driver: mysql
+      dsn: "not a dsn"
+      policy: weighted
+      replicas:
+        - dsn: ""
+      pool:
+        max_open: 5
+        max_idle: 10
+      connect:
+        backoff: 0s
+    audit:
+      driver: oracle
+  cache:
+    driver: tiered
+    ttl: 0s
+    tiered:
+      fallback_ttl: 0s
+scheduler:
+  purge_users:
+    spec: "0 3 * *"
+    overlap: wait
+log:
+  encoding: yaml
+  sinks:
+    - type: file
+slo:
+  groups:
+    - name: auth
+      routes: [/v1/login]
+      availability: 99.9
+      latency: 0.99
+`))
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load() error = %v, want ValidationError", err)
+	}
+	want := []string{
+		"data.db.audit.driver",
+		"data.db.audit.dsn",
+		"data.db.user.connect.backoff",
+		"data.db.user.dsn",
+		"data.db.user.policy",
+		"data.db.user.pool.max_idle",
+		"data.db.user.replicas.0.dsn",
+		"data.cache.tiered",
+		"data.cache.ttl",
+		"data.redis.addr",
+		"http.cors.allow_origins",
+		"http.expose",
+		"http.port",
+		"log.encoding",
+		"log.sinks.0.path",
+		"scheduler.purge_users.overlap",
+		"scheduler.purge_users.spec",
+		"security.jwt.key",
+		"slo.groups.auth.availability",
+	}
+	for _, key := range want {
+		found := false
+		for _, p := range verr.Problems {
+			found = found || strings.HasPrefix(p, key+":")
+		}
+		if !found {
+			t.Errorf("problems %q miss key %s", verr.Problems, key)
+		}
+	}
+	if len(verr.Problems) != len(want) {
+		t.Errorf("got %d problems %q, want %d", len(verr.Problems), verr.Problems, len(want))
+	}
+	if strings.Contains(err.Error(), "not a dsn") {
+		t.Errorf("error %q leaks the DSN", err)
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	c, err := Load(newViper(t, validYAML))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	settings := c.Redacted()
+	security := settings["security"].(map[string]any)["jwt"].(map[string]any)
+	if security["key"] != config.Redacted {
+		t.Errorf("security.jwt.key = %v, want redacted", security["key"])
+	}
+	user := settings["data"].(map[string]any)["db"].(map[string]any)[DBUser].(map[string]any)
+	if user["dsn"] != config.Redacted || user["driver"] != DriverSQLite {
+		t.Errorf("data.db.user = %v, want dsn redacted only", user)
+	}
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.vault")
+	t.Setenv(VaultKeyEnv, "passphrase")
+	t.Setenv("TROVE_TEST_REDIS", "redis-s3cr3t")
+	vlt, err := vault.Open(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("open vault error = %v", err)
+	}
+	const jwtKey = "vaulted-jwt-key-0123456789abcdefghij"
+	vlt.Set("jwt/key", jwtKey)
+	if err = vlt.Save(); err != nil {
+		t.Fatalf("save vault error = %v", err)
+	}
+
+	c, err := Load(newViper(t, `
+secrets:
+  vault:
+    path: `+path+`
+security:
+  jwt:
+    key: ${vault:jwt/key}
+data:
+  db:
+    user:
+      driver: sqlite
+      dsn: store/trove.db
+  redis:
+    addr: "${env:TROVE_TEST_REDIS}:6379"
+`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Security.JWT.Key != jwtKey {
+		t.Errorf("security.jwt.key = %q, want the vault secret", c.Security.JWT.Key)
+	}
+
+	dump, _ := json.Marshal(c.Redacted())
+	for _, leak := range []string{jwtKey, "redis-s3cr3t"} {
+		if strings.Contains(string(dump), leak) {
+			t.Errorf("config dump %s leaks %q", dump, leak)
+		}
+	}
+
+	opts, err := log.OptionsFromConfig(c.Log)
+	if err != nil {
+		t.Fatalf("log options error = %v", err)
+	}
+	buf := new(bytes.Buffer)
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{ReplaceAttr: opts.Redactor.ReplaceAttr})
+	slog.New(handler).Info("connect", "addr", c.Data.Redis.Addr)
+	if strings.Contains(buf.String(), "redis-s3cr3t") {
+		t.Errorf("log %s leaks the secret", buf)
+	}
+}
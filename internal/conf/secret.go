@@ -0,0 +1,94 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/kelein/trove-fiber/pkg/config"
+	"github.com/kelein/trove-fiber/pkg/vault"
+)
+
+// VaultKeyEnv is the environment variable holding the vault passphrase
+const VaultKeyEnv = "TROVE_VAULT_KEY"
+
+// Secrets is the secret providers of the ${provider:ref} placeholders
+type Secrets struct {
+	Vault Vault `mapstructure:"vault"`
+}
+
+// Vault is the local encrypted secrets file, its passphrase comes from
+// the TROVE_VAULT_KEY environment variable or else from the key file.
+type Vault struct {
+	Path    string `mapstructure:"path"`
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// LoadVault reads the vault config alone, the secrets commands must
+// work before the placeholders of the config can be resolved.
+func LoadVault(v *viper.Viper) (Vault, error) {
+	config.SetDefaults(v, "", Default())
+	config.BindEnv(v, config.EnvPrefix)
+	var vc Vault
+	err := v.UnmarshalKey("secrets.vault", &vc)
+	return vc, err
+}
+
+// Open decrypts the vault file
+func (v Vault) Open() (*vault.Vault, error) {
+	passphrase := os.Getenv(VaultKeyEnv)
+	if passphrase == "" && v.KeyFile != "" {
+		data, err := os.ReadFile(v.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+	if passphrase == "" {
+		return nil, errors.New("vault passphrase missing, set " + VaultKeyEnv + " or secrets.vault.key_file")
+	}
+	return vault.Open(v.Path, []byte(passphrase))
+}
+
+// provider opens the vault on the first reference only,
+// a config without vault references needs no passphrase.
+func (v Vault) provider() config.SecretProvider {
+	var (
+		once  sync.Once
+		vlt   *vault.Vault
+		cause error
+	)
+	return config.ProviderFunc(func(ref string) (string, error) {
+		once.Do(func() { vlt, cause = v.Open() })
+		if cause != nil {
+			return "", cause
+		}
+		secret, ok := vlt.Get(ref)
+		if !ok {
+			return "", fmt.Errorf("no secret %q in vault %s", ref, v.Path)
+		}
+		return secret, nil
+	})
+}
+
+// resolveSecrets replaces the secret placeholders of all values and
+// keeps the secrets, so that dumps and logs mask them.
+func (c *Config) resolveSecrets() error {
+	r := config.NewResolver(map[string]config.SecretProvider{
+		config.ProviderFile:  config.FileProvider(),
+		config.ProviderEnv:   config.EnvProvider(),
+		config.ProviderVault: c.Secrets.Vault.provider(),
+	})
+	var p config.Problems
+	r.ResolveStruct(c, &p)
+	if err := p.Err(); err != nil {
+		return err
+	}
+	c.secrets = r.Secrets()
+	c.Log.Redact.Values = c.secrets
+	return nil
+}
@@ -0,0 +1,309 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kelein/trove-fiber/pkg/config"
+	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
+)
+
+// Minimal key lengths, an HMAC key shorter than the hash adds no strength
+const (
+	minJWTKeyLength   = 32
+	minDebugKeyLength = 16
+)
+
+var (
+	exposes   = []string{ExposePprof, ExposeHealth, ExposeMetrics, ExposeSwagger}
+	drivers   = []string{DriverMySQL, DriverPostgres, DriverSQLite}
+	caches    = []string{CacheMemory, CacheRedis, CacheTiered}
+	locks     = []string{LockDB, LockRedis}
+	overlaps  = []string{scheduler.OverlapSkip, scheduler.OverlapQueue}
+	policies  = []string{PolicyRoundRobin, PolicyLeastConn, PolicyRandom}
+	journals  = []string{JournalDelete, "truncate", "persist", "memory", JournalWAL, "off"}
+	encodings = []string{log.EncodingJSON, log.EncodingText, log.EncodingConsole}
+	sinkTypes = []string{log.SinkStdout, log.SinkStderr, log.SinkFile}
+	ormLevels = []string{"silent", "error", "warn", "info"}
+
+	errEmptyPath = errors.New("empty database path")
+)
+
+// Validate checks the whole config, the error lists every problem found
+func (c *Config) Validate() error {
+	var p config.Problems
+	c.validateListeners(&p)
+	c.validateSecurity(&p)
+	c.validateData(&p)
+	c.validateMetrics(&p)
+	c.validateLog(&p)
+	return p.Err()
+}
+
+func (c *Config) validateListeners(p *config.Problems) {
+	p.Port("http.port", c.HTTP.Port)
+	p.Port("admin.port", c.Admin.Port)
+	if c.HTTP.Port == c.Admin.Port {
+		p.Addf("admin.port", "must differ from http.port %d", c.HTTP.Port)
+	}
+	for _, name := range c.HTTP.Expose {
+		p.OneOf("http.expose", name, exposes...)
+	}
+	validateCORS(p, c.HTTP.CORS)
+	if rl := c.HTTP.RateLimit; rl.Enabled && (rl.Max < 1 || rl.Expiration <= 0) {
+		p.Addf("http.rate_limit", "max and expiration must be positive")
+	}
+	for _, ip := range c.Admin.AllowIPs {
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				p.Addf("admin.allow_ips", "%q is neither an IP nor a CIDR", ip)
+			}
+		}
+	}
+	if auth := c.Admin.BasicAuth; (auth.Username == "") != (auth.Password == "") {
+		p.Addf("admin.basic_auth", "username and password must be set together")
+	}
+}
+
+// validateCORS checks the origins the CORS middleware would panic on, no
+// origins allow every origin like a lone wildcard.
+func validateCORS(p *config.Problems, c CORS) {
+	wildcard := len(c.AllowOrigins) == 0 || slices.Contains(c.AllowOrigins, "*")
+	if wildcard && c.AllowCredentials {
+		p.Addf("http.cors.allow_origins", "explicit origins are required with credentials")
+	}
+	if len(c.AllowOrigins) > 1 && slices.Contains(c.AllowOrigins, "*") {
+		p.Addf("http.cors.allow_origins", "wildcard origin must be the only one")
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin != "*" && !validOrigin(origin) {
+			p.Addf("http.cors.allow_origins", "%q is not scheme://host[:port]", origin)
+		}
+	}
+}
+
+// validOrigin reports whether the origin is scheme://host[:port], the host
+// may start with a *. subdomain wildcard.
+func validOrigin(origin string) bool {
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+	if err != nil || u.Scheme == "" || u.Host == "" || strings.Contains(u.Host, "*") {
+		return false
+	}
+	return (u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+func (c *Config) validateSecurity(p *config.Problems) {
+	p.Required("security.jwt.key", c.Security.JWT.Key)
+	if c.Security.JWT.Key != "" {
+		p.MinLength("security.jwt.key", c.Security.JWT.Key, minJWTKeyLength)
+	}
+}
+
+func (c *Config) validateData(p *config.Problems) {
+	if _, ok := c.Data.DB[DBUser]; !ok {
+		p.Addf("data.db."+DBUser, "is required")
+	}
+	for name, db := range c.Data.DB {
+		key := "data.db." + name
+		p.OneOf(key+".driver", db.Driver, drivers...)
+		p.OneOf(key+".log.level", db.Log.Level, ormLevels...)
+		p.OneOf(key+".policy", db.Policy, policies...)
+		if db.StickyWindow < 0 {
+			p.Addf(key+".sticky_window", "must not be negative")
+		}
+		validatePool(p, key, db)
+		for i, replica := range db.Replicas {
+			if err := checkDSN(db.Driver, replica.DSN); replica.DSN == "" || err != nil {
+				p.Addf(fmt.Sprintf("%s.replicas.%d.dsn", key, i), "invalid %s DSN", db.Driver)
+			}
+		}
+		if db.DSN == "" {
+			p.Required(key+".dsn", db.DSN)
+			continue
+		}
+		// * The DSN itself is never echoed, it usually embeds a password.
+		if err := checkDSN(db.Driver, db.DSN); err != nil {
+			p.Addf(key+".dsn", "invalid %s DSN", db.Driver)
+		}
+	}
+	if addr := c.Data.Redis.Addr; addr != "" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			p.Addf("data.redis.addr", "%q is not a host:port address", addr)
+		}
+	}
+
+	cache := c.Data.Cache
+	p.OneOf("data.cache.driver", cache.Driver, caches...)
+	if cache.Driver != CacheRedis && cache.Size < 1 {
+		p.Addf("data.cache.size", "must be positive")
+	}
+	if cache.Driver != CacheMemory {
+		p.Required("data.redis.addr", c.Data.Redis.Addr)
+	}
+	if tiered := cache.Tiered; cache.Driver == CacheTiered {
+		p.Required("data.cache.tiered.channel", tiered.Channel)
+		if tiered.FallbackTTL <= 0 || tiered.NearTTL < tiered.FallbackTTL {
+			p.Addf("data.cache.tiered", "fallback_ttl must be positive and not above near_ttl")
+		}
+	}
+	if cache.TTL <= 0 {
+		p.Addf("data.cache.ttl", "must be positive")
+	}
+	if cache.NegativeTTL < 0 {
+		p.Addf("data.cache.negative_ttl", "must not be negative")
+	}
+
+	o := c.Outbox
+	if o.Interval <= 0 || o.Batch < 1 || o.MaxAttempts < 1 || o.Backoff <= 0 {
+		p.Addf("outbox", "interval, batch, max_attempts and backoff must be positive")
+	}
+	if o.MaxBackoff < o.Backoff {
+		p.Addf("outbox.max_backoff", "must not be below backoff %s", o.Backoff)
+	}
+
+	j := c.Jobs
+	if j.Workers < 1 || j.Poll <= 0 || j.Lease <= 0 || j.MaxAttempts < 1 || j.Backoff <= 0 {
+		p.Addf("jobs", "workers, poll, lease, max_attempts and backoff must be positive")
+	}
+	if j.MaxBackoff < j.Backoff {
+		p.Addf("jobs.max_backoff", "must not be below backoff %s", j.Backoff)
+	}
+
+	sc := c.Scheduler
+	p.OneOf("scheduler.lock", sc.Lock, locks...)
+	if sc.Lock == LockRedis {
+		p.Required("data.redis.addr", c.Data.Redis.Addr)
+	}
+	if sc.LeaderTTL < time.Second*3 {
+		p.Addf("scheduler.leader_ttl", "must be at least 3s")
+	}
+	if sc.History < 1 {
+		p.Addf("scheduler.history", "must be positive")
+	}
+	validateTask(p, "scheduler.purge_users", sc.PurgeUsers.Task)
+	if sc.PurgeUsers.After <= 0 {
+		p.Addf("scheduler.purge_users.after", "must be positive")
+	}
+}
+
+func validateTask(p *config.Problems, key string, task Task) {
+	if task.Spec == "" {
+		return
+	}
+	if _, err := scheduler.ParseSpec(task.Spec); err != nil {
+		p.Addf(key+".spec", "invalid cron expression: %v", err)
+	}
+	p.OneOf(key+".overlap", task.Overlap, overlaps...)
+	if task.Jitter < 0 || task.Timeout < 0 {
+		p.Addf(key, "jitter and timeout must not be negative")
+	}
+}
+
+func validatePool(p *config.Problems, key string, db Database) {
+	if db.Pool.MaxOpen < 0 || db.Pool.MaxIdle < 0 || db.Pool.MaxLifetime < 0 || db.Pool.MaxIdleTime < 0 {
+		p.Addf(key+".pool", "limits must not be negative")
+	}
+	if db.Pool.MaxOpen > 0 && db.Pool.MaxIdle > db.Pool.MaxOpen {
+		p.Addf(key+".pool.max_idle", "%d exceeds max_open %d", db.Pool.MaxIdle, db.Pool.MaxOpen)
+	}
+	if db.Connect.Timeout < 0 {
+		p.Addf(key+".connect.timeout", "must not be negative")
+	}
+	if db.Connect.Timeout > 0 && db.Connect.Backoff <= 0 {
+		p.Addf(key+".connect.backoff", "must be positive to retry")
+	}
+	if db.Connect.MaxBackoff < db.Connect.Backoff {
+		p.Addf(key+".connect.max_backoff", "must not be below backoff %s", db.Connect.Backoff)
+	}
+	if db.Driver == DriverSQLite {
+		p.OneOf(key+".sqlite.journal_mode", strings.ToLower(db.SQLite.JournalMode), journals...)
+	}
+}
+
+func checkDSN(driver, dsn string) error {
+	switch strings.ToLower(driver) {
+	case DriverMySQL:
+		_, err := mysql.ParseDSN(dsn)
+		return err
+	case DriverPostgres:
+		_, err := pgconn.ParseConfig(dsn)
+		return err
+	case DriverSQLite:
+		if path, _, _ := strings.Cut(dsn, "?"); strings.TrimPrefix(path, "file:") == "" {
+			return errEmptyPath
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateMetrics(p *config.Problems) {
+	if !strings.HasPrefix(c.Metrics.Path, "/") {
+		p.Addf("metrics.path", "%q must start with /", c.Metrics.Path)
+	}
+	if f := c.Metrics.NativeHistogramBucketFactor; f != 0 && f <= 1 {
+		p.Addf("metrics.native_histogram_bucket_factor", "%v must be greater than 1", f)
+	}
+
+	groups := make(map[string]bool, len(c.SLO.Groups))
+	for _, g := range c.SLO.Groups {
+		key := "slo.groups." + g.Name
+		p.Required("slo.groups.name", g.Name)
+		if groups[g.Name] {
+			p.Addf(key, "is duplicated")
+		}
+		groups[g.Name] = true
+		if len(g.Routes) == 0 {
+			p.Addf(key+".routes", "is required")
+		}
+		for field, ratio := range map[string]float64{"availability": g.Availability, "latency": g.Latency} {
+			if ratio <= 0 || ratio >= 1 {
+				p.Addf(key+"."+field, "%v must be between 0 and 1", ratio)
+			}
+		}
+	}
+}
+
+func (c *Config) validateLog(p *config.Problems) {
+	l := c.Log
+	p.OneOf("log.encoding", l.Encoding, encodings...)
+	if _, err := log.ParseLevel(l.Level); err != nil {
+		p.Addf("log.log_level", "%v", err)
+	}
+	for module, lvl := range l.Modules {
+		if _, err := log.ParseLevel(lvl); err != nil {
+			p.Addf("log.modules."+module, "%v", err)
+		}
+	}
+	if l.DebugKey != "" {
+		p.MinLength("log.debug_key", l.DebugKey, minDebugKeyLength)
+	}
+	for i, sink := range l.Sinks {
+		key := fmt.Sprintf("log.sinks.%d", i)
+		if sink.Type != "" {
+			p.OneOf(key+".type", sink.Type, sinkTypes...)
+		}
+		if sink.Type == log.SinkFile {
+			p.Required(key+".path", sink.Path)
+		}
+		if sink.Level != "" {
+			if _, err := log.ParseLevel(sink.Level); err != nil {
+				p.Addf(key+".level", "%v", err)
+			}
+		}
+	}
+	for _, pattern := range l.Redact.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			p.Addf("log.redact.patterns", "%q does not compile", pattern)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package conf
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/config"
+	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// debounce coalesces the bursts of file events of a single save
+const debounce = time.Millisecond * 200
+
+// Reload Results
+const (
+	resultSuccess  = "success"
+	resultFailure  = "failure"
+	resultRejected = "rejected"
+)
+
+// ErrNotReloadable rejects a reload changing keys no component can apply
+var ErrNotReloadable = errors.New("config changes require a restart")
+
+// Event is the change notification of a reload
+type Event struct {
+	Old     *Config
+	New     *Config
+	Changes []config.Change
+}
+
+// Changed reports whether any key under the given key changed
+func (e Event) Changed(key string) bool {
+	for _, c := range e.Changes {
+		if covers(key, c.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscriber applies the reloaded config to a component
+type Subscriber func(Event) error
+
+type subscription struct {
+	name string
+	keys []string
+	fn   Subscriber
+}
+
+// Watcher reloads the config on file changes and on demand, it validates
+// the new config and hands it to the subscribers of the changed keys.
+type Watcher struct {
+	src     *config.Source
+	current atomic.Pointer[Config]
+
+	mu    sync.Mutex
+	subs  []subscription
+	stop  func() error
+	timer *time.Timer
+
+	reloads *prometheus.CounterVec
+	info    *prometheus.GaugeVec
+}
+
+// NewWatcher creates a new Watcher of the loaded config, the log levels
+// are reloadable out of the box.
+func NewWatcher(src *config.Source, c *Config, reg prometheus.Registerer) (*Watcher, error) {
+	w := &Watcher{src: src}
+	w.current.Store(c)
+	w.reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "config",
+		Name:      "reloads_total",
+		Help:      "How many config reloads happened, with label result of success, failure or rejected.",
+	}, []string{"result"})
+	w.info = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "config",
+		Name:      "info",
+		Help:      "The hash of the config in effect, always 1.",
+	}, []string{"hash"})
+	if err := reg.Register(w.reloads); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(w.info); err != nil {
+		return nil, err
+	}
+	w.info.WithLabelValues(Hash(c)).Set(1)
+
+	w.Subscribe("log", []string{"log.log_level", "log.modules"}, func(e Event) error {
+		return log.ApplyLevels(e.New.Log)
+	})
+	return w, nil
+}
+
+// Current returns the config in effect
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// Subscribe declares the reloadable keys of a component, the subscriber
+// is called on every reload changing any key under them.
+func (w *Watcher) Subscribe(name string, keys []string, fn Subscriber) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, subscription{name: name, keys: keys, fn: fn})
+}
+
+// Reloadable returns the reloadable keys declared by all components
+func (w *Watcher) Reloadable() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var keys []string
+	for _, s := range w.subs {
+		keys = append(keys, s.keys...)
+	}
+	return keys
+}
+
+// Start watches the config files until Stop
+func (w *Watcher) Start(context.Context) error {
+	stop, err := w.src.Watch(func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.timer != nil {
+			w.timer.Stop()
+		}
+		w.timer = time.AfterFunc(debounce, func() {
+			if err := w.Reload(context.Background()); err != nil {
+				slog.Error("config reload failed", "error", err)
+			}
+		})
+	})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.stop = stop
+	w.mu.Unlock()
+	return nil
+}
+
+// Stop stops watching the config files
+func (w *Watcher) Stop(context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.stop == nil {
+		return nil
+	}
+	return w.stop()
+}
+
+// Reload reads and validates the config files, then applies it. A reload
+// changing keys which no subscriber declared is rejected as a whole.
+func (w *Watcher) Reload(context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.reload()
+	switch {
+	case err == nil:
+		w.reloads.WithLabelValues(resultSuccess).Inc()
+	case errors.Is(err, ErrNotReloadable):
+		w.reloads.WithLabelValues(resultRejected).Inc()
+	default:
+		w.reloads.WithLabelValues(resultFailure).Inc()
+	}
+	return err
+}
+
+func (w *Watcher) reload() error {
+	// * The files are read into a new source, the source in effect
+	// * describes the applied config until the reload succeeds.
+	src, err := w.src.Next()
+	if err != nil {
+		return err
+	}
+	next, err := Load(src.Viper)
+	if err != nil {
+		return err
+	}
+
+	old := w.current.Load()
+	event := Event{Old: old, New: next, Changes: config.Diff(config.ToMap(old), config.ToMap(next))}
+	if len(event.Changes) == 0 {
+		w.src.Swap(src)
+		slog.Info("config reloaded without changes")
+		return nil
+	}
+	if fixed := w.fixed(event.Changes); len(fixed) > 0 {
+		secrets := append(slices.Clone(old.secrets), next.secrets...)
+		diff := make([]string, 0, len(fixed))
+		for _, c := range fixed {
+			diff = append(diff, c.Format(secrets...))
+		}
+		slog.Error("config reload rejected", "diff", diff)
+		return fmt.Errorf("%w: %s", ErrNotReloadable, strings.Join(keys(fixed), ", "))
+	}
+
+	// * Subscribers applied before a failing one get the old config back,
+	// * so that the components never run with a half applied reload.
+	var applied []subscription
+	for _, s := range w.subs {
+		if !s.interested(event) {
+			continue
+		}
+		if err = s.fn(event); err != nil {
+			rollback := Event{Old: next, New: old, Changes: event.Changes}
+			for _, a := range applied {
+				if rerr := a.fn(rollback); rerr != nil {
+					slog.Error("config rollback failed", "component", a.name, "error", rerr)
+				}
+			}
+			return fmt.Errorf("apply config to %s: %w", s.name, err)
+		}
+		applied = append(applied, s)
+	}
+
+	w.src.Swap(src)
+	w.current.Store(next)
+	w.info.Reset()
+	w.info.WithLabelValues(Hash(next)).Set(1)
+	slog.Info("config reloaded", "changes", keys(event.Changes))
+	return nil
+}
+
+// fixed returns the changes no subscriber declared as reloadable
+func (w *Watcher) fixed(changes []config.Change) []config.Change {
+	var fixed []config.Change
+	for _, c := range changes {
+		reloadable := false
+		for _, s := range w.subs {
+			for _, key := range s.keys {
+				reloadable = reloadable || covers(key, c.Key)
+			}
+		}
+		if !reloadable {
+			fixed = append(fixed, c)
+		}
+	}
+	return fixed
+}
+
+func (s subscription) interested(e Event) bool {
+	for _, key := range s.keys {
+		if e.Changed(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether the key is the prefix key or nested under it
+func covers(prefix, key string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+".")
+}
+
+func keys(changes []config.Change) []string {
+	list := make([]string, 0, len(changes))
+	for _, c := range changes {
+		list = append(list, c.Key)
+	}
+	return list
+}
+
+// Hash returns a short digest of the config, it changes with any value
+func Hash(c *Config) string {
+	data, _ := json.Marshal(config.ToMap(c))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,129 @@
+package conf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kelein/trove-fiber/pkg/config"
+)
+
+func newWatcher(t *testing.T) (*Watcher, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trove.yaml")
+	writeConfig(t, path, "", "")
+
+	src, err := config.NewSource(path, "")
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	c, err := Load(src.Viper)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	w, err := NewWatcher(src, c, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	return w, path
+}
+
+func writeConfig(t *testing.T, path, http, origins string) {
+	t.Helper()
+	if origins == "" {
+		origins = "[\"*\"]"
+	}
+	content := validYAML + "http:\n" + http + "  cors:\n    allow_origins: " + origins + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config error = %v", err)
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	w, path := newWatcher(t)
+	var got []string
+	w.Subscribe("http", []string{"http.cors"}, func(e Event) error {
+		got = e.New.HTTP.CORS.AllowOrigins
+		return nil
+	})
+
+	writeConfig(t, path, "", "[https://trove.io]")
+	if err := w.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != "https://trove.io" {
+		t.Errorf("subscriber got origins %v, want https://trove.io", got)
+	}
+	if origins := w.Current().HTTP.CORS.AllowOrigins; len(origins) != 1 {
+		t.Errorf("current origins = %v, want reloaded", origins)
+	}
+	if n := testutil.ToFloat64(w.reloads.WithLabelValues(resultSuccess)); n != 1 {
+		t.Errorf("successful reloads = %v, want 1", n)
+	}
+	if n := testutil.ToFloat64(w.info.WithLabelValues(Hash(w.Current()))); n != 1 {
+		t.Errorf("config info of current hash = %v, want 1", n)
+	}
+}
+
+func TestWatcher_Reject(t *testing.T) {
+	tests := []struct {
+		name    string
+		http    string
+		origins string
+		want    error
+		result  string
+	}{
+		{"non reloadable key", "  port: 9999\n", "[https://trove.io]", ErrNotReloadable, resultRejected},
+		{"invalid config", "  port: -1\n", "[https://trove.io]", nil, resultFailure},
+		{"malformed origin", "", "[trove.io]", nil, resultFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, path := newWatcher(t)
+			before, origins := w.Current(), w.src.Origins()
+
+			writeConfig(t, path, tt.http, tt.origins)
+			err := w.Reload(context.Background())
+			if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Fatalf("Reload() error = %v, want %v", err, tt.want)
+			}
+			if w.Current() != before {
+				t.Error("current config changed by a failed reload")
+			}
+			if got := w.src.GetStringSlice("http.cors.allow_origins"); !reflect.DeepEqual(got, []string{"*"}) ||
+				!reflect.DeepEqual(w.src.Origins(), origins) {
+				t.Errorf("source changed by a failed reload, allow_origins = %v, origins = %v", got, w.src.Origins())
+			}
+			if n := testutil.ToFloat64(w.reloads.WithLabelValues(tt.result)); n != 1 {
+				t.Errorf("%s reloads = %v, want 1", tt.result, n)
+			}
+		})
+	}
+}
+
+func TestWatcher_Rollback(t *testing.T) {
+	w, path := newWatcher(t)
+	var applied []string
+	w.Subscribe("first", []string{"http.cors"}, func(e Event) error {
+		applied = append(applied, strings.Join(e.New.HTTP.CORS.AllowOrigins, ","))
+		return nil
+	})
+	w.Subscribe("second", []string{"http.cors"}, func(Event) error {
+		return errors.New("boom")
+	})
+
+	writeConfig(t, path, "", "[https://trove.io]")
+	if err := w.Reload(context.Background()); err == nil {
+		t.Fatal("Reload() error = nil, want subscriber error")
+	}
+	if want := []string{"https://trove.io", "*"}; strings.Join(applied, " ") != strings.Join(want, " ") {
+		t.Errorf("first subscriber applied %v, want %v", applied, want)
+	}
+}
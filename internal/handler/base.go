@@ -1,6 +1,18 @@
 package handler
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// If-Match Errors
+var (
+	ErrInvalidIfMatch = errors.New("If-Match must be a version ETag")
+	ErrOtherIfMatch   = errors.New("If-Match is the ETag of another resource")
+)
 
 // Response Message Constants
 const (
@@ -42,6 +54,38 @@ func (h *BaseHandler) Failed(ctx *fiber.Ctx, code int, err error) error {
 	})
 }
 
+// SetVersion tags the response with the ID and the version of the
+// resource it returns, "<id>-<version>".
+func (h *BaseHandler) SetVersion(ctx *fiber.Ctx, id string, version int64) {
+	ctx.Set(fiber.HeaderETag, strconv.Quote(id+"-"+strconv.FormatInt(version, 10)))
+}
+
+// IfMatch returns the version of the If-Match header of the resource,
+// zero without one or for any version.
+func (h *BaseHandler) IfMatch(ctx *fiber.Ctx, id string) (int64, error) {
+	tag := strings.TrimSpace(ctx.Get(fiber.HeaderIfMatch))
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+	// * If-Match compares strongly, a weak tag never matches.
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, ErrInvalidIfMatch
+	}
+	i := strings.LastIndexByte(unquoted, '-')
+	if i < 0 {
+		return 0, ErrInvalidIfMatch
+	}
+	version, err := strconv.ParseInt(unquoted[i+1:], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalidIfMatch
+	}
+	if unquoted[:i] != id {
+		return 0, ErrOtherIfMatch
+	}
+	return version, nil
+}
+
 // ParseUserID parses the user ID from the context claims
 func (h *BaseHandler) ParseUserID(ctx *fiber.Ctx) string {
 	// 	v, exists := ctx.Get("claims")
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestBaseHandler_IfMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		err    error
+	}{
+		{"", 0, nil},
+		{"*", 0, nil},
+		{`"u-1-3"`, 3, nil},
+		{`"u-2-3"`, 0, ErrOtherIfMatch},
+		{`W/"u-1-3"`, 0, ErrInvalidIfMatch},
+		{`"3"`, 0, ErrInvalidIfMatch},
+		{`"u-1-0"`, 0, ErrInvalidIfMatch},
+	}
+	app := fiber.New()
+	h := NewBaseHandler()
+	for _, tt := range tests {
+		ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+		ctx.Request().Header.Set(fiber.HeaderIfMatch, tt.header)
+		got, err := h.IfMatch(ctx, "u-1")
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("IfMatch(%s) = %d, %v, want %d, %v", tt.header, got, err, tt.want, tt.err)
+		}
+		app.ReleaseCtx(ctx)
+	}
+
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	h.SetVersion(ctx, "u-1", 3)
+	if got := string(ctx.Response().Header.Peek(fiber.HeaderETag)); got != `"u-1-3"` {
+		t.Errorf("SetVersion() ETag = %s, want \"u-1-3\"", got)
+	}
+}
@@ -89,6 +89,7 @@ func (h *UserHandler) GetProfile(ctx *fiber.Ctx) error {
 	if err != nil {
 		return h.Failed(ctx, http.StatusBadRequest, err)
 	}
+	h.SetVersion(ctx, userID, user.Version)
 	return h.Succeed(ctx, user)
 }
 
@@ -100,8 +101,11 @@ func (h *UserHandler) GetProfile(ctx *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Security Bearer
+// @Param If-Match header string false "ETag of the profile read"
 // #@Param request body v1.UpdateProfileRequest true "params"
 // #@Success 200 {object} v1.Response
+// @Failure 409 {object} ServerResponse "changed concurrently"
+// @Failure 412 {object} ServerResponse "changed since the If-Match version"
 // @Router /user [put]
 func (h *UserHandler) UpdateProfile(ctx *fiber.Ctx) error {
 	userID := h.ParseUserID(ctx)
@@ -109,8 +113,23 @@ func (h *UserHandler) UpdateProfile(ctx *fiber.Ctx) error {
 	if err := ctx.BodyParser(&req); err != nil {
 		return h.Failed(ctx, http.StatusBadRequest, err)
 	}
-	if err := h.userService.UpdateProfile(ctx.Context(), userID, &req); err != nil {
+	version, err := h.IfMatch(ctx, userID)
+	switch {
+	case errors.Is(err, ErrOtherIfMatch):
+		return h.Failed(ctx.Status(http.StatusPreconditionFailed), http.StatusPreconditionFailed, err)
+	case err != nil:
 		return h.Failed(ctx, http.StatusBadRequest, err)
 	}
+
+	version, err = h.userService.UpdateProfile(ctx.Context(), userID, version, &req)
+	switch {
+	case errors.Is(err, service.ErrUserConflict) && ctx.Get(fiber.HeaderIfMatch) != "":
+		return h.Failed(ctx.Status(http.StatusPreconditionFailed), http.StatusPreconditionFailed, err)
+	case errors.Is(err, service.ErrUserConflict):
+		return h.Failed(ctx.Status(http.StatusConflict), http.StatusConflict, err)
+	case err != nil:
+		return h.Failed(ctx, http.StatusBadRequest, err)
+	}
+	h.SetVersion(ctx, userID, version)
 	return h.Succeed(ctx, nil)
 }
@@ -0,0 +1,21 @@
+package inject
+
+import (
+	"github.com/kelein/trove-fiber/internal/server"
+	"github.com/kelein/trove-fiber/internal/service"
+	"github.com/kelein/trove-fiber/pkg/app"
+	"github.com/kelein/trove-fiber/pkg/jwt"
+	"github.com/kelein/trove-fiber/pkg/migrate"
+	"github.com/kelein/trove-fiber/pkg/server/http"
+)
+
+// Trove is the object graph shared by the server and the admin commands,
+// so that both run the same service code.
+type Trove struct {
+	App      *app.App
+	HTTP     *http.Server
+	Admin    *server.AdminServer
+	Users    service.UserService
+	JWT      *jwt.JWT
+	Migrator *migrate.Migrator
+}
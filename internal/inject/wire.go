@@ -5,29 +5,56 @@ package inject
 
 import (
 	"github.com/google/wire"
-	"github.com/spf13/viper"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/kelein/trove-fiber/internal/conf"
 	"github.com/kelein/trove-fiber/internal/handler"
+	"github.com/kelein/trove-fiber/internal/metrics"
 	"github.com/kelein/trove-fiber/internal/repository"
 	"github.com/kelein/trove-fiber/internal/server"
 	"github.com/kelein/trove-fiber/internal/service"
 	"github.com/kelein/trove-fiber/pkg/app"
+	"github.com/kelein/trove-fiber/pkg/cache"
+	"github.com/kelein/trove-fiber/pkg/config"
+	"github.com/kelein/trove-fiber/pkg/events"
+	"github.com/kelein/trove-fiber/pkg/health"
+	"github.com/kelein/trove-fiber/pkg/jobs"
 	"github.com/kelein/trove-fiber/pkg/jwt"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
 	"github.com/kelein/trove-fiber/pkg/server/http"
 	"github.com/kelein/trove-fiber/pkg/sid"
 	"github.com/kelein/trove-fiber/pkg/version"
 )
 
 var repositorySet = wire.NewSet(
-	repository.NewDB,
+	repository.NewDatabases,
+	repository.NewUserConn,
+	repository.NewDBMetrics,
+	repository.NewSlowQueries,
 	repository.NewRepository,
 	repository.NewTransaction,
-	repository.NewUserRepository,
+	repository.NewCache,
+	cache.NewMetrics,
+	repository.NewCachedUserRepository,
+	repository.NewOutbox,
+	repository.NewInbox,
+	repository.NewJobStore,
+	repository.NewLocker,
+	repository.NewMigrator,
 )
 
 var serviceSet = wire.NewSet(
+	metrics.NewRecorder,
 	service.NewService,
 	service.NewUserService,
+	service.NewEventBus,
+	wire.Bind(new(events.Publisher), new(*events.Bus)),
+	events.NewMetrics,
+	service.NewRelay,
+	jobs.NewMetrics,
+	service.NewJobQueue,
+	scheduler.NewMetrics,
+	service.NewScheduler,
 )
 
 var handlerSet = wire.NewSet(
@@ -36,24 +63,39 @@ var handlerSet = wire.NewSet(
 )
 
 var serverSet = wire.NewSet(
+	server.NewRegistry,
+	conf.NewWatcher,
+	wire.Bind(new(prometheus.Registerer), new(*prometheus.Registry)),
+	server.NewSLOTracker,
+	server.NewHealth,
 	server.NewHTTPServer,
+	server.NewAdminServer,
 )
 
-func newApp(httpServer *http.Server) *app.App {
+func newJwt(c *conf.Config) *jwt.JWT {
+	return jwt.NewJwt(c.Security.JWT.Key)
+}
+
+func newApp(httpServer *http.Server, adminServer *server.AdminServer,
+	watcher *conf.Watcher, relay *events.Relay, queue *jobs.Queue, sched *scheduler.Scheduler,
+	probes *health.Registry) *app.App {
 	return app.NewApp(
-		app.WithServer(httpServer),
+		app.WithServer(httpServer, adminServer, watcher, relay, queue, sched),
 		app.WithName(version.AppName),
+		app.WithBeforeStop(probes.Shutdown),
+		app.WithReload(watcher.Reload),
 	)
 }
 
-func NewWire(*viper.Viper) (*app.App, func(), error) {
+func NewWire(*config.Source, *conf.Config) (*Trove, func(), error) {
 	panic(wire.Build(
+		wire.Struct(new(Trove), "*"),
 		repositorySet,
 		serviceSet,
 		handlerSet,
 		serverSet,
 		sid.NewSid,
-		jwt.NewJwt,
+		newJwt,
 		newApp,
 	))
 }
@@ -8,47 +8,146 @@ package inject
 
 import (
 	"github.com/google/wire"
+	"github.com/kelein/trove-fiber/internal/conf"
 	"github.com/kelein/trove-fiber/internal/handler"
+	"github.com/kelein/trove-fiber/internal/metrics"
 	"github.com/kelein/trove-fiber/internal/repository"
 	"github.com/kelein/trove-fiber/internal/server"
 	"github.com/kelein/trove-fiber/internal/service"
 	"github.com/kelein/trove-fiber/pkg/app"
+	"github.com/kelein/trove-fiber/pkg/cache"
+	"github.com/kelein/trove-fiber/pkg/config"
+	"github.com/kelein/trove-fiber/pkg/events"
+	"github.com/kelein/trove-fiber/pkg/health"
+	"github.com/kelein/trove-fiber/pkg/jobs"
 	"github.com/kelein/trove-fiber/pkg/jwt"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
 	"github.com/kelein/trove-fiber/pkg/server/http"
 	"github.com/kelein/trove-fiber/pkg/sid"
 	"github.com/kelein/trove-fiber/pkg/version"
-	"github.com/spf13/viper"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Injectors from wire.go:
 
-func NewWire(viperViper *viper.Viper) (*app.App, func(), error) {
-	jwtJWT := jwt.NewJwt(viperViper)
+func NewWire(source *config.Source, confConfig *conf.Config) (*Trove, func(), error) {
+	registry := server.NewRegistry()
+	watcher, err := conf.NewWatcher(source, confConfig, registry)
+	if err != nil {
+		return nil, nil, err
+	}
+	tracker, err := server.NewSLOTracker(confConfig, registry)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbMetrics := repository.NewDBMetrics(registry)
+	slowQueries := repository.NewSlowQueries(registry)
+	databases, cleanup, err := repository.NewDatabases(confConfig, dbMetrics, slowQueries)
+	if err != nil {
+		return nil, nil, err
+	}
+	cacheCache, cleanup2, err := repository.NewCache(confConfig, registry)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	healthRegistry, err := server.NewHealth(confConfig, databases, cacheCache)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	jwt := newJwt(confConfig)
 	baseHandler := handler.NewBaseHandler()
 	sidSid := sid.NewSid()
-	db := repository.NewDB(viperViper)
-	repositoryRepository := repository.NewRepository(db)
+	conn, err := repository.NewUserConn(databases)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	repositoryRepository := repository.NewRepository(conn)
 	transaction := repository.NewTransaction(repositoryRepository)
-	serviceService := service.NewService(sidSid, jwtJWT, transaction)
-	userRepository := repository.NewUserRepository(repositoryRepository)
-	userService := service.NewUserService(serviceService, userRepository)
+	recorder := metrics.NewRecorder(registry)
+	serviceService := service.NewService(sidSid, jwt, transaction, recorder)
+	cacheMetrics := cache.NewMetrics(registry)
+	userRepository := repository.NewCachedUserRepository(repositoryRepository, confConfig, cacheCache, cacheMetrics)
+	outbox := repository.NewOutbox(repositoryRepository)
+	userService := service.NewUserService(serviceService, userRepository, outbox)
 	userHandler := handler.NewUserHandler(baseHandler, userService)
-	httpServer := server.NewHTTPServer(viperViper, jwtJWT, userHandler)
-	appApp := newApp(httpServer)
-	return appApp, func() {
+	httpServer, err := server.NewHTTPServer(confConfig, watcher, registry, tracker, healthRegistry, jwt, userHandler)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	store := repository.NewJobStore(repositoryRepository)
+	jobsMetrics := jobs.NewMetrics(registry)
+	queue := service.NewJobQueue(confConfig, store, jobsMetrics)
+	locker, cleanup3, err := repository.NewLocker(confConfig, repositoryRepository, registry)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	schedulerMetrics := scheduler.NewMetrics(registry)
+	schedulerScheduler, err := service.NewScheduler(confConfig, locker, userRepository, schedulerMetrics)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	adminServer, err := server.NewAdminServer(confConfig, watcher, registry, tracker, healthRegistry, slowQueries, queue, schedulerScheduler, httpServer)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	inbox := repository.NewInbox(repositoryRepository)
+	bus := service.NewEventBus(inbox, queue)
+	eventsMetrics := events.NewMetrics(registry)
+	relay := service.NewRelay(confConfig, outbox, bus, eventsMetrics)
+	app := newApp(httpServer, adminServer, watcher, relay, queue, schedulerScheduler, healthRegistry)
+	migrator, err := repository.NewMigrator(conn)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	trove := &Trove{
+		App:      app,
+		HTTP:     httpServer,
+		Admin:    adminServer,
+		Users:    userService,
+		JWT:      jwt,
+		Migrator: migrator,
+	}
+	return trove, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
 	}, nil
 }
 
 // wire.go:
 
-var repositorySet = wire.NewSet(repository.NewDB, repository.NewRepository, repository.NewTransaction, repository.NewUserRepository)
+var repositorySet = wire.NewSet(repository.NewDatabases, repository.NewUserConn, repository.NewDBMetrics, repository.NewSlowQueries, repository.NewRepository, repository.NewTransaction, repository.NewCache, cache.NewMetrics, repository.NewCachedUserRepository, repository.NewOutbox, repository.NewInbox, repository.NewJobStore, repository.NewLocker, repository.NewMigrator)
 
-var serviceSet = wire.NewSet(service.NewService, service.NewUserService)
+var serviceSet = wire.NewSet(metrics.NewRecorder, service.NewService, service.NewUserService, service.NewEventBus, wire.Bind(new(events.Publisher), new(*events.Bus)), events.NewMetrics, service.NewRelay, jobs.NewMetrics, service.NewJobQueue, scheduler.NewMetrics, service.NewScheduler)
 
 var handlerSet = wire.NewSet(handler.NewBaseHandler, handler.NewUserHandler)
 
-var serverSet = wire.NewSet(server.NewHTTPServer)
+var serverSet = wire.NewSet(server.NewRegistry, conf.NewWatcher, wire.Bind(new(prometheus.Registerer), new(*prometheus.Registry)), server.NewSLOTracker, server.NewHealth, server.NewHTTPServer, server.NewAdminServer)
 
-func newApp(httpServer *http.Server) *app.App {
-	return app.NewApp(app.WithServer(httpServer), app.WithName(version.AppName))
+func newJwt(c *conf.Config) *jwt.JWT {
+	return jwt.NewJwt(c.Security.JWT.Key)
+}
+
+func newApp(httpServer *http.Server, adminServer *server.AdminServer,
+	watcher *conf.Watcher, relay *events.Relay, queue *jobs.Queue, sched *scheduler.Scheduler,
+	probes *health.Registry) *app.App {
+	return app.NewApp(app.WithServer(httpServer, adminServer, watcher, relay, queue, sched), app.WithName(version.AppName), app.WithBeforeStop(probes.Shutdown), app.WithReload(watcher.Reload))
 }
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// Login Failure Reasons
+const (
+	ReasonUserNotFound = "user_not_found"
+	ReasonBadPassword  = "bad_password"
+	ReasonTokenError   = "token_error"
+	ReasonUserDisabled = "user_disabled"
+)
+
+// Recorder records business level events of the service layer
+type Recorder interface {
+	UserRegistered()
+	LoginSucceeded()
+	LoginFailed(reason string)
+	ProfileUpdated()
+}
+
+// NewRecorder creates a Recorder exporting prometheus counters
+func NewRecorder(reg prometheus.Registerer) Recorder {
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: version.Namespace(),
+			Subsystem: "user",
+			Name:      name,
+			Help:      help,
+		})
+	}
+
+	r := &promRecorder{
+		registrations: counter("registrations_total", "How many users registered."),
+		logins:        counter("login_success_total", "How many logins succeeded."),
+		updates:       counter("profile_updates_total", "How many user profiles updated."),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: version.Namespace(),
+			Subsystem: "user",
+			Name:      "login_failures_total",
+			Help:      "How many logins failed, with label reason.",
+		}, []string{"reason"}),
+	}
+	reg.MustRegister(r.registrations, r.logins, r.updates, r.failures)
+	return r
+}
+
+type promRecorder struct {
+	registrations prometheus.Counter
+	logins        prometheus.Counter
+	updates       prometheus.Counter
+	failures      *prometheus.CounterVec
+}
+
+func (r *promRecorder) UserRegistered()           { r.registrations.Inc() }
+func (r *promRecorder) LoginSucceeded()           { r.logins.Inc() }
+func (r *promRecorder) LoginFailed(reason string) { r.failures.WithLabelValues(reason).Inc() }
+func (r *promRecorder) ProfileUpdated()           { r.updates.Inc() }
+
+// NewNopRecorder creates a Recorder dropping all events
+func NewNopRecorder() Recorder { return nopRecorder{} }
+
+type nopRecorder struct{}
+
+func (nopRecorder) UserRegistered()    {}
+func (nopRecorder) LoginSucceeded()    {}
+func (nopRecorder) LoginFailed(string) {}
+func (nopRecorder) ProfileUpdated()    {}
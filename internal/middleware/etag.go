@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
+)
+
+// ETag tags the responses by a hash of the body, unless the handler has
+// tagged it with the version of the resource. A GET matching either tag
+// by If-None-Match gets 304 Not Modified. The tags vary by Authorization,
+// the same resource reads differently for every account.
+func ETag() fiber.Handler {
+	hash := etag.New()
+	return func(ctx *fiber.Ctx) error {
+		if err := hash(ctx); err != nil {
+			return err
+		}
+		// * The hash middleware answers 304 without the tag.
+		if ctx.Response().StatusCode() == fiber.StatusNotModified || len(ctx.Response().Header.Peek(fiber.HeaderETag)) > 0 {
+			ctx.Vary(fiber.HeaderAuthorization)
+		}
+		// * The hash middleware answers the tags it computes only.
+		if ctx.Response().StatusCode() != fiber.StatusOK {
+			return nil
+		}
+		if ctx.Method() != fiber.MethodGet && ctx.Method() != fiber.MethodHead {
+			return nil
+		}
+		tag := string(ctx.Response().Header.Peek(fiber.HeaderETag))
+		if tag == "" || !noneMatch(ctx.Get(fiber.HeaderIfNoneMatch), tag) {
+			return nil
+		}
+		ctx.Context().ResetBody()
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+}
+
+// noneMatch reports whether the If-None-Match list has the tag,
+// comparing weakly.
+func noneMatch(header, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
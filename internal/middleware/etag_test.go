@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestETag(t *testing.T) {
+	app := fiber.New()
+	app.Use(ETag())
+	app.Get("/hashed", func(ctx *fiber.Ctx) error { return ctx.SendString("body") })
+	app.Get("/versioned", func(ctx *fiber.Ctx) error {
+		ctx.Set(fiber.HeaderETag, `"u1-3"`)
+		return ctx.SendString("body")
+	})
+	app.Put("/versioned", func(ctx *fiber.Ctx) error {
+		ctx.Set(fiber.HeaderETag, `"u1-4"`)
+		return ctx.SendString("body")
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		ifNoneMatch string
+		status      int
+		etag        string
+	}{
+		{"hashed", fiber.MethodGet, "/hashed", "", fiber.StatusOK, `"4-3554089878"`},
+		{"hashed match", fiber.MethodGet, "/hashed", `"4-3554089878"`, fiber.StatusNotModified, ""},
+		{"versioned", fiber.MethodGet, "/versioned", "", fiber.StatusOK, `"u1-3"`},
+		{"versioned match", fiber.MethodGet, "/versioned", `"u2-3", W/"u1-3"`, fiber.StatusNotModified, `"u1-3"`},
+		{"versioned stale", fiber.MethodGet, "/versioned", `"u1-2"`, fiber.StatusOK, `"u1-3"`},
+		{"versioned other", fiber.MethodGet, "/versioned", `"u2-3"`, fiber.StatusOK, `"u1-3"`},
+		{"versioned write", fiber.MethodPut, "/versioned", `"u1-4"`, fiber.StatusOK, `"u1-4"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set(fiber.HeaderIfNoneMatch, tt.ifNoneMatch)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Test() error = %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderETag); tt.etag != "" && got != tt.etag {
+				t.Errorf("ETag = %s, want %s", got, tt.etag)
+			}
+			if got := resp.Header.Get(fiber.HeaderVary); got != fiber.HeaderAuthorization {
+				t.Errorf("Vary = %q, want %s", got, fiber.HeaderAuthorization)
+			}
+		})
+	}
+}
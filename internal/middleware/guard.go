@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GuardConfig protects operational endpoints
+type GuardConfig struct {
+	// AllowIPs lists the IPs or CIDRs allowed to connect,
+	// only loopback is allowed when neither IPs nor credentials are set.
+	AllowIPs []string
+	// Username and Password enable basic authentication
+	Username string
+	Password string
+	// Token enables bearer token authentication
+	Token string
+}
+
+// Guard rejects requests not matching the IP allowlist or the credentials
+func Guard(conf GuardConfig) (fiber.Handler, error) {
+	allowIPs := conf.AllowIPs
+	hasCredentials := conf.Token != "" || (conf.Username != "" && conf.Password != "")
+	if len(allowIPs) == 0 && !hasCredentials {
+		allowIPs = []string{"127.0.0.0/8", "::1/128"}
+	}
+
+	nets := make([]*net.IPNet, 0, len(allowIPs))
+	for _, s := range allowIPs {
+		if !strings.Contains(s, "/") {
+			if strings.Contains(s, ":") {
+				s += "/128"
+			} else {
+				s += "/32"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipnet)
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		if len(nets) > 0 && !containsIP(nets, net.ParseIP(ctx.IP())) {
+			return fiber.ErrForbidden
+		}
+		if hasCredentials && !authorized(ctx, conf) {
+			ctx.Set(fiber.HeaderWWWAuthenticate, `Basic realm="admin"`)
+			return fiber.ErrUnauthorized
+		}
+		return ctx.Next()
+	}, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func authorized(ctx *fiber.Ctx, conf GuardConfig) bool {
+	auth := ctx.Get(fiber.HeaderAuthorization)
+	switch {
+	case conf.Token != "" && strings.HasPrefix(auth, "Bearer "):
+		return secureEqual(strings.TrimPrefix(auth, "Bearer "), conf.Token)
+
+	case conf.Username != "" && strings.HasPrefix(auth, "Basic "):
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if err != nil {
+			return false
+		}
+		username, password, ok := strings.Cut(string(raw), ":")
+		return ok && secureEqual(username, conf.Username) && secureEqual(password, conf.Password)
+	}
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestGuard(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   GuardConfig
+		auth   string
+		status int
+	}{
+		{"loopback only by default", GuardConfig{}, "", fiber.StatusForbidden},
+		{"allowed ip", GuardConfig{AllowIPs: []string{"0.0.0.0"}}, "", fiber.StatusOK},
+		{"denied cidr", GuardConfig{AllowIPs: []string{"10.0.0.0/8"}}, "", fiber.StatusForbidden},
+		{"missing token", GuardConfig{Token: "s3cret"}, "", fiber.StatusUnauthorized},
+		{"wrong token", GuardConfig{Token: "s3cret"}, "Bearer nope", fiber.StatusUnauthorized},
+		{"valid token", GuardConfig{Token: "s3cret"}, "Bearer s3cret", fiber.StatusOK},
+		{"valid basic auth", GuardConfig{Username: "ops", Password: "pw"}, "Basic b3BzOnB3", fiber.StatusOK},
+		{"wrong basic auth", GuardConfig{Username: "ops", Password: "pw"}, "Basic b3BzOnh4", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard, err := Guard(tt.conf)
+			if err != nil {
+				t.Fatalf("Guard() error = %v", err)
+			}
+			app := fiber.New()
+			app.Use(guard)
+			app.Get("/", func(ctx *fiber.Ctx) error { return ctx.SendStatus(fiber.StatusOK) })
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.auth != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.auth)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestGuard_InvalidCIDR(t *testing.T) {
+	if _, err := Guard(GuardConfig{AllowIPs: []string{"10.0.0.0/99"}}); err == nil {
+		t.Error("Guard() error = nil, want invalid CIDR error")
+	}
+}
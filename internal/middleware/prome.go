@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,67 +13,190 @@ import (
 )
 
 // DefaultBuckets prometheus buckets in seconds
-var DefaultBuckets = []float64{0.5, 1.0, 5.0}
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets prometheus buckets in bytes
+var DefaultSizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
 
 const (
-	requestMetricName = "http_request_total"
-	latencyMetricName = "http_request_seconds"
+	requestMetricName  = "http_request_total"
+	latencyMetricName  = "http_request_seconds"
+	inflightMetricName = "http_requests_in_flight"
+	reqSizeMetricName  = "http_request_size_bytes"
+	respSizeMetricName = "http_response_size_bytes"
 )
 
+// unmatchedRoute labels requests which no route handled,
+// it keeps unknown paths from creating new series.
+const unmatchedRoute = "NOT_FOUND"
+
 // Metrics in prometheus defination
 type Metrics struct {
-	reqs    *prometheus.CounterVec
-	latency *prometheus.HistogramVec
+	reqs     *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inflight prometheus.Gauge
+	reqSize  *prometheus.HistogramVec
+	respSize *prometheus.HistogramVec
+	skips    map[string]struct{}
+}
+
+type promeOptions struct {
+	buckets      []float64
+	sizeBuckets  []float64
+	nativeFactor float64
+	skipPaths    []string
+}
+
+// PromeOption setup the prometheus middleware
+type PromeOption func(o *promeOptions)
+
+// WithBuckets setup latency histogram buckets in seconds
+func WithBuckets(buckets []float64) PromeOption {
+	return func(o *promeOptions) {
+		if len(buckets) > 0 {
+			o.buckets = buckets
+		}
+	}
+}
+
+// WithSizeBuckets setup request and response size buckets in bytes
+func WithSizeBuckets(buckets []float64) PromeOption {
+	return func(o *promeOptions) {
+		if len(buckets) > 0 {
+			o.sizeBuckets = buckets
+		}
+	}
 }
 
-// NewProme returns a new prometheus middleware of Fiber
-func NewProme(app *fiber.App, jobName, metricsPath string) *Metrics {
-	m := Metrics{}
+// WithNativeHistogram enables native histograms with the given bucket factor,
+// the classic buckets are still exposed for scrapers without native support.
+func WithNativeHistogram(factor float64) PromeOption {
+	return func(o *promeOptions) { o.nativeFactor = factor }
+}
+
+// WithSkipPaths excludes the given paths from being measured
+func WithSkipPaths(paths ...string) PromeOption {
+	return func(o *promeOptions) { o.skipPaths = append(o.skipPaths, paths...) }
+}
+
+// NewProme returns a new prometheus middleware of Fiber,
+// all metrics are registered on the given registerer.
+func NewProme(reg prometheus.Registerer, jobName string, opts ...PromeOption) *Metrics {
+	o := &promeOptions{buckets: DefaultBuckets, sizeBuckets: DefaultSizeBuckets}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	namespace := strings.ReplaceAll(jobName, "-", "_")
+	labels := prometheus.Labels{"job": namespace}
+	histogram := func(name, help string, buckets []float64) *prometheus.HistogramVec {
+		ho := prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        name,
+			Help:        help,
+			ConstLabels: labels,
+			Buckets:     buckets,
+		}
+		if o.nativeFactor > 1 {
+			ho.NativeHistogramBucketFactor = o.nativeFactor
+			ho.NativeHistogramMaxBucketNumber = 160
+			ho.NativeHistogramMinResetDuration = time.Hour
+		}
+		return prometheus.NewHistogramVec(ho, []string{"method", "path", "code"})
+	}
+
+	m := &Metrics{skips: make(map[string]struct{}, len(o.skipPaths))}
+	for _, p := range o.skipPaths {
+		m.skips[p] = struct{}{}
+	}
+
 	m.reqs = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace:   jobName,
+		Namespace:   namespace,
 		Name:        requestMetricName,
-		Help:        "How many http requests processed, with labels status code, method and path.",
-		ConstLabels: prometheus.Labels{"job": jobName},
+		Help:        "How many http requests processed, with labels status code, method and route.",
+		ConstLabels: labels,
 	},
 		[]string{"method", "path", "code"},
 	)
-
-	m.latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Namespace:   jobName,
-		Name:        latencyMetricName,
-		Help:        "How long it took to process the request, with labels status code, method and path.",
-		ConstLabels: prometheus.Labels{"job": jobName},
-		Buckets:     DefaultBuckets,
-	},
-		[]string{"method", "path", "code"},
-	)
-
-	prometheus.MustRegister(m.reqs, m.latency)
-
-	// * Register metrics router of the middleware
-	app.Get(metricsPath, func(ctx *fiber.Ctx) error {
-		handler := fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
-		handler(ctx.Context())
-		return nil
+	m.inflight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        inflightMetricName,
+		Help:        "How many http requests are currently being processed.",
+		ConstLabels: labels,
 	})
+	m.latency = histogram(latencyMetricName,
+		"How long it took to process the request, with labels status code, method and route.",
+		o.buckets)
+	m.reqSize = histogram(reqSizeMetricName,
+		"How large the http requests are, with labels status code, method and route.",
+		o.sizeBuckets)
+	m.respSize = histogram(respSizeMetricName,
+		"How large the http responses are, with labels status code, method and route.",
+		o.sizeBuckets)
 
-	return &m
+	reg.MustRegister(m.reqs, m.inflight, m.latency, m.reqSize, m.respSize)
+	return m
 }
 
 // Run start prometheus middleware with context
 func (m *Metrics) Run() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		if ctx.Path() == "/metrics" {
+		if _, ok := m.skips[ctx.Path()]; ok {
 			return ctx.Next()
 		}
 
 		start := time.Now()
-		lvs := []string{ctx.Method(), ctx.Path(), strconv.Itoa(ctx.Response().StatusCode())}
+		m.inflight.Inc()
+		defer m.inflight.Dec()
 
 		err := ctx.Next()
 
+		// * Labels resolved after the chain ran, so that the route
+		// * and the status code are the ones actually served.
+		code := statusCode(ctx, err)
+		route := ctx.Route().Path
+		if code == fiber.StatusNotFound && err != nil {
+			route = unmatchedRoute
+		}
+		lvs := []string{ctx.Method(), route, strconv.Itoa(code)}
+
 		m.reqs.WithLabelValues(lvs...).Inc()
-		m.latency.WithLabelValues(lvs...).Observe(float64(time.Since(start).Nanoseconds()) / 1e9)
+		m.latency.WithLabelValues(lvs...).Observe(time.Since(start).Seconds())
+		m.reqSize.WithLabelValues(lvs...).Observe(float64(requestSize(ctx)))
+		m.respSize.WithLabelValues(lvs...).Observe(float64(len(ctx.Response().Body())))
 		return err
 	}
 }
+
+// PromeHandler serves the metrics gathered by the given gatherer
+func PromeHandler(gatherer prometheus.Gatherer) fiber.Handler {
+	handler := fasthttpadaptor.NewFastHTTPHandler(
+		promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}),
+	)
+	return func(ctx *fiber.Ctx) error {
+		handler(ctx.Context())
+		return nil
+	}
+}
+
+// statusCode resolves the status code the error handler will respond with
+func statusCode(ctx *fiber.Ctx, err error) int {
+	if err == nil {
+		return ctx.Response().StatusCode()
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+func requestSize(ctx *fiber.Ctx) int {
+	size := len(ctx.Request().Header.Header())
+	if n := ctx.Request().Header.ContentLength(); n > 0 {
+		return size + n
+	}
+	return size + len(ctx.Request().Body())
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newPromeApp(reg *prometheus.Registry) (*fiber.App, *Metrics) {
+	app := fiber.New()
+	prome := NewProme(reg, "trove-test", WithSkipPaths("/metrics"))
+	app.Use(prome.Run())
+	app.Get("/metrics", PromeHandler(reg))
+	app.Get("/users/:id", func(ctx *fiber.Ctx) error { return ctx.SendString(ctx.Params("id")) })
+	app.Get("/teapot", func(ctx *fiber.Ctx) error { return fiber.ErrTeapot })
+	return app, prome
+}
+
+func TestMetrics_Labels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	app, prome := newPromeApp(reg)
+
+	for _, path := range []string{"/users/1", "/users/2", "/teapot", "/nowhere", "/metrics"} {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil)); err != nil {
+			t.Fatalf("request %s error = %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"route template", []string{"GET", "/users/:id", "200"}, 2},
+		{"error status code", []string{"GET", "/teapot", "418"}, 1},
+		{"unmatched route", []string{"GET", unmatchedRoute, "404"}, 1},
+		{"skipped path", []string{"GET", "/metrics", "200"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testutil.ToFloat64(prome.reqs.WithLabelValues(tt.labels...))
+			if got != tt.want {
+				t.Errorf("requests %v = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetrics_Registries(t *testing.T) {
+	// * Each app instance owns its registry, so building twice must not panic.
+	for range 2 {
+		reg := prometheus.NewRegistry()
+		app, _ := newPromeApp(reg)
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/users/1", nil)); err != nil {
+			t.Fatalf("request error = %v", err)
+		}
+
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/metrics", nil))
+		if err != nil {
+			t.Fatalf("scrape error = %v", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read scrape error = %v", err)
+		}
+		for _, name := range []string{
+			"trove_test_http_request_total",
+			"trove_test_http_requests_in_flight",
+			"trove_test_http_request_size_bytes",
+			"trove_test_http_response_size_bytes",
+		} {
+			if !strings.Contains(string(body), name) {
+				t.Errorf("scrape output missing %s", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kelein/trove-fiber/internal/slo"
+)
+
+// SLO records request outcomes of the route groups tracked by the tracker
+func SLO(tracker *slo.Tracker) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		err := ctx.Next()
+
+		group, ok := tracker.Group(ctx.Route().Path)
+		if !ok {
+			return err
+		}
+		failed := statusCode(ctx, err) >= fiber.StatusInternalServerError
+		tracker.Record(group, failed, time.Since(start))
+		return err
+	}
+}
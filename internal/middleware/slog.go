@@ -5,14 +5,20 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/kelein/trove-fiber/pkg/log"
 )
 
+// HeaderDebugLog carries a signed token forcing debug logs of a request
+const HeaderDebugLog = "X-Debug-Log"
+
 // Slogger stands for a logger middleware of Fiber
 func Slogger() fiber.Handler {
+	logger := log.Named(log.ModuleHTTP)
 	return func(ctx *fiber.Ctx) error {
 		start := time.Now()
 		err := ctx.Next()
-		slog.Info("HTTP Request",
+		logger.InfoContext(ctx.Context(), "HTTP Request",
 			slog.String("method", ctx.Method()),
 			slog.String("path", ctx.Path()),
 			slog.Int("code", ctx.Response().StatusCode()),
@@ -23,3 +29,16 @@ func Slogger() fiber.Handler {
 		return err
 	}
 }
+
+// DebugLog forces debug logs of requests carrying a valid signed header,
+// the token is minted by log.SignDebugToken with the same key.
+func DebugLog(key []byte) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		token := ctx.Get(HeaderDebugLog)
+		if token != "" && log.VerifyDebugToken(key, token) {
+			ctx.Locals(log.ForceDebugKey{}, true)
+			ctx.SetUserContext(log.WithForceDebug(ctx.UserContext()))
+		}
+		return ctx.Next()
+	}
+}
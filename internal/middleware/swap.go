@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Swappable is a middleware whose handler is replaced at runtime,
+// it lets reloaded config rebuild middlewares of a running router.
+type Swappable struct {
+	handler atomic.Pointer[fiber.Handler]
+}
+
+// NewSwappable creates a new Swappable serving the given handler
+func NewSwappable(h fiber.Handler) *Swappable {
+	s := new(Swappable)
+	s.Swap(h)
+	return s
+}
+
+// Swap replaces the handler, requests in flight finish on the old one
+func (s *Swappable) Swap(h fiber.Handler) {
+	s.handler.Store(&h)
+}
+
+// Run returns the middleware handler
+func (s *Swappable) Run() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return (*s.handler.Load())(ctx)
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kelein/trove-fiber/internal/repository"
+)
+
+// ReadYourWrites records the database writes of each request, so that
+// its reads stay on the primary for the sticky window after a write.
+func ReadYourWrites() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		ctx.Locals(repository.WritesKey{}, repository.NewWrites())
+		return ctx.Next()
+	}
+}
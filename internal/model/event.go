@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+)
+
+// Event Types
+const (
+	EventUserRegistered = "user.registered"
+)
+
+// Outbox Statuses
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead"
+)
+
+// UserRegistered is the payload of EventUserRegistered
+type UserRegistered struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// OutboxEvent mapped from table <outbox>, a domain event recorded by the
+// transaction of its change until the relay delivers it.
+type OutboxEvent struct {
+	ID          int64      `gorm:"column:id;primaryKey" json:"id"`
+	Type        string     `gorm:"column:type;not null" json:"type"`
+	Key         string     `gorm:"column:event_key;not null" json:"key"`
+	Payload     string     `gorm:"column:payload;not null" json:"payload"`
+	Status      string     `gorm:"column:status;not null;default:pending;index:idx_outbox_status" json:"status"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	LastError   string     `gorm:"column:last_error;not null;default:''" json:"last_error"`
+	RetryAt     time.Time  `gorm:"column:retry_at;not null" json:"retry_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at;not null" json:"created_at"`
+	DeliveredAt *time.Time `gorm:"column:delivered_at" json:"delivered_at"`
+}
+
+// TableName returns the table name for the OutboxEvent model
+func (e *OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// ProcessedEvent mapped from table <processed_events>, an outbox event a
+// consumer handled already.
+type ProcessedEvent struct {
+	Consumer    string    `gorm:"column:consumer;primaryKey" json:"consumer"`
+	EventID     int64     `gorm:"column:event_id;primaryKey;autoIncrement:false" json:"event_id"`
+	ProcessedAt time.Time `gorm:"column:processed_at;not null" json:"processed_at"`
+}
+
+// TableName returns the table name for the ProcessedEvent model
+func (e *ProcessedEvent) TableName() string {
+	return "processed_events"
+}
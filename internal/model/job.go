@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// Job mapped from table <jobs>, a background job of the queue. The unique
+// key of a job is cleared once it finishes, a job of the same key may be
+// queued again.
+type Job struct {
+	ID          int64      `gorm:"column:id;primaryKey" json:"id"`
+	Type        string     `gorm:"column:type;not null" json:"type"`
+	Payload     string     `gorm:"column:payload;not null" json:"payload"`
+	UniqueKey   *string    `gorm:"column:unique_key;uniqueIndex:uni_jobs_unique_key" json:"unique_key"`
+	Priority    int        `gorm:"column:priority;not null;default:0" json:"priority"`
+	Status      string     `gorm:"column:status;not null;default:pending;index:idx_jobs_due,priority:1" json:"status"`
+	RunAt       time.Time  `gorm:"column:run_at;not null;index:idx_jobs_due,priority:2" json:"run_at"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"column:max_attempts;not null" json:"max_attempts"`
+	LastError   string     `gorm:"column:last_error;not null;default:''" json:"last_error"`
+	LockedBy    string     `gorm:"column:locked_by;not null;default:''" json:"locked_by"`
+	LockedUntil *time.Time `gorm:"column:locked_until" json:"locked_until"`
+	CreatedAt   time.Time  `gorm:"column:created_at;not null" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;not null" json:"updated_at"`
+	FinishedAt  *time.Time `gorm:"column:finished_at" json:"finished_at"`
+}
+
+// TableName returns the table name for the Job model
+func (j *Job) TableName() string {
+	return "jobs"
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+// Lock mapped from table <locks>, a named lock held by its owner until it
+// expires.
+type Lock struct {
+	Name      string    `gorm:"column:name;primaryKey" json:"name"`
+	Owner     string    `gorm:"column:owner;not null" json:"owner"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null" json:"expires_at"`
+}
+
+// TableName returns the table name for the Lock model
+func (l *Lock) TableName() string {
+	return "locks"
+}
@@ -6,19 +6,43 @@ import (
 	"gorm.io/gorm"
 )
 
-// User mapped from table <users>
+// User Roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// User Statuses
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
+// Roles lists all user roles
+var Roles = []string{RoleUser, RoleAdmin}
+
+// User mapped from table <users>, the password hash is only written
+// on create.
 type User struct {
 	ID        int32          `gorm:"column:id;primaryKey" json:"id"`
-	UserID    string         `gorm:"column:user_id;not null" json:"user_id"`
+	UserID    string         `gorm:"column:user_id;not null;unique" json:"user_id"`
 	Nickname  string         `gorm:"column:nickname;not null" json:"nickname"`
-	Password  string         `gorm:"column:password;not null" json:"password"`
+	Password  string         `gorm:"column:password;not null;<-:create" json:"password"`
 	Email     string         `gorm:"column:email;not null" json:"email"`
+	Role      string         `gorm:"column:role;not null;default:user" json:"role"`
+	Status    string         `gorm:"column:status;not null;default:active" json:"status"`
+	Version   int64          `gorm:"column:version;not null;default:1" json:"version"`
 	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt time.Time      `gorm:"column:updated_at" json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at" json:"deleted_at"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deleted_at"`
 }
 
 // TableName returns the table name for the User model
 func (u *User) TableName() string {
 	return "users"
 }
+
+// Disabled reports whether the user is locked out
+func (u *User) Disabled() bool {
+	return u.Status == StatusDisabled
+}
@@ -0,0 +1,436 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/pkg/log"
+)
+
+// Databases holds the connections of every data.db entry by name
+type Databases struct {
+	conns map[string]*Conn
+}
+
+// NewDatabases opens every named database connection of the config,
+// the cleanup closes their pools.
+func NewDatabases(c *conf.Config, metrics *DBMetrics, slow *SlowQueries) (*Databases, func(), error) {
+	d := &Databases{conns: make(map[string]*Conn, len(c.Data.DB))}
+	for name, db := range c.Data.DB {
+		conn, err := openConn(name, db, metrics, slow)
+		if err != nil {
+			d.Close()
+			return nil, nil, fmt.Errorf("open database %s: %w", name, err)
+		}
+		d.conns[name] = conn
+	}
+	cleanup := func() {
+		if err := d.Close(); err != nil {
+			slog.Error("close databases failed", "error", err)
+		}
+	}
+	return d, cleanup, nil
+}
+
+// NewUserConn returns the user database connection
+func NewUserConn(d *Databases) (*Conn, error) {
+	return d.Get(conf.DBUser)
+}
+
+// Get returns the named connection
+func (d *Databases) Get(name string) (*Conn, error) {
+	conn, ok := d.conns[name]
+	if !ok {
+		return nil, fmt.Errorf("database %s is not configured", name)
+	}
+	return conn, nil
+}
+
+// Names returns the sorted connection names
+func (d *Databases) Names() []string {
+	names := make([]string, 0, len(d.conns))
+	for name := range d.conns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Close closes the pools of every connection
+func (d *Databases) Close() error {
+	var errs []error
+	for _, conn := range d.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Conn is a named database connection, the writes go to the primary and
+// the reads to the replicas by the policy. A request reads from the
+// primary for the sticky window after its last write.
+type Conn struct {
+	Name string
+
+	db       *gorm.DB
+	primary  *gorm.DB
+	sqlDB    *sql.DB
+	replicas []*sql.DB
+	sticky   time.Duration
+}
+
+// DB returns the connection routing the reads to the replicas
+func (c *Conn) DB() *gorm.DB { return c.db }
+
+// Primary returns the connection never routed to the replicas,
+// both share the pool of the primary.
+func (c *Conn) Primary() *gorm.DB { return c.primary }
+
+// SQL returns the pool of the primary
+func (c *Conn) SQL() *sql.DB { return c.sqlDB }
+
+// Replicas returns the pools of the replicas
+func (c *Conn) Replicas() []*sql.DB { return c.replicas }
+
+// Close closes the pools of the primary and the replicas
+func (c *Conn) Close() error {
+	var errs []error
+	for _, db := range append([]*sql.DB{c.sqlDB}, c.replicas...) {
+		if db != nil {
+			errs = append(errs, db.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Session returns the connection for the context, it is pinned to the
+// primary within the sticky window after a write of the request.
+func (c *Conn) Session(ctx context.Context) *gorm.DB {
+	if len(c.replicas) > 0 && c.sticky > 0 {
+		if w := writesFrom(ctx); w != nil && w.within(c.Name, c.sticky) {
+			return c.primary.WithContext(ctx)
+		}
+	}
+	return c.db.WithContext(ctx)
+}
+
+func openConn(name string, c conf.Database, metrics *DBMetrics, slow *SlowQueries) (conn *Conn, err error) {
+	option, err := c.Log.Option()
+	if err != nil {
+		return nil, err
+	}
+	// * Every gorm.DB gets its own config, it holds the plugins.
+	logger := log.NewOrmlogger(nil, option).OnSlow(slow.Hook(name))
+	gormConf := func() *gorm.Config {
+		return &gorm.Config{DisableAutomaticPing: false, Logger: logger}
+	}
+
+	primary, err := openPool(name, c, c.DSN, gormConf())
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := primary.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn = &Conn{Name: name, db: primary, primary: primary, sqlDB: sqlDB, sticky: c.StickyWindow}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	if err = metrics.Watch(name, sqlDB); err != nil {
+		return nil, err
+	}
+	if err = usePlugins(primary, metrics.Plugin(name), &writesPlugin{name: name}); err != nil {
+		return nil, err
+	}
+	if len(c.Replicas) == 0 {
+		return conn, nil
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(c.Replicas))
+	for i, replica := range c.Replicas {
+		replicaName := fmt.Sprintf("%s:replica%d", name, i)
+		db, err := openPool(replicaName, c, replica.DSN, gormConf())
+		if err != nil {
+			return nil, err
+		}
+		replicaDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		conn.replicas = append(conn.replicas, replicaDB)
+		if err = metrics.Watch(replicaName, replicaDB); err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, db.Dialector)
+	}
+
+	// * The routed connection shares the pool of the primary, the primary
+	// * one stays unrouted for transactions, migrations and pinned reads.
+	if conn.db, err = gorm.Open(primary.Dialector, gormConf()); err != nil {
+		return nil, err
+	}
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: newPolicy(c.Policy)})
+	if err = usePlugins(conn.db, metrics.Plugin(name), &writesPlugin{name: name}, resolver); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// openPool opens the pool of a database with the driver settings, it
+// waits for the database to answer before opening the gorm.DB on it.
+func openPool(name string, c conf.Database, dsn string, gormConf *gorm.Config) (*gorm.DB, error) {
+	sqlDB, err := openSQL(c, dsn)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(c.Pool.MaxOpen)
+	sqlDB.SetMaxIdleConns(c.Pool.MaxIdle)
+	sqlDB.SetConnMaxLifetime(c.Pool.MaxLifetime)
+	sqlDB.SetConnMaxIdleTime(c.Pool.MaxIdleTime)
+	if err = connect(name, sqlDB, c.Connect); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	var d gorm.Dialector
+	switch c.Driver {
+	case conf.DriverMySQL:
+		d = mysql.New(mysql.Config{DSN: dsn, Conn: sqlDB})
+	case conf.DriverPostgres:
+		d = postgres.New(postgres.Config{DSN: dsn, Conn: sqlDB})
+	default:
+		d = sqlite.New(sqlite.Config{DSN: dsn, Conn: sqlDB})
+	}
+	db, err := gorm.Open(d, gormConf)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openSQL opens the pool of the driver, it does not connect yet
+func openSQL(c conf.Database, dsn string) (*sql.DB, error) {
+	switch c.Driver {
+	case conf.DriverMySQL:
+		cfg, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if c.MySQL.ReadTimeout > 0 {
+			cfg.ReadTimeout = c.MySQL.ReadTimeout
+		}
+		if c.MySQL.WriteTimeout > 0 {
+			cfg.WriteTimeout = c.MySQL.WriteTimeout
+		}
+		connector, err := mysqldriver.NewConnector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+
+	case conf.DriverPostgres:
+		cfg, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+		if timeout := c.Postgres.StatementTimeout; timeout > 0 {
+			cfg.RuntimeParams["statement_timeout"] = strconv.FormatInt(timeout.Milliseconds(), 10)
+		}
+		return stdlib.OpenDB(*cfg), nil
+
+	case conf.DriverSQLite:
+		return sql.Open(sqlite.DriverName, sqliteDSN(dsn, c.SQLite))
+	}
+	return nil, fmt.Errorf("unknown db driver %q", c.Driver)
+}
+
+// sqliteDSN adds the driver settings the DSN does not set itself, they
+// apply to every connection of the pool.
+func sqliteDSN(dsn string, s conf.SQLite) string {
+	path, query, _ := strings.Cut(dsn, "?")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return dsn
+	}
+	set := func(value string, keys ...string) {
+		for _, key := range keys {
+			if values.Has(key) {
+				return
+			}
+		}
+		values.Set(keys[0], value)
+	}
+	if s.JournalMode != "" {
+		set(s.JournalMode, "_journal_mode", "_journal")
+	}
+	set(strconv.FormatBool(s.ForeignKeys), "_foreign_keys", "_fk")
+	if s.BusyTimeout > 0 {
+		set(strconv.FormatInt(s.BusyTimeout.Milliseconds(), 10), "_busy_timeout", "_timeout")
+	}
+	return path + "?" + values.Encode()
+}
+
+// connect pings the database until it answers, it retries with a doubling
+// backoff until the connect timeout. A zero timeout tries once.
+func connect(name string, db *sql.DB, c conf.Connect) error {
+	if c.Timeout <= 0 {
+		return db.Ping()
+	}
+	deadline := time.Now().Add(c.Timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	backoff := c.Backoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || time.Until(deadline) < backoff {
+			return fmt.Errorf("no answer after %d attempts in %s: %w", attempt, c.Timeout, err)
+		}
+		slog.Warn("database not ready, retrying", "db", name, "attempt", attempt, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, c.MaxBackoff)
+	}
+}
+
+func usePlugins(db *gorm.DB, plugins ...gorm.Plugin) error {
+	for _, plugin := range plugins {
+		if err := db.Use(plugin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newPolicy(name string) dbresolver.Policy {
+	switch name {
+	case conf.PolicyLeastConn:
+		return &leastConnPolicy{}
+	case conf.PolicyRandom:
+		return dbresolver.RandomPolicy{}
+	}
+	return dbresolver.StrictRoundRobinPolicy()
+}
+
+// leastConnPolicy resolves the replica with the fewest connections in
+// use, the ties go round-robin.
+type leastConnPolicy struct {
+	next atomic.Uint64
+}
+
+func (p *leastConnPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	start := int(p.next.Add(1) % uint64(len(pools)))
+	best, least := pools[start], inUse(pools[start])
+	for i := 1; i < len(pools); i++ {
+		pool := pools[(start+i)%len(pools)]
+		if n := inUse(pool); n < least {
+			best, least = pool, n
+		}
+	}
+	return best
+}
+
+func inUse(pool gorm.ConnPool) int {
+	if db, ok := pool.(interface{ Stats() sql.DBStats }); ok {
+		return db.Stats().InUse
+	}
+	return 0
+}
+
+// WritesKey is the context key of the writes of a request
+type WritesKey struct{}
+
+// Writes records when a request last wrote to each connection
+type Writes struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewWrites creates the write record of a request
+func NewWrites() *Writes {
+	return &Writes{last: map[string]time.Time{}}
+}
+
+// WithWrites returns a context recording its writes
+func WithWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, WritesKey{}, NewWrites())
+}
+
+func writesFrom(ctx context.Context) *Writes {
+	if ctx == nil {
+		return nil
+	}
+	w, _ := ctx.Value(WritesKey{}).(*Writes)
+	return w
+}
+
+func (w *Writes) mark(name string) {
+	w.mu.Lock()
+	w.last[name] = time.Now()
+	w.mu.Unlock()
+}
+
+func (w *Writes) within(name string, window time.Duration) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last, ok := w.last[name]
+	return ok && time.Since(last) < window
+}
+
+// writesPlugin records the successful writes in the request writes
+type writesPlugin struct {
+	name string
+}
+
+func (p *writesPlugin) Name() string { return "trove:writes:" + p.name }
+
+func (p *writesPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	register := []func(name string, fn func(*gorm.DB)) error{
+		cb.Create().After("gorm:create").Register,
+		cb.Update().After("gorm:update").Register,
+		cb.Delete().After("gorm:delete").Register,
+		cb.Raw().After("gorm:raw").Register,
+	}
+	for _, fn := range register {
+		if err := fn("trove:writes", p.mark); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *writesPlugin) mark(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if w := writesFrom(db.Statement.Context); w != nil {
+		w.mark(p.name)
+	}
+}
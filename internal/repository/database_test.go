@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+)
+
+// newReplicated opens a sqlite primary and two replicas, the origin
+// table of each names the database it is in.
+func newReplicated(t *testing.T, policy string, sticky time.Duration) *Databases {
+	t.Helper()
+	dir := t.TempDir()
+	dsn := func(name string) string {
+		path := filepath.Join(dir, name+".db")
+		db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatalf("open %s error = %v", name, err)
+		}
+		err = db.Exec("CREATE TABLE origins (name text)").Exec("INSERT INTO origins VALUES (?)", name).Error
+		if err != nil {
+			t.Fatalf("seed %s error = %v", name, err)
+		}
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+		return path
+	}
+
+	db := conf.DefaultDatabase()
+	db.Driver, db.DSN, db.Policy, db.StickyWindow = conf.DriverSQLite, dsn("primary"), policy, sticky
+	db.Replicas = []conf.Replica{{DSN: dsn("replica0")}, {DSN: dsn("replica1")}}
+	db.Log.Level = "silent"
+	c := &conf.Config{Data: conf.Data{DB: map[string]conf.Database{conf.DBUser: db}}}
+
+	reg := prometheus.NewRegistry()
+	dbs, cleanup, err := NewDatabases(c, NewDBMetrics(reg), NewSlowQueries(reg))
+	if err != nil {
+		t.Fatalf("NewDatabases() error = %v", err)
+	}
+	t.Cleanup(cleanup)
+	return dbs
+}
+
+func origin(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var name string
+	if err := db.Table("origins").Select("name").Scan(&name).Error; err != nil {
+		t.Fatalf("read origin error = %v", err)
+	}
+	return name
+}
+
+func TestConn_Routing(t *testing.T) {
+	dbs := newReplicated(t, conf.PolicyRoundRobin, time.Millisecond*200)
+	conn, err := NewUserConn(dbs)
+	if err != nil {
+		t.Fatalf("NewUserConn() error = %v", err)
+	}
+	if _, err = dbs.Get("orders"); err == nil {
+		t.Errorf("Get() of an unknown connection error = nil")
+	}
+
+	ctx := WithWrites(context.Background())
+	var reads []string
+	for range 4 {
+		reads = append(reads, origin(t, conn.Session(ctx)))
+	}
+	if want := []string{"replica1", "replica0", "replica1", "replica0"}; !reflect.DeepEqual(reads, want) {
+		t.Errorf("round-robin reads = %v, want %v", reads, want)
+	}
+	if got := origin(t, conn.Primary()); got != "primary" {
+		t.Errorf("Primary() reads %s", got)
+	}
+
+	// * Writes go to the primary and pin the reads of the request.
+	if err = conn.Session(ctx).Exec("UPDATE origins SET name = name || '*'").Error; err != nil {
+		t.Fatalf("write error = %v", err)
+	}
+	if got := origin(t, conn.Session(ctx)); got != "primary*" {
+		t.Errorf("read after write = %s, want the written primary", got)
+	}
+	if got := origin(t, conn.Session(context.Background())); got == "primary*" {
+		t.Errorf("read of another request = primary, want a replica")
+	}
+	time.Sleep(time.Millisecond * 250)
+	if got := origin(t, conn.Session(ctx)); got == "primary*" {
+		t.Errorf("read after the sticky window = primary, want a replica")
+	}
+
+	// * Transactions stay on the primary.
+	repo := NewRepository(conn)
+	err = repo.Transaction(context.Background(), func(ctx context.Context) error {
+		if got := origin(t, repo.DB(ctx)); got != "primary*" {
+			t.Errorf("read in transaction = %s, want primary", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+}
+
+func TestConn_LeastConn(t *testing.T) {
+	dbs := newReplicated(t, conf.PolicyLeastConn, 0)
+	conn, _ := NewUserConn(dbs)
+
+	// * A connection in use on the first replica.
+	held, err := conn.Replicas()[0].Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Conn() error = %v", err)
+	}
+	defer held.Close()
+	for range 3 {
+		if got := origin(t, conn.Session(context.Background())); got != "replica1" {
+			t.Errorf("least-conn read = %s, want replica1", got)
+		}
+	}
+}
+
+func TestSqliteDSN(t *testing.T) {
+	s := conf.SQLite{JournalMode: conf.JournalWAL, ForeignKeys: true, BusyTimeout: time.Second * 5}
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"trove.db", "trove.db?_busy_timeout=5000&_foreign_keys=true&_journal_mode=wal"},
+		{"trove.db?_journal=delete&_fk=0", "trove.db?_busy_timeout=5000&_fk=0&_journal=delete"},
+		{"file:trove.db?cache=shared&_busy_timeout=100", "file:trove.db?_busy_timeout=100&_foreign_keys=true&_journal_mode=wal&cache=shared"},
+	}
+	for _, tt := range tests {
+		if got := sqliteDSN(tt.dsn, s); got != tt.want {
+			t.Errorf("sqliteDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "later")
+	db, err := sql.Open(sqlite.DriverName, filepath.Join(dir, "trove.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	// * The directory never shows up.
+	c := conf.Connect{Timeout: time.Millisecond * 300, Backoff: time.Millisecond * 20, MaxBackoff: time.Millisecond * 50}
+	err = connect("user", db, c)
+	if err == nil || !strings.Contains(err.Error(), "attempts in 300ms") {
+		t.Fatalf("connect() error = %v, want the retries given up", err)
+	}
+
+	// * The directory shows up while retrying.
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		os.Mkdir(dir, 0o755)
+	}()
+	c.Timeout = time.Second * 5
+	if err = connect("user", db, c); err != nil {
+		t.Errorf("connect() error = %v, want the database up after a retry", err)
+	}
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/crud"
+	"github.com/kelein/trove-fiber/pkg/jobs"
+)
+
+// claimAttempts bounds the claims lost to a concurrent worker in a row
+const claimAttempts = 3
+
+// errClaimLost is returned when a concurrent worker claimed the job first
+var errClaimLost = errors.New("job claimed by another worker")
+
+// jobStore is the jobs.Store of the jobs table
+type jobStore struct {
+	tm         Transaction
+	jobs       *crud.Repository[model.Job, int64]
+	skipLocked bool
+	now        func() time.Time
+}
+
+// NewJobStore creates the jobs.Store of the jobs table. The workers claim
+// the jobs with SELECT ... FOR UPDATE SKIP LOCKED on Postgres and MySQL,
+// on SQLite, which serializes the writes, a claim is a conditional update
+// and a lost one is tried again.
+func NewJobStore(r *Repository) jobs.Store {
+	return &jobStore{
+		tm:         r,
+		jobs:       crud.New[model.Job, int64](r, crud.WithNotFound(jobs.ErrNotFound)),
+		skipLocked: r.conn.Primary().Dialector.Name() != "sqlite",
+		now:        time.Now,
+	}
+}
+
+func (s *jobStore) Enqueue(ctx context.Context, job *jobs.Job) (*jobs.Job, error) {
+	if job.UniqueKey != "" {
+		queued, err := s.jobs.FindOne(ctx, crud.Eq("unique_key", job.UniqueKey))
+		if err == nil {
+			return toJob(queued), jobs.ErrDuplicate
+		}
+		if !errors.Is(err, jobs.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	now := s.now()
+	row := &model.Job{
+		Type:        job.Type,
+		Payload:     string(job.Payload),
+		Priority:    job.Priority,
+		Status:      jobs.StatusPending,
+		RunAt:       job.RunAt,
+		MaxAttempts: job.MaxAttempts,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if job.UniqueKey != "" {
+		row.UniqueKey = &job.UniqueKey
+	}
+	if err := s.jobs.Create(ctx, row); err != nil {
+		// * A concurrent enqueue of the key won the race.
+		if duplicated(err) {
+			return nil, jobs.ErrDuplicate
+		}
+		return nil, err
+	}
+	return toJob(row), nil
+}
+
+func (s *jobStore) Claim(ctx context.Context, worker string, types []string, lease time.Duration) (*jobs.Job, error) {
+	for range claimAttempts {
+		var claimed *model.Job
+		err := s.tm.Transaction(ctx, func(ctx context.Context) error {
+			now := s.now()
+			tx := s.jobs.DB(ctx).
+				Where("type IN ?", types).
+				Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+					jobs.StatusPending, now, jobs.StatusRunning, now).
+				Order("priority DESC, run_at, id")
+			if s.skipLocked {
+				tx = tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked})
+			}
+			var job model.Job
+			if err := tx.Take(&job).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+
+			until := now.Add(lease)
+			res := s.jobs.DB(ctx).
+				Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+				Updates(map[string]any{
+					"status":       jobs.StatusRunning,
+					"attempts":     job.Attempts + 1,
+					"locked_by":    worker,
+					"locked_until": until,
+					"updated_at":   now,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errClaimLost
+			}
+			job.Status, job.Attempts, job.LockedBy, job.LockedUntil, job.UpdatedAt = jobs.StatusRunning, job.Attempts+1, worker, &until, now
+			claimed = &job
+			return nil
+		})
+		if !errors.Is(err, errClaimLost) {
+			return toJob(claimed), err
+		}
+	}
+	return nil, nil
+}
+
+func (s *jobStore) Succeeded(ctx context.Context, id int64, worker string) error {
+	now := s.now()
+	return s.release(ctx, id, worker, map[string]any{
+		"status":      jobs.StatusSucceeded,
+		"unique_key":  nil,
+		"finished_at": now,
+		"updated_at":  now,
+	})
+}
+
+func (s *jobStore) Failed(ctx context.Context, id int64, worker string, cause error, retryAt time.Time, dead bool) error {
+	now := s.now()
+	values := map[string]any{
+		"status":     jobs.StatusPending,
+		"last_error": cause.Error(),
+		"run_at":     retryAt,
+		"updated_at": now,
+	}
+	if dead {
+		values["status"], values["unique_key"], values["finished_at"] = jobs.StatusDead, nil, now
+	}
+	return s.release(ctx, id, worker, values)
+}
+
+// release records the outcome of the job leased to the worker, a job
+// claimed again by another worker returns ErrState.
+func (s *jobStore) release(ctx context.Context, id int64, worker string, values map[string]any) error {
+	values["locked_by"], values["locked_until"] = "", nil
+	res := s.jobs.DB(ctx).
+		Where("id = ? AND status = ? AND locked_by = ?", id, jobs.StatusRunning, worker).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return jobs.ErrState
+	}
+	return nil
+}
+
+func (s *jobStore) List(ctx context.Context, filter jobs.Filter) ([]*jobs.Job, int64, error) {
+	specs := []crud.Spec{crud.OrderBy("id", true)}
+	if filter.Status != "" {
+		specs = append(specs, crud.Eq("status", filter.Status))
+	}
+	if filter.Type != "" {
+		specs = append(specs, crud.Eq("type", filter.Type))
+	}
+	rows, total, err := s.jobs.ListOffset(ctx, filter.Offset, filter.Limit, specs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	list := make([]*jobs.Job, len(rows))
+	for i, row := range rows {
+		list[i] = toJob(row)
+	}
+	return list, total, nil
+}
+
+func (s *jobStore) Retry(ctx context.Context, id int64) (*jobs.Job, error) {
+	now := s.now()
+	return s.transition(ctx, id, []string{jobs.StatusDead, jobs.StatusCanceled}, map[string]any{
+		"status":      jobs.StatusPending,
+		"attempts":    0,
+		"last_error":  "",
+		"run_at":      now,
+		"finished_at": nil,
+		"updated_at":  now,
+	})
+}
+
+func (s *jobStore) Cancel(ctx context.Context, id int64) (*jobs.Job, error) {
+	now := s.now()
+	return s.transition(ctx, id, []string{jobs.StatusPending}, map[string]any{
+		"status":      jobs.StatusCanceled,
+		"unique_key":  nil,
+		"finished_at": now,
+		"updated_at":  now,
+	})
+}
+
+// transition updates the job in one of the statuses, a job in another
+// returns ErrState.
+func (s *jobStore) transition(ctx context.Context, id int64, from []string, values map[string]any) (*jobs.Job, error) {
+	var job *model.Job
+	err := s.tm.Transaction(ctx, func(ctx context.Context) error {
+		res := s.jobs.DB(ctx).Where("id = ? AND status IN ?", id, from).Updates(values)
+		if res.Error != nil {
+			return res.Error
+		}
+		var err error
+		if job, err = s.jobs.FindByID(ctx, id); err == nil && res.RowsAffected == 0 {
+			err = jobs.ErrState
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toJob(job), nil
+}
+
+// toJob maps the row of the jobs table to its jobs.Job
+func toJob(row *model.Job) *jobs.Job {
+	if row == nil {
+		return nil
+	}
+	job := &jobs.Job{
+		ID:          row.ID,
+		Type:        row.Type,
+		Payload:     json.RawMessage(row.Payload),
+		Priority:    row.Priority,
+		Status:      row.Status,
+		Attempts:    row.Attempts,
+		MaxAttempts: row.MaxAttempts,
+		LastError:   row.LastError,
+		RunAt:       row.RunAt,
+		LockedBy:    row.LockedBy,
+		LockedUntil: row.LockedUntil,
+		CreatedAt:   row.CreatedAt,
+		FinishedAt:  row.FinishedAt,
+	}
+	if row.UniqueKey != nil {
+		job.UniqueKey = *row.UniqueKey
+	}
+	return job
+}
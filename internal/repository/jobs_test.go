@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kelein/trove-fiber/pkg/jobs"
+)
+
+func enqueue(t *testing.T, store jobs.Store, job *jobs.Job) *jobs.Job {
+	t.Helper()
+	job.Status, job.MaxAttempts = jobs.StatusPending, 3
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now().Add(-time.Second)
+	}
+	queued, err := store.Enqueue(context.Background(), job)
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	return queued
+}
+
+func TestJobStore_Claim(t *testing.T) {
+	store := NewJobStore(newOutboxRepo(t))
+	ctx := context.Background()
+	types := []string{"export"}
+	low := enqueue(t, store, &jobs.Job{Type: "export", Payload: []byte(`{"id":1}`)})
+	high := enqueue(t, store, &jobs.Job{Type: "export", Payload: []byte(`{}`), Priority: 5})
+	enqueue(t, store, &jobs.Job{Type: "export", Payload: []byte(`{}`), RunAt: time.Now().Add(time.Hour)})
+	enqueue(t, store, &jobs.Job{Type: "cleanup", Payload: []byte(`{}`)})
+
+	var claimed []int64
+	for {
+		job, err := store.Claim(ctx, "w1", types, time.Minute)
+		if err != nil {
+			t.Fatalf("Claim() error = %v", err)
+		}
+		if job == nil {
+			break
+		}
+		if job.Status != jobs.StatusRunning || job.Attempts != 1 || job.LockedBy != "w1" {
+			t.Errorf("Claim() = %+v, want running for w1 on its first attempt", job)
+		}
+		claimed = append(claimed, job.ID)
+	}
+	if len(claimed) != 2 || claimed[0] != high.ID || claimed[1] != low.ID {
+		t.Fatalf("claimed = %v, want the due export jobs %d then %d", claimed, high.ID, low.ID)
+	}
+
+	// * The job of an ended lease is claimed again, its former worker
+	// * can no longer record an outcome.
+	store.(*jobStore).now = func() time.Time { return time.Now().Add(time.Minute * 2) }
+	job, err := store.Claim(ctx, "w2", types, time.Minute)
+	if err != nil || job == nil || job.ID != high.ID || job.Attempts != 2 {
+		t.Fatalf("Claim() after the lease = %+v, %v, want job %d on its second attempt", job, err, high.ID)
+	}
+	if err = store.Succeeded(ctx, high.ID, "w1"); !errors.Is(err, jobs.ErrState) {
+		t.Errorf("Succeeded() by the former worker error = %v, want ErrState", err)
+	}
+	if err = store.Succeeded(ctx, high.ID, "w2"); err != nil {
+		t.Errorf("Succeeded() error = %v", err)
+	}
+}
+
+func TestJobStore_Failed(t *testing.T) {
+	store := NewJobStore(newOutboxRepo(t))
+	ctx := context.Background()
+	job := enqueue(t, store, &jobs.Job{Type: "export", Payload: []byte(`{}`), UniqueKey: "export:1"})
+
+	if dup, err := store.Enqueue(ctx, &jobs.Job{Type: "export", Payload: []byte(`{}`), UniqueKey: "export:1", RunAt: time.Now()}); !errors.Is(err, jobs.ErrDuplicate) || dup.ID != job.ID {
+		t.Fatalf("Enqueue() of the unique key = %+v, %v, want job %d and ErrDuplicate", dup, err, job.ID)
+	}
+
+	_, _ = store.Claim(ctx, "w1", []string{"export"}, time.Minute)
+	retryAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	if err := store.Failed(ctx, job.ID, "w1", errAbort, retryAt, false); err != nil {
+		t.Fatalf("Failed() error = %v", err)
+	}
+	list, _, _ := store.List(ctx, jobs.Filter{Limit: 10})
+	if got := list[0]; got.Status != jobs.StatusPending || !got.RunAt.Equal(retryAt) || got.LastError != errAbort.Error() || got.UniqueKey != "export:1" {
+		t.Fatalf("job after the failure = %+v, want pending until %v", got, retryAt)
+	}
+	if next, _ := store.Claim(ctx, "w1", []string{"export"}, time.Minute); next != nil {
+		t.Errorf("Claim() before the retry = %+v, want none", next)
+	}
+
+	// * A dead job releases its unique key.
+	store.(*jobStore).now = func() time.Time { return retryAt }
+	_, _ = store.Claim(ctx, "w1", []string{"export"}, time.Minute)
+	if err := store.Failed(ctx, job.ID, "w1", errAbort, retryAt, true); err != nil {
+		t.Fatalf("Failed() error = %v", err)
+	}
+	enqueue(t, store, &jobs.Job{Type: "export", Payload: []byte(`{}`), UniqueKey: "export:1"})
+	if list, total, _ := store.List(ctx, jobs.Filter{Status: jobs.StatusDead, Limit: 10}); total != 1 || list[0].ID != job.ID || list[0].FinishedAt == nil {
+		t.Errorf("List(dead) = %+v, %d, want job %d finished", list, total, job.ID)
+	}
+}
+
+func TestJobStore_RetryCancel(t *testing.T) {
+	store := NewJobStore(newOutboxRepo(t))
+	ctx := context.Background()
+	job := enqueue(t, store, &jobs.Job{Type: "export", Payload: []byte(`{}`), UniqueKey: "export:1"})
+
+	if _, err := store.Retry(ctx, job.ID); !errors.Is(err, jobs.ErrState) {
+		t.Errorf("Retry() of a pending job error = %v, want ErrState", err)
+	}
+	canceled, err := store.Cancel(ctx, job.ID)
+	if err != nil || canceled.Status != jobs.StatusCanceled || canceled.UniqueKey != "" {
+		t.Fatalf("Cancel() = %+v, %v, want canceled without its unique key", canceled, err)
+	}
+	if _, err = store.Cancel(ctx, job.ID); !errors.Is(err, jobs.ErrState) {
+		t.Errorf("Cancel() of a canceled job error = %v, want ErrState", err)
+	}
+	retried, err := store.Retry(ctx, job.ID)
+	if err != nil || retried.Status != jobs.StatusPending || retried.Attempts != 0 || retried.FinishedAt != nil {
+		t.Errorf("Retry() = %+v, %v, want pending again", retried, err)
+	}
+	if _, err = store.Cancel(ctx, 404); !errors.Is(err, jobs.ErrNotFound) {
+		t.Errorf("Cancel() of a missing job error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/crud"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
+)
+
+// dbLocker is the scheduler.Locker of the locks table, the expiry of a
+// lock is written by the clock of its owner.
+type dbLocker struct {
+	locks *crud.Repository[model.Lock, string]
+	now   func() time.Time
+}
+
+// NewDBLocker creates the Locker of the locks table
+func NewDBLocker(r *Repository) scheduler.Locker {
+	return &dbLocker{locks: crud.New[model.Lock, string](r, crud.WithKey("name")), now: time.Now}
+}
+
+// Acquire takes the lock over when it expired or the owner holds it, and
+// inserts it otherwise. An insert losing to another owner is not granted.
+func (l *dbLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	now := l.now()
+	res := l.locks.DB(ctx).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", key, owner, now).
+		Updates(map[string]any{"owner": owner, "expires_at": now.Add(ttl)})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.RowsAffected > 0, res.Error
+	}
+
+	err := l.locks.Create(ctx, &model.Lock{Name: key, Owner: owner, ExpiresAt: now.Add(ttl)})
+	if duplicated(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *dbLocker) Release(ctx context.Context, key, owner string) error {
+	return l.locks.DB(ctx).Where("name = ? AND owner = ?", key, owner).Delete(&model.Lock{}).Error
+}
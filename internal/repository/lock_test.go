@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDBLocker(t *testing.T) {
+	locker := NewDBLocker(newOutboxRepo(t))
+	ctx := context.Background()
+	acquire := func(owner string, want bool) {
+		t.Helper()
+		if ok, err := locker.Acquire(ctx, "leader", owner, time.Minute); err != nil || ok != want {
+			t.Errorf("Acquire(%s) = %v, %v, want %v", owner, ok, err, want)
+		}
+	}
+
+	acquire("a", true)
+	acquire("b", false)
+	acquire("a", true)
+	if err := locker.Release(ctx, "leader", "b"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	acquire("b", false)
+
+	// * An expired lock is taken over.
+	locker.(*dbLocker).now = func() time.Time { return time.Now().Add(time.Minute * 2) }
+	acquire("b", true)
+	acquire("a", false)
+	_ = locker.Release(ctx, "leader", "b")
+	acquire("a", true)
+}
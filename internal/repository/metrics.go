@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+const metricsStartKey = "trove:metrics:start"
+
+// DBMetrics collects data layer metrics of all named connections
+type DBMetrics struct {
+	reg     prometheus.Registerer
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+}
+
+// NewDBMetrics creates a new DBMetrics registered on the given registerer
+func NewDBMetrics(reg prometheus.Registerer) *DBMetrics {
+	m := &DBMetrics{reg: reg}
+	m.latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "How long database queries took, with labels db, operation and table.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"db", "operation", "table"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "db",
+		Name:      "query_errors_total",
+		Help:      "How many database queries failed, with labels db, operation and table.",
+	}, []string{"db", "operation", "table"})
+	reg.MustRegister(m.latency, m.errors)
+	return m
+}
+
+// Watch exports the sql.DBStats of the named connection
+func (m *DBMetrics) Watch(name string, db *sql.DB) error {
+	return m.reg.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Plugin returns a GORM plugin measuring the queries of the named connection
+func (m *DBMetrics) Plugin(name string) gorm.Plugin {
+	return &metricsPlugin{name: name, metrics: m}
+}
+
+type metricsPlugin struct {
+	name    string
+	metrics *DBMetrics
+}
+
+func (p *metricsPlugin) Name() string { return "trove:metrics:" + p.name }
+
+func (p *metricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("trove:metrics:before_"+h.operation, p.before); err != nil {
+			return err
+		}
+		if err := h.after("trove:metrics:after_"+h.operation, p.after(h.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *metricsPlugin) before(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func (p *metricsPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+
+		lvs := []string{p.name, operation, db.Statement.Table}
+		p.metrics.latency.WithLabelValues(lvs...).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			p.metrics.errors.WithLabelValues(lvs...).Inc()
+		}
+	}
+}
+
+// RedisCollector exports the connection pool stats of a Redis client
+type RedisCollector struct {
+	client *redis.Client
+
+	hits     *prometheus.Desc
+	misses   *prometheus.Desc
+	timeouts *prometheus.Desc
+	total    *prometheus.Desc
+	idle     *prometheus.Desc
+	stale    *prometheus.Desc
+}
+
+// NewRedisCollector creates a collector of the named Redis client pool stats
+func NewRedisCollector(name string, client *redis.Client) *RedisCollector {
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(version.Namespace(), "redis_pool", metric),
+			help, nil, prometheus.Labels{"client": name},
+		)
+	}
+	return &RedisCollector{
+		client:   client,
+		hits:     desc("hits_total", "Number of times free connection was found in the pool."),
+		misses:   desc("misses_total", "Number of times free connection was NOT found in the pool."),
+		timeouts: desc("timeouts_total", "Number of times a wait timeout occurred."),
+		total:    desc("connections", "Number of total connections in the pool."),
+		idle:     desc("idle_connections", "Number of idle connections in the pool."),
+		stale:    desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *RedisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.total
+	ch <- c.idle
+	ch <- c.stale
+}
+
+// Collect implements prometheus.Collector
+func (c *RedisCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.stale, prometheus.CounterValue, float64(stats.StaleConns))
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/kelein/trove-fiber/internal/model"
+)
+
+func TestDBMetrics_Plugin(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := NewDBMetrics(reg)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite error = %v", err)
+	}
+	if err = db.Use(metrics.Plugin("user")); err != nil {
+		t.Fatalf("use plugin error = %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db error = %v", err)
+	}
+	if err = metrics.Watch("user", sqlDB); err != nil {
+		t.Fatalf("watch error = %v", err)
+	}
+	if err = db.AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("migrate error = %v", err)
+	}
+
+	if err = db.Create(&model.User{UserID: "u1", Email: "u1@trove.io"}).Error; err != nil {
+		t.Fatalf("create error = %v", err)
+	}
+	var user model.User
+	if err = db.Where("user_id = ?", "u1").First(&user).Error; err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	_ = db.Where("user_id = ?", "none").First(&user).Error
+	_ = db.Table("missing").Where("id = ?", 1).Find(&user).Error
+
+	tests := []struct {
+		name   string
+		vec    *prometheus.CounterVec
+		labels []string
+		want   float64
+	}{
+		{"record not found is no error", metrics.errors, []string{"user", "query", "users"}, 0},
+		{"missing table is an error", metrics.errors, []string{"user", "query", "missing"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testutil.ToFloat64(tt.vec.WithLabelValues(tt.labels...)); got != tt.want {
+				t.Errorf("errors %v = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+
+	if got := testutil.CollectAndCount(metrics.latency, "trove_fiber_db_query_duration_seconds"); got < 2 {
+		t.Errorf("latency series = %d, want at least 2", got)
+	}
+	if got := testutil.CollectAndCount(reg, "go_sql_open_connections"); got != 1 {
+		t.Errorf("dbstats series = %d, want 1", got)
+	}
+}
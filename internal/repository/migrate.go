@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/migrate"
+)
+
+// Models are the GORM models the migrations must keep the schema in sync with
+var Models = []any{&model.User{}, &model.OutboxEvent{}, &model.ProcessedEvent{}, &model.Job{}, &model.Lock{}}
+
+// migrations holds the SQL migrations of every dialect,
+// migrations/<dialect>/<version>_<name>.<up|down>.sql
+//
+//go:embed migrations
+var migrations embed.FS
+
+// NewMigrator creates the Migrator of the migrations of the database
+// dialect, it runs on the primary of the connection.
+func NewMigrator(conn *Conn) (*migrate.Migrator, error) {
+	db := conn.Primary()
+	dialect := db.Dialector.Name()
+	list, err := migrate.Load(migrations, "migrations/"+dialect)
+	if err != nil {
+		return nil, fmt.Errorf("load %s migrations: %w", dialect, err)
+	}
+	return migrate.New(db, list, migrate.WithModels(Models...)), nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/migrate"
+)
+
+func TestMigrator(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "trove.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite error = %v", err)
+	}
+	m, err := NewMigrator(&Conn{Name: "user", db: db, primary: db})
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err = m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	drift, err := m.Drift(ctx)
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("Drift() after up = %+v, want the migrations in sync with the models", drift)
+	}
+
+	if _, err = m.Down(ctx, len(m.Migrations())); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if drift, err = m.Drift(ctx); err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	want := []migrate.Drift{
+		{Table: "users", Kind: migrate.DriftMissingTable},
+		{Table: "outbox", Kind: migrate.DriftMissingTable},
+		{Table: "processed_events", Kind: migrate.DriftMissingTable},
+		{Table: "jobs", Kind: migrate.DriftMissingTable},
+		{Table: "locks", Kind: migrate.DriftMissingTable},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("Drift() after down = %+v, want %+v", drift, want)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	// * Every dialect has the same migrations.
+	var want []string
+	for _, dialect := range []string{"sqlite", "mysql", "postgres"} {
+		list, err := migrate.Load(migrations, "migrations/"+dialect)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", dialect, err)
+		}
+		var names []string
+		for _, m := range list {
+			if m.Down == "" {
+				t.Errorf("%s migration %d_%s has no down script", dialect, m.Version, m.Name)
+			}
+			names = append(names, m.Name)
+		}
+		if want == nil {
+			want = names
+		} else if !reflect.DeepEqual(names, want) {
+			t.Errorf("%s migrations = %v, want %v", dialect, names, want)
+		}
+	}
+}
+
+func TestMigrator_Baseline(t *testing.T) {
+	// * A database of the users table from before the versioned migrations.
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "trove.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite error = %v", err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` text NOT NULL,`nickname` text NOT NULL," +
+			"`password` text NOT NULL,`email` text NOT NULL,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime," +
+			"CONSTRAINT `uni_users_user_id` UNIQUE (`user_id`))",
+		"CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`)",
+		"INSERT INTO `users` (`user_id`, `nickname`, `password`, `email`) VALUES ('u1', 'alice', 'secret', 'alice@trove.io')",
+	} {
+		if err = db.Exec(stmt).Error; err != nil {
+			t.Fatalf("create baseline schema error = %v", err)
+		}
+	}
+	m, err := NewMigrator(&Conn{Name: "user", db: db, primary: db})
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err = m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	drift, err := m.Drift(ctx)
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("Drift() after up = %+v, want the baseline schema brought forward", drift)
+	}
+	var user model.User
+	if err = db.First(&user).Error; err != nil {
+		t.Fatalf("read baseline user error = %v", err)
+	}
+	if user.Role != model.RoleUser || user.Status != model.StatusActive || user.Version != 1 {
+		t.Errorf("baseline user role, status, version = %s, %s, %d, want %s, %s, 1",
+			user.Role, user.Status, user.Version, model.RoleUser, model.StatusActive)
+	}
+}
@@ -0,0 +1,15 @@
+-- IF NOT EXISTS adopts the users table of the databases created before
+-- the versioned migrations, later columns are added by their migrations.
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` varchar(191) NOT NULL,
+  `nickname` longtext NOT NULL,
+  `password` longtext NOT NULL,
+  `email` longtext NOT NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `uni_users_user_id` UNIQUE (`user_id`),
+  INDEX `idx_users_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+-- version counts the updates of a user, an update of a stale read fails.
+ALTER TABLE `users` ADD COLUMN `version` bigint NOT NULL DEFAULT 1;
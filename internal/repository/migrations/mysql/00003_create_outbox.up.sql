@@ -0,0 +1,24 @@
+-- outbox holds the domain events recorded by the transactions of their
+-- changes until the relay delivers them, processed_events the events the
+-- idempotent consumers handled.
+CREATE TABLE `outbox` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `type` varchar(191) NOT NULL,
+  `event_key` varchar(191) NOT NULL,
+  `payload` longtext NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `last_error` longtext NOT NULL,
+  `retry_at` datetime(3) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `delivered_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_outbox_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `processed_events` (
+  `consumer` varchar(191) NOT NULL,
+  `event_id` bigint NOT NULL,
+  `processed_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`consumer`, `event_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,22 @@
+-- jobs holds the background jobs of the queue, a unique key is only held
+-- by a queued job.
+CREATE TABLE `jobs` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `type` varchar(191) NOT NULL,
+  `payload` longtext NOT NULL,
+  `unique_key` varchar(191) NULL,
+  `priority` bigint NOT NULL DEFAULT 0,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `run_at` datetime(3) NOT NULL,
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `max_attempts` bigint NOT NULL,
+  `last_error` longtext NOT NULL,
+  `locked_by` varchar(191) NOT NULL DEFAULT '',
+  `locked_until` datetime(3) NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `finished_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `uni_jobs_unique_key` (`unique_key`),
+  INDEX `idx_jobs_due` (`status`, `run_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,8 @@
+-- locks holds the named locks of the instances, such as the leader lock of
+-- the scheduler.
+CREATE TABLE `locks` (
+  `name` varchar(191) NOT NULL,
+  `owner` varchar(191) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+-- role and status gate the users, the existing users are active users.
+ALTER TABLE `users` ADD COLUMN `role` varchar(16) NOT NULL DEFAULT 'user';
+ALTER TABLE `users` ADD COLUMN `status` varchar(16) NOT NULL DEFAULT 'active';
@@ -0,0 +1,23 @@
+-- outbox holds the domain events recorded by the transactions of their
+-- changes until the relay delivers them, processed_events the events the
+-- idempotent consumers handled.
+CREATE TABLE "outbox" (
+  "id" bigserial PRIMARY KEY,
+  "type" text NOT NULL,
+  "event_key" text NOT NULL,
+  "payload" text NOT NULL,
+  "status" text NOT NULL DEFAULT 'pending',
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "last_error" text NOT NULL DEFAULT '',
+  "retry_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "delivered_at" timestamptz
+);
+CREATE INDEX "idx_outbox_status" ON "outbox" ("status");
+
+CREATE TABLE "processed_events" (
+  "consumer" text NOT NULL,
+  "event_id" bigint NOT NULL,
+  "processed_at" timestamptz NOT NULL,
+  PRIMARY KEY ("consumer", "event_id")
+);
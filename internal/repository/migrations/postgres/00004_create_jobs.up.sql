@@ -0,0 +1,21 @@
+-- jobs holds the background jobs of the queue, a unique key is only held
+-- by a queued job.
+CREATE TABLE "jobs" (
+  "id" bigserial PRIMARY KEY,
+  "type" text NOT NULL,
+  "payload" text NOT NULL,
+  "unique_key" text,
+  "priority" bigint NOT NULL DEFAULT 0,
+  "status" text NOT NULL DEFAULT 'pending',
+  "run_at" timestamptz NOT NULL,
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "max_attempts" bigint NOT NULL,
+  "last_error" text NOT NULL DEFAULT '',
+  "locked_by" text NOT NULL DEFAULT '',
+  "locked_until" timestamptz,
+  "created_at" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL,
+  "finished_at" timestamptz
+);
+CREATE UNIQUE INDEX "uni_jobs_unique_key" ON "jobs" ("unique_key");
+CREATE INDEX "idx_jobs_due" ON "jobs" ("status", "run_at");
@@ -0,0 +1,7 @@
+-- locks holds the named locks of the instances, such as the leader lock of
+-- the scheduler.
+CREATE TABLE "locks" (
+  "name" text PRIMARY KEY,
+  "owner" text NOT NULL,
+  "expires_at" timestamptz NOT NULL
+);
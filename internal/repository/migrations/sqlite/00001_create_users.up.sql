@@ -0,0 +1,14 @@
+-- IF NOT EXISTS adopts the users table of the databases created before
+-- the versioned migrations, later columns are added by their migrations.
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` text NOT NULL,
+  `nickname` text NOT NULL,
+  `password` text NOT NULL,
+  `email` text NOT NULL,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  CONSTRAINT `uni_users_user_id` UNIQUE (`user_id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users` (`deleted_at`);
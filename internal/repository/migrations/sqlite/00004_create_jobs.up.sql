@@ -0,0 +1,21 @@
+-- jobs holds the background jobs of the queue, a unique key is only held
+-- by a queued job.
+CREATE TABLE `jobs` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `type` text NOT NULL,
+  `payload` text NOT NULL,
+  `unique_key` text,
+  `priority` integer NOT NULL DEFAULT 0,
+  `status` text NOT NULL DEFAULT 'pending',
+  `run_at` datetime NOT NULL,
+  `attempts` integer NOT NULL DEFAULT 0,
+  `max_attempts` integer NOT NULL,
+  `last_error` text NOT NULL DEFAULT '',
+  `locked_by` text NOT NULL DEFAULT '',
+  `locked_until` datetime,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  `finished_at` datetime
+);
+CREATE UNIQUE INDEX `uni_jobs_unique_key` ON `jobs` (`unique_key`);
+CREATE INDEX `idx_jobs_due` ON `jobs` (`status`, `run_at`);
@@ -0,0 +1,3 @@
+-- role and status gate the users, the existing users are active users.
+ALTER TABLE `users` ADD COLUMN `role` text NOT NULL DEFAULT 'user';
+ALTER TABLE `users` ADD COLUMN `status` text NOT NULL DEFAULT 'active';
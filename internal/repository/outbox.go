@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/crud"
+	"github.com/kelein/trove-fiber/pkg/events"
+)
+
+// ErrNoTransaction is returned when an event is recorded outside of a
+// transaction, its change could commit without it.
+var ErrNoTransaction = errors.New("event recorded outside of a transaction")
+
+// Outbox records the domain events with the transaction of their change,
+// the relay delivers them from there.
+type Outbox interface {
+	events.Store
+	// Record adds the event of the key to the transaction of the context
+	Record(ctx context.Context, eventType, key string, payload any) error
+}
+
+type outbox struct {
+	events *crud.Repository[model.OutboxEvent, int64]
+	now    func() time.Time
+}
+
+// NewOutbox creates the Outbox of the outbox table
+func NewOutbox(r *Repository) Outbox {
+	return &outbox{events: crud.New[model.OutboxEvent, int64](r), now: time.Now}
+}
+
+func (o *outbox) Record(ctx context.Context, eventType, key string, payload any) error {
+	if scopeFrom(ctx) == nil {
+		return ErrNoTransaction
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := o.now()
+	return o.events.Create(ctx, &model.OutboxEvent{
+		Type:      eventType,
+		Key:       key,
+		Payload:   string(data),
+		Status:    model.OutboxPending,
+		RetryAt:   now,
+		CreatedAt: now,
+	})
+}
+
+func (o *outbox) Pending(ctx context.Context, limit int) ([]events.Delivery, error) {
+	list, err := o.events.List(ctx, crud.Eq("status", model.OutboxPending), crud.OrderBy("id", false), crud.Limit(limit))
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]events.Delivery, len(list))
+	for i, e := range list {
+		deliveries[i] = events.Delivery{
+			Event: events.Event{
+				ID:         e.ID,
+				Type:       e.Type,
+				Key:        e.Key,
+				Payload:    json.RawMessage(e.Payload),
+				OccurredAt: e.CreatedAt,
+			},
+			Attempts: e.Attempts,
+			RetryAt:  e.RetryAt,
+		}
+	}
+	return deliveries, nil
+}
+
+func (o *outbox) Delivered(ctx context.Context, id int64) error {
+	now := o.now()
+	return o.events.Patch(ctx, id, &model.OutboxEvent{Status: model.OutboxDelivered, DeliveredAt: &now}, "status", "delivered_at")
+}
+
+func (o *outbox) Failed(ctx context.Context, id int64, cause error, retryAt time.Time, dead bool) error {
+	status := model.OutboxPending
+	if dead {
+		status = model.OutboxDead
+	}
+	return o.events.DB(ctx).Where("id = ?", id).Updates(map[string]any{
+		"status":     status,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": cause.Error(),
+		"retry_at":   retryAt,
+	}).Error
+}
+
+// inbox is the events.Inbox of the processed_events table
+type inbox struct {
+	tm        Transaction
+	processed *crud.Repository[model.ProcessedEvent, int64]
+}
+
+// NewInbox creates the Inbox of the processed_events table
+func NewInbox(r *Repository) events.Inbox {
+	return &inbox{tm: r, processed: crud.New[model.ProcessedEvent, int64](r, crud.WithKey("event_id"))}
+}
+
+// Once runs fn within the transaction recording the event, the changes of
+// fn commit with the record. A concurrent delivery of the event fails on
+// the primary key and is retried.
+func (b *inbox) Once(ctx context.Context, consumer string, eventID int64, fn func(ctx context.Context) error) error {
+	return b.tm.Transaction(ctx, func(ctx context.Context) error {
+		n, err := b.processed.Count(ctx, crud.Eq("consumer", consumer), crud.Eq("event_id", eventID))
+		if err != nil || n > 0 {
+			return err
+		}
+		if err = fn(ctx); err != nil {
+			return err
+		}
+		return b.processed.Create(ctx, &model.ProcessedEvent{Consumer: consumer, EventID: eventID, ProcessedAt: time.Now()})
+	})
+}
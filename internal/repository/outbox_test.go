@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/events"
+)
+
+func newOutboxRepo(t *testing.T) *Repository {
+	t.Helper()
+	r := newTxRepo(t)
+	m, err := NewMigrator(r.conn)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if _, err = m.Up(context.Background(), 0); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	return r
+}
+
+func TestOutbox(t *testing.T) {
+	r := newOutboxRepo(t)
+	outbox := NewOutbox(r)
+	ctx := context.Background()
+	payload := model.UserRegistered{UserID: "u1"}
+
+	if err := outbox.Record(ctx, model.EventUserRegistered, "u1", payload); !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Record() outside of a transaction error = %v, want ErrNoTransaction", err)
+	}
+	// * The events of a rolled back change are never delivered.
+	_ = r.Transaction(ctx, func(ctx context.Context) error {
+		_ = outbox.Record(ctx, model.EventUserRegistered, "u0", payload)
+		return errAbort
+	})
+	for _, key := range []string{"u1", "u2"} {
+		err := r.Transaction(ctx, func(ctx context.Context) error {
+			return outbox.Record(ctx, model.EventUserRegistered, key, model.UserRegistered{UserID: key})
+		})
+		if err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	pending, err := outbox.Pending(ctx, 10)
+	if err != nil || len(pending) != 2 || pending[0].Key != "u1" || pending[1].Key != "u2" {
+		t.Fatalf("Pending() = %+v, %v, want u1 then u2", pending, err)
+	}
+	var got model.UserRegistered
+	if err = pending[0].Decode(&got); err != nil || got != payload {
+		t.Errorf("Decode() = %+v, %v, want %+v", got, err, payload)
+	}
+
+	retryAt := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	_ = outbox.Failed(ctx, pending[0].ID, errAbort, retryAt, false)
+	_ = outbox.Failed(ctx, pending[1].ID, errAbort, retryAt, true)
+	if pending, _ = outbox.Pending(ctx, 10); len(pending) != 1 || pending[0].Attempts != 1 || !pending[0].RetryAt.Equal(retryAt) {
+		t.Fatalf("Pending() after the failures = %+v, want u1 retried at %v", pending, retryAt)
+	}
+	_ = outbox.Delivered(ctx, pending[0].ID)
+	if pending, _ = outbox.Pending(ctx, 10); len(pending) != 0 {
+		t.Errorf("Pending() after the delivery = %+v, want none", pending)
+	}
+}
+
+func TestInbox(t *testing.T) {
+	r := newOutboxRepo(t)
+	ctx := context.Background()
+	calls, fail := 0, true
+	handle := events.Idempotent(NewInbox(r), "welcome", func(ctx context.Context, e events.Event) error {
+		calls++
+		if err := insert(r, "sent")(ctx); err != nil || fail {
+			return errors.Join(err, errAbort)
+		}
+		return nil
+	})
+	e := events.Event{ID: 1, Type: model.EventUserRegistered}
+
+	// * A failed handler rolls back its changes, the event is handled again.
+	if err := handle(ctx, e); !errors.Is(err, errAbort) || len(notes(t, r)) != 0 {
+		t.Fatalf("handle() = %v, notes %v, want the changes rolled back", err, notes(t, r))
+	}
+	fail = false
+	for range 2 {
+		if err := handle(ctx, e); err != nil {
+			t.Fatalf("handle() error = %v", err)
+		}
+	}
+	if got := notes(t, r); calls != 2 || len(got) != 1 {
+		t.Errorf("calls = %d, notes %v, want the event handled once past the failure", calls, got)
+	}
+}
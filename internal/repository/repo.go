@@ -6,41 +6,21 @@ import (
 	"gorm.io/gorm"
 )
 
-const ctxTxKey = "TxKey"
-
-// Transaction standard interface for transaction management
-type Transaction interface {
-	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
-}
-
-// NewTransaction builds a new Transaction instance
-func NewTransaction(r *Repository) Transaction { return r }
-
 // Repository layer for database operations
 type Repository struct {
-	db *gorm.DB
+	conn *Conn
 }
 
-// NewRepository creates a new Repository instance
-func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
+// NewRepository creates a new Repository instance on the connection
+func NewRepository(conn *Conn) *Repository {
+	return &Repository{conn: conn}
 }
 
-// DB builds a gorm.DB instance with the context
+// DB builds a gorm.DB instance with the context, the transaction of the
+// context or else the connection session of the request.
 func (r *Repository) DB(ctx context.Context) *gorm.DB {
-	v := ctx.Value(ctxTxKey)
-	if v != nil {
-		if tx, ok := v.(*gorm.DB); ok {
-			return tx
-		}
+	if scope := scopeFrom(ctx); scope != nil {
+		return scope.db
 	}
-	return r.db.WithContext(ctx)
-}
-
-// Transaction allows executing a function within a transaction context
-func (r *Repository) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		ctx = context.WithValue(ctx, ctxTxKey, tx)
-		return fn(ctx)
-	})
+	return r.conn.Session(ctx)
 }
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// maxFingerprints bounds the distinct slow queries kept in memory
+const maxFingerprints = 512
+
+// SlowQuery is the aggregate of the slow queries sharing a fingerprint
+type SlowQuery struct {
+	DB          string    `json:"db"`
+	Fingerprint string    `json:"fingerprint"`
+	Count       uint64    `json:"count"`
+	Total       float64   `json:"total_seconds"`
+	Max         float64   `json:"max_seconds"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// Sort orders of the slow query report
+const (
+	SortTotal = "total"
+	SortCount = "count"
+	SortMax   = "max"
+)
+
+// SlowQueries counts the slow queries of all named connections and
+// keeps an in-memory report of them grouped by normalised fingerprint.
+type SlowQueries struct {
+	mu      sync.Mutex
+	queries map[slowKey]*SlowQuery
+	counter *prometheus.CounterVec
+	now     func() time.Time
+}
+
+type slowKey struct {
+	db          string
+	fingerprint string
+}
+
+// NewSlowQueries creates a new SlowQueries registered on the given registerer
+func NewSlowQueries(reg prometheus.Registerer) *SlowQueries {
+	s := &SlowQueries{
+		queries: make(map[slowKey]*SlowQuery),
+		now:     time.Now,
+	}
+	s.counter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "db",
+		Name:      "slow_queries_total",
+		Help:      "How many database queries exceeded the slow threshold, with label db.",
+	}, []string{"db"})
+	reg.MustRegister(s.counter)
+	return s
+}
+
+// Hook returns the slow query hook of the named connection
+func (s *SlowQueries) Hook(name string) log.SlowHook {
+	return func(_ context.Context, sql string, elapsed time.Duration) {
+		s.Record(name, sql, elapsed)
+	}
+}
+
+// Record records a slow query of the named connection
+func (s *SlowQueries) Record(db, sql string, elapsed time.Duration) {
+	s.counter.WithLabelValues(db).Inc()
+
+	key := slowKey{db: db, fingerprint: Fingerprint(sql)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.queries[key]
+	if !ok {
+		if len(s.queries) >= maxFingerprints {
+			s.evict()
+		}
+		q = &SlowQuery{DB: db, Fingerprint: key.fingerprint}
+		s.queries[key] = q
+	}
+	seconds := elapsed.Seconds()
+	q.Count++
+	q.Total += seconds
+	q.Max = max(q.Max, seconds)
+	q.LastSeen = s.now()
+}
+
+// evict drops the least recently seen fingerprint
+func (s *SlowQueries) evict() {
+	var oldest slowKey
+	var seen time.Time
+	for key, q := range s.queries {
+		if seen.IsZero() || q.LastSeen.Before(seen) {
+			oldest, seen = key, q.LastSeen
+		}
+	}
+	delete(s.queries, oldest)
+}
+
+// Top returns the n worst slow queries in the given sort order,
+// by total time unless ordered by count or max.
+func (s *SlowQueries) Top(n int, order string) []SlowQuery {
+	s.mu.Lock()
+	list := make([]SlowQuery, 0, len(s.queries))
+	for _, q := range s.queries {
+		list = append(list, *q)
+	}
+	s.mu.Unlock()
+
+	value := func(q SlowQuery) float64 { return q.Total }
+	switch order {
+	case SortCount:
+		value = func(q SlowQuery) float64 { return float64(q.Count) }
+	case SortMax:
+		value = func(q SlowQuery) float64 { return q.Max }
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if vi, vj := value(list[i]), value(list[j]); vi != vj {
+			return vi > vj
+		}
+		return list[i].Fingerprint < list[j].Fingerprint
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Reset drops all recorded slow queries
+func (s *SlowQueries) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.queries)
+}
+
+var (
+	quotedRe  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberRe  = regexp.MustCompile(`\b-?\d+(?:\.\d+)?\b`)
+	holderRe  = regexp.MustCompile(`\$\d+|@p\d+`)
+	inListRe  = regexp.MustCompile(`(?i)\bin\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	valuesRe  = regexp.MustCompile(`(?i)\bvalues\s*\([^)]*\)(?:\s*,\s*\([^)]*\))*`)
+	spacingRe = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint normalises a SQL statement, literals and placeholders turn
+// into ?, IN lists and VALUES rows collapse, so that the statements of the
+// same query shape share a fingerprint whatever their params.
+func Fingerprint(sql string) string {
+	fp := quotedRe.ReplaceAllString(sql, "?")
+	fp = holderRe.ReplaceAllString(fp, "?")
+	fp = numberRe.ReplaceAllString(fp, "?")
+	fp = inListRe.ReplaceAllString(fp, "IN (?+)")
+	fp = valuesRe.ReplaceAllString(fp, "VALUES (?+)")
+	fp = spacingRe.ReplaceAllString(strings.TrimSpace(fp), " ")
+	return strings.TrimSuffix(fp, ";")
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/log"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{"placeholders", "SELECT * FROM `users` WHERE user_id = ? LIMIT 1", "SELECT * FROM `users` WHERE user_id = ? LIMIT ?"},
+		{"literals", "SELECT * FROM users WHERE email = 'a@b.io' AND id > 42", "SELECT * FROM users WHERE email = ? AND id > ?"},
+		{"escaped quote", "SELECT 1 FROM t WHERE name = 'o''brien'", "SELECT ? FROM t WHERE name = ?"},
+		{"postgres", `SELECT * FROM "users" WHERE id = $1 AND age < $2`, `SELECT * FROM "users" WHERE id = ? AND age < ?`},
+		{"in list", "DELETE FROM users WHERE id IN (1, 2,3)", "DELETE FROM users WHERE id IN (?+)"},
+		{"values rows", "INSERT INTO t (a,b) VALUES (1,'x'),(2,'y') RETURNING id", "INSERT INTO t (a,b) VALUES (?+) RETURNING id"},
+		{"identifiers", "SELECT t1.col2 FROM table3 t1;", "SELECT t1.col2 FROM table3 t1"},
+		{"spacing", "SELECT *\n\t FROM users", "SELECT * FROM users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fingerprint(tt.sql); got != tt.want {
+				t.Errorf("Fingerprint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowQueries_Top(t *testing.T) {
+	slow := NewSlowQueries(prometheus.NewRegistry())
+	slow.Record("user", "SELECT * FROM users WHERE id = 1", time.Second)
+	slow.Record("user", "SELECT * FROM users WHERE id = 2", time.Second)
+	slow.Record("user", "SELECT * FROM users WHERE id = 3", time.Second)
+	slow.Record("user", "UPDATE users SET name = 'x'", 5*time.Second)
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{SortTotal, "UPDATE users SET name = ?"},
+		{SortMax, "UPDATE users SET name = ?"},
+		{SortCount, "SELECT * FROM users WHERE id = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			top := slow.Top(1, tt.order)
+			if len(top) != 1 || top[0].Fingerprint != tt.want {
+				t.Errorf("Top(1, %s) = %+v, want %q", tt.order, top, tt.want)
+			}
+		})
+	}
+	if got := slow.Top(0, SortTotal); len(got) != 2 || got[1].Count != 3 {
+		t.Errorf("Top(0) = %+v, want 2 fingerprints", got)
+	}
+	if got := testutil.ToFloat64(slow.counter.WithLabelValues("user")); got != 4 {
+		t.Errorf("slow queries total = %v, want 4", got)
+	}
+}
+
+func TestSlowQueries_Ormlogger(t *testing.T) {
+	slow := NewSlowQueries(prometheus.NewRegistry())
+	logger := log.NewOrmlogger(nil, log.Option{
+		LogLevel:             log.Silent,
+		SlowThreshold:        time.Nanosecond,
+		ParameterizedQueries: true,
+	}).OnSlow(slow.Hook("user"))
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger})
+	if err != nil {
+		t.Fatalf("open sqlite error = %v", err)
+	}
+	if err = db.AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("migrate error = %v", err)
+	}
+	slow.Reset()
+
+	var user model.User
+	_ = db.Where("email = ?", "secret@trove.io").First(&user).Error
+	_ = db.Where("email = ?", "other@trove.io").First(&user).Error
+
+	top := slow.Top(10, SortCount)
+	if len(top) != 1 || top[0].Count != 2 {
+		t.Fatalf("Top() = %+v, want one fingerprint seen twice", top)
+	}
+	if fp := top[0].Fingerprint; fp != "SELECT * FROM `users` WHERE email = ? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT ?" {
+		t.Errorf("fingerprint = %q, want params dropped", fp)
+	}
+}
@@ -1,74 +1,86 @@
 package repository
 
 import (
+	"context"
 	"fmt"
-	"time"
+	"log/slog"
 
-	"github.com/go-redis/redis"
-	"github.com/spf13/viper"
-	"gorm.io/driver/mysql"
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
 
-	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/pkg/cache"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
 )
 
-// NewRedis creates a new Redis client
-func NewRedis(conf *viper.Viper) *redis.Client {
+// NewRedis creates a new Redis client whose pool metrics are labelled by
+// the name, the cleanup closes it
+func NewRedis(c *conf.Config, reg prometheus.Registerer, name string) (*redis.Client, func(), error) {
 	rdb := redis.NewClient(&redis.Options{
-		DB:       conf.GetInt("data.redis.db"),
-		Addr:     conf.GetString("data.redis.addr"),
-		Password: conf.GetString("data.redis.password"),
+		DB:           c.Data.Redis.DB,
+		Addr:         c.Data.Redis.Addr,
+		Password:     c.Data.Redis.Password,
+		ReadTimeout:  c.Data.Redis.ReadTimeout,
+		WriteTimeout: c.Data.Redis.WriteTimeout,
 	})
 
-	_, err := rdb.Ping().Result()
-	if err != nil {
-		panic(fmt.Sprintf("redis error: %s", err.Error()))
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		rdb.Close()
+		return nil, nil, fmt.Errorf("redis ping: %w", err)
+	}
+	if err := reg.Register(NewRedisCollector(name, rdb)); err != nil {
+		rdb.Close()
+		return nil, nil, err
+	}
+	cleanup := func() {
+		if err := rdb.Close(); err != nil {
+			slog.Error("close redis failed", "error", err)
+		}
 	}
-	return rdb
+	return rdb, cleanup, nil
 }
 
-// NewDB creates a new GORM database connection
-func NewDB(conf *viper.Viper) *gorm.DB {
-	var db *gorm.DB
-	var err error
-	driver := conf.GetString("data.db.user.driver")
-	dsn := conf.GetString("data.db.user.dsn")
-
-	switch driver {
-	case "mysql":
-		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
-			DisableAutomaticPing: false,
-			Logger:               log.DefaultOrmlogger(),
-		})
-
-	case "postgres":
-		db, err = gorm.Open(postgres.New(postgres.Config{
-			DSN:                  dsn,
-			PreferSimpleProtocol: true,
-		}), &gorm.Config{})
-
-	case "sqlite":
-		db, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
-			DisableAutomaticPing: false,
-			Logger:               log.DefaultOrmlogger(),
-		})
-
-	default:
-		panic("unknown db driver")
+// NewCache creates the cache of the repositories on the configured driver.
+// The tiered one receives the invalidations of the other instances until
+// the cleanup, which closes the Redis client.
+func NewCache(c *conf.Config, reg prometheus.Registerer) (cache.Cache, func(), error) {
+	cc := c.Data.Cache
+	if cc.Driver == conf.CacheMemory {
+		return cache.NewLRU(cc.Size), func() {}, nil
 	}
+	rdb, cleanup, err := NewRedis(c, reg, "default")
 	if err != nil {
-		panic(err)
+		return nil, nil, err
+	}
+	if cc.Driver == conf.CacheRedis {
+		return cache.NewRedis(rdb), cleanup, nil
 	}
-	db = db.Debug()
 
-	sqlDB, err := db.DB()
+	tiered := cache.NewTiered(cache.NewLRU(cc.Size), cache.NewRedis(rdb),
+		cache.NewRedisBus(rdb, cc.Tiered.Channel),
+		cache.WithNearTTL(cc.Tiered.NearTTL), cache.WithFallbackTTL(cc.Tiered.FallbackTTL))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tiered.Run(ctx)
+	}()
+	return tiered, func() {
+		cancel()
+		<-done
+		cleanup()
+	}, nil
+}
+
+// NewLocker creates the lock of the scheduler leader on the configured
+// driver, the cleanup closes the Redis client of the redis one.
+func NewLocker(c *conf.Config, r *Repository, reg prometheus.Registerer) (scheduler.Locker, func(), error) {
+	if c.Scheduler.Lock != conf.LockRedis {
+		return NewDBLocker(r), func() {}, nil
+	}
+	rdb, cleanup, err := NewRedis(c, reg, "lock")
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
-	return db
+	return scheduler.NewRedisLocker(rdb), cleanup, nil
 }
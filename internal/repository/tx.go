@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// Transaction Retries
+const (
+	DefaultTxAttempts = 3
+	DefaultTxBackoff  = time.Millisecond * 20
+)
+
+// Propagation is how a transaction relates to the one of the context
+type Propagation int
+
+// Transaction Propagations
+const (
+	// PropagationRequired joins the transaction of the context, or begins one
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew always begins a transaction of its own
+	PropagationRequiresNew
+	// PropagationNested runs in a savepoint of the transaction of the
+	// context, or begins one. A failure rolls back the savepoint only.
+	PropagationNested
+)
+
+// ErrTxOptions rejects the options a joined transaction cannot honour
+var ErrTxOptions = errors.New("transaction options conflict with the joined transaction")
+
+// Transaction standard interface for transaction management
+type Transaction interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error
+}
+
+// NewTransaction builds a new Transaction instance
+func NewTransaction(r *Repository) Transaction { return r }
+
+// TxOption configures a transaction call
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	propagation Propagation
+	sql         sql.TxOptions
+	attempts    int
+	backoff     time.Duration
+	retry       bool
+}
+
+// WithPropagation sets the propagation, PropagationRequired by default
+func WithPropagation(p Propagation) TxOption {
+	return func(o *txOptions) { o.propagation = p }
+}
+
+// WithIsolation sets the isolation level of the transaction it begins,
+// joining a transaction of another level fails with ErrTxOptions.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) { o.sql.Isolation = level }
+}
+
+// WithReadOnly makes the transaction it begins read-only, joining a
+// read-write transaction fails with ErrTxOptions.
+func WithReadOnly() TxOption {
+	return func(o *txOptions) { o.sql.ReadOnly = true }
+}
+
+// WithRetry sets the attempts of a transaction failing on a serialization
+// failure or a deadlock, the backoff doubles after each. One attempt
+// disables the retry. Retries apply to the outermost transaction only,
+// joining a transaction with retries fails with ErrTxOptions.
+func WithRetry(attempts int, backoff time.Duration) TxOption {
+	return func(o *txOptions) { o.attempts, o.backoff, o.retry = attempts, backoff, attempts > 1 }
+}
+
+// Transaction runs fn within a transaction by the propagation, every
+// read of the transaction goes to the primary. Only the call beginning
+// the transaction retries it, the calls joining it return the error and
+// must not ask for another isolation, read-only mode or retries.
+func (r *Repository) Transaction(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	o := txOptions{attempts: DefaultTxAttempts, backoff: DefaultTxBackoff}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	parent := scopeFrom(ctx)
+	if parent != nil && o.propagation != PropagationRequiresNew {
+		if err := o.joinable(parent); err != nil {
+			return err
+		}
+		switch o.propagation {
+		case PropagationRequired:
+			return fn(ctx)
+		case PropagationNested:
+			return runScope(ctx, parent.db, parent, fn, nil)
+		}
+	}
+
+	backoff := o.backoff
+	for attempt := 1; ; attempt++ {
+		err := runScope(ctx, r.conn.Primary().WithContext(ctx), nil, fn, &o.sql)
+		if err == nil || attempt >= o.attempts || !Retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// joinable checks the options against the transaction joined, which has
+// its isolation and mode already and is retried by its beginner only.
+func (o *txOptions) joinable(parent *txScope) error {
+	switch {
+	case o.sql.Isolation != sql.LevelDefault && o.sql.Isolation != parent.opts.Isolation:
+		return fmt.Errorf("%w: isolation %s, joined %s", ErrTxOptions, o.sql.Isolation, parent.opts.Isolation)
+	case o.sql.ReadOnly && !parent.opts.ReadOnly:
+		return fmt.Errorf("%w: read-only, joined read-write", ErrTxOptions)
+	case o.retry:
+		return fmt.Errorf("%w: retries apply to the outermost transaction only", ErrTxOptions)
+	}
+	return nil
+}
+
+// runScope runs fn in a transaction of db, a savepoint when db is in one.
+// The hooks of a released savepoint join the parent scope.
+func runScope(ctx context.Context, db *gorm.DB, parent *txScope, fn func(ctx context.Context) error, opts *sql.TxOptions) (err error) {
+	scope := &txScope{}
+	switch {
+	case parent != nil:
+		scope.opts = parent.opts
+	case opts != nil:
+		scope.opts = *opts
+	}
+	done := false
+	defer func() {
+		// * A panic rolled the transaction back too.
+		if !done {
+			scope.fire(ctx, false)
+		}
+	}()
+
+	var txOpts []*sql.TxOptions
+	if opts != nil {
+		txOpts = append(txOpts, opts)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		scope.db = tx
+		return fn(context.WithValue(ctx, txKey{}, scope))
+	}, txOpts...)
+	done = true
+
+	switch {
+	case err != nil:
+		scope.fire(ctx, false)
+	case parent != nil:
+		parent.adopt(scope)
+	default:
+		scope.fire(ctx, true)
+	}
+	return err
+}
+
+// OnCommit runs fn after the transaction of the context commits, or right
+// away without one. It never runs when the savepoint of the context
+// rolls back.
+func OnCommit(ctx context.Context, fn func(ctx context.Context)) {
+	scope := scopeFrom(ctx)
+	if scope == nil {
+		fn(ctx)
+		return
+	}
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	scope.commit = append(scope.commit, fn)
+}
+
+// OnRollback runs fn after the transaction or the savepoint of the
+// context rolls back, never without one.
+func OnRollback(ctx context.Context, fn func(ctx context.Context)) {
+	scope := scopeFrom(ctx)
+	if scope == nil {
+		return
+	}
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	scope.rollback = append(scope.rollback, fn)
+}
+
+// Retryable reports whether the transaction failed on a serialization
+// failure, a deadlock or a busy database, running it again may succeed.
+func Retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// * serialization_failure and deadlock_detected
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var myErr *mysqldriver.MySQLError
+	if errors.As(err, &myErr) {
+		// * ER_LOCK_DEADLOCK
+		return myErr.Number == 1213
+	}
+	var liteErr sqlite3.Error
+	if errors.As(err, &liteErr) {
+		return liteErr.Code == sqlite3.ErrBusy || liteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// duplicated reports whether the statement failed on a unique constraint
+// or a primary key
+func duplicated(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// * unique_violation
+		return pgErr.Code == "23505"
+	}
+	var myErr *mysqldriver.MySQLError
+	if errors.As(err, &myErr) {
+		// * ER_DUP_ENTRY
+		return myErr.Number == 1062
+	}
+	var liteErr sqlite3.Error
+	if errors.As(err, &liteErr) {
+		return liteErr.ExtendedCode == sqlite3.ErrConstraintUnique || liteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+// txKey is the context key of the transaction scope
+type txKey struct{}
+
+// txScope is a transaction, or a savepoint of one, and the hooks
+// registered within it.
+type txScope struct {
+	db   *gorm.DB
+	opts sql.TxOptions
+
+	mu       sync.Mutex
+	commit   []func(ctx context.Context)
+	rollback []func(ctx context.Context)
+}
+
+func scopeFrom(ctx context.Context) *txScope {
+	scope, _ := ctx.Value(txKey{}).(*txScope)
+	return scope
+}
+
+// adopt moves the hooks of a released savepoint to the scope
+func (s *txScope) adopt(child *txScope) {
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commit = append(s.commit, child.commit...)
+	s.rollback = append(s.rollback, child.rollback...)
+}
+
+// fire runs the commit or the rollback hooks once
+func (s *txScope) fire(ctx context.Context, committed bool) {
+	s.mu.Lock()
+	hooks := s.rollback
+	if committed {
+		hooks = s.commit
+	}
+	s.commit, s.rollback = nil, nil
+	s.mu.Unlock()
+	for _, fn := range hooks {
+		fn(ctx)
+	}
+}
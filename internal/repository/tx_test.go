@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errAbort = errors.New("abort")
+
+func newTxRepo(t *testing.T) *Repository {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "tx.db") + "?_journal_mode=wal&_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite error = %v", err)
+	}
+	if err = db.Exec("CREATE TABLE notes (name text)").Error; err != nil {
+		t.Fatalf("create notes error = %v", err)
+	}
+	return NewRepository(&Conn{Name: "user", db: db, primary: db})
+}
+
+func insert(r *Repository, name string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return r.DB(ctx).Exec("INSERT INTO notes VALUES (?)", name).Error
+	}
+}
+
+func notes(t *testing.T, r *Repository) []string {
+	t.Helper()
+	var names []string
+	if err := r.DB(context.Background()).Raw("SELECT name FROM notes ORDER BY name").Scan(&names).Error; err != nil {
+		t.Fatalf("read notes error = %v", err)
+	}
+	return names
+}
+
+func TestTransaction_Propagation(t *testing.T) {
+	r := newTxRepo(t)
+	ctx := context.Background()
+
+	// * Required joins, the failure of the outer transaction undoes both.
+	err := r.Transaction(ctx, func(ctx context.Context) error {
+		if err := r.Transaction(ctx, insert(r, "joined")); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) || len(notes(t, r)) != 0 {
+		t.Fatalf("required = %v, %v, want both rolled back", err, notes(t, r))
+	}
+
+	// * Requires new commits on its own.
+	err = r.Transaction(ctx, func(ctx context.Context) error {
+		if err := r.Transaction(ctx, insert(r, "new"), WithPropagation(PropagationRequiresNew)); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) || !reflect.DeepEqual(notes(t, r), []string{"new"}) {
+		t.Fatalf("requires new = %v, %v, want the new transaction committed", err, notes(t, r))
+	}
+
+	// * Nested rolls back its savepoint only.
+	err = r.Transaction(ctx, func(ctx context.Context) error {
+		if err := insert(r, "outer")(ctx); err != nil {
+			return err
+		}
+		err := r.Transaction(ctx, func(ctx context.Context) error {
+			if err := insert(r, "savepoint")(ctx); err != nil {
+				return err
+			}
+			return errAbort
+		}, WithPropagation(PropagationNested))
+		if !errors.Is(err, errAbort) {
+			return fmt.Errorf("nested error = %v, want abort", err)
+		}
+		return r.Transaction(ctx, insert(r, "released"), WithPropagation(PropagationNested))
+	})
+	if err != nil {
+		t.Fatalf("nested error = %v", err)
+	}
+	if want := []string{"new", "outer", "released"}; !reflect.DeepEqual(notes(t, r), want) {
+		t.Errorf("nested notes = %v, want %v", notes(t, r), want)
+	}
+
+	err = r.Transaction(ctx, insert(r, "isolated"), WithIsolation(sql.LevelSerializable), WithPropagation(PropagationNested))
+	if err != nil {
+		t.Errorf("nested without a transaction error = %v", err)
+	}
+}
+
+func TestTransaction_Hooks(t *testing.T) {
+	r := newTxRepo(t)
+	ctx := context.Background()
+	var events []string
+	hook := func(event string) func(context.Context) {
+		return func(context.Context) { events = append(events, event) }
+	}
+
+	err := r.Transaction(ctx, func(ctx context.Context) error {
+		OnCommit(ctx, hook("commit"))
+		OnRollback(ctx, hook("rollback"))
+		_ = r.Transaction(ctx, func(ctx context.Context) error {
+			OnCommit(ctx, hook("savepoint commit"))
+			OnRollback(ctx, hook("savepoint rollback"))
+			return errAbort
+		}, WithPropagation(PropagationNested))
+		return r.Transaction(ctx, func(ctx context.Context) error {
+			OnCommit(ctx, hook("released commit"))
+			return nil
+		}, WithPropagation(PropagationNested))
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+	if want := []string{"savepoint rollback", "commit", "released commit"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("hooks = %v, want %v", events, want)
+	}
+
+	events = nil
+	_ = r.Transaction(ctx, func(ctx context.Context) error {
+		OnCommit(ctx, hook("commit"))
+		OnRollback(ctx, hook("rollback"))
+		return errAbort
+	})
+	OnCommit(ctx, hook("no transaction"))
+	OnRollback(ctx, hook("never"))
+	if want := []string{"rollback", "no transaction"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("hooks = %v, want %v", events, want)
+	}
+
+	events = nil
+	func() {
+		defer func() { _ = recover() }()
+		_ = r.Transaction(ctx, func(ctx context.Context) error {
+			OnRollback(ctx, hook("panic"))
+			panic("boom")
+		})
+	}()
+	if want := []string{"panic"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("hooks after a panic = %v, want %v", events, want)
+	}
+}
+
+func TestTransaction_Retry(t *testing.T) {
+	r := newTxRepo(t)
+	ctx := context.Background()
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		opts     []TxOption
+		want     int
+		wantErr  bool
+	}{
+		{"retried", 2, busy, []TxOption{WithRetry(3, 0)}, 3, false},
+		{"exhausted", 5, busy, []TxOption{WithRetry(3, 0)}, 3, true},
+		{"no retry", 5, busy, []TxOption{WithRetry(1, 0)}, 1, true},
+		{"not retryable", 5, errAbort, []TxOption{WithRetry(3, 0)}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := r.Transaction(ctx, func(ctx context.Context) error {
+				attempts++
+				if attempts <= tt.failures {
+					return tt.err
+				}
+				return nil
+			}, tt.opts...)
+			if attempts != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("attempts = %d, error = %v, want %d attempts", attempts, err, tt.want)
+			}
+		})
+	}
+
+	// * A joined transaction leaves the retry to the outer one.
+	inner := 0
+	err := r.Transaction(ctx, func(ctx context.Context) error {
+		return r.Transaction(ctx, func(ctx context.Context) error {
+			inner++
+			if inner == 1 {
+				return busy
+			}
+			return nil
+		})
+	}, WithRetry(2, 0))
+	if err != nil || inner != 2 {
+		t.Errorf("joined retry = %d attempts, %v, want the outer transaction retried", inner, err)
+	}
+}
+
+func TestTransaction_JoinOptions(t *testing.T) {
+	r := newTxRepo(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		opts    []TxOption
+		wantErr bool
+	}{
+		{"no options", nil, false},
+		{"same isolation", []TxOption{WithIsolation(sql.LevelSerializable)}, false},
+		{"no retry", []TxOption{WithRetry(1, 0)}, false},
+		{"other isolation", []TxOption{WithIsolation(sql.LevelReadCommitted)}, true},
+		{"read-only", []TxOption{WithReadOnly()}, true},
+		{"retry", []TxOption{WithRetry(3, 0)}, true},
+		{"nested retry", []TxOption{WithRetry(3, 0), WithPropagation(PropagationNested)}, true},
+		{"requires new", []TxOption{WithReadOnly(), WithPropagation(PropagationRequiresNew)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joined := false
+			err := r.Transaction(ctx, func(ctx context.Context) error {
+				return r.Transaction(ctx, func(context.Context) error {
+					joined = true
+					return nil
+				}, tt.opts...)
+			}, WithIsolation(sql.LevelSerializable))
+			if (err != nil) != tt.wantErr || (tt.wantErr && (!errors.Is(err, ErrTxOptions) || joined)) {
+				t.Errorf("Transaction() error = %v, ran %v, wantErr %v", err, joined, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{fmt.Errorf("update: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&mysqldriver.MySQLError{Number: 1213}, true},
+		{&mysqldriver.MySQLError{Number: 1062}, false},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+		{gorm.ErrRecordNotFound, false},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
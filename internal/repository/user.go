@@ -3,61 +3,66 @@ package repository
 import (
 	"context"
 	"errors"
-
-	"gorm.io/gorm"
+	"time"
 
 	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/crud"
+)
+
+// User Repository Errors
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserConflict = crud.ErrConflict
 )
 
 // UserRepository abstracts the user-related operations
 type UserRepository interface {
 	Create(ctx context.Context, user *model.User) error
+	// Update saves the user when it is still at the version read,
+	// a crud.ConflictError otherwise.
 	Update(ctx context.Context, user *model.User) error
 	GetByID(ctx context.Context, id string) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	List(ctx context.Context, offset, limit int) ([]*model.User, int64, error)
+	// Purge hard deletes the users soft deleted before the time
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
-type userRepository struct{ *Repository }
+type userRepository struct {
+	users *crud.Repository[model.User, string]
+}
 
 // NewUserRepository creates a new instance of UserRepository
 func NewUserRepository(r *Repository) UserRepository {
-	return &userRepository{Repository: r}
+	users := crud.New[model.User, string](r, crud.WithKey("user_id"),
+		crud.WithVersion("version"), crud.WithNotFound(ErrUserNotFound))
+	return &userRepository{users: users}
 }
 
 func (r *userRepository) Create(ctx context.Context, user *model.User) error {
-	if err := r.DB(ctx).Create(user).Error; err != nil {
-		return err
-	}
-	return nil
+	return r.users.Create(ctx, user)
 }
 
 func (r *userRepository) Update(ctx context.Context, user *model.User) error {
-	if err := r.DB(ctx).Save(user).Error; err != nil {
-		return err
-	}
-	return nil
+	return r.users.Update(ctx, user)
 }
 
 func (r *userRepository) GetByID(ctx context.Context, userID string) (*model.User, error) {
-	var user model.User
-	cond := map[string]string{"user_id": userID}
-	if err := r.DB(ctx).Where(cond).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
-	}
-	return &user, nil
+	return r.users.FindByID(ctx, userID)
 }
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
-	var user model.User
-	cond := map[string]string{"email": email}
-	if err := r.DB(ctx).Where(cond).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
-		}
-		return nil, err
+	user, err := r.users.FindOne(ctx, crud.Eq("email", email))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil
 	}
-	return &user, nil
+	return user, err
+}
+
+func (r *userRepository) List(ctx context.Context, offset, limit int) ([]*model.User, int64, error) {
+	return r.users.ListOffset(ctx, offset, limit, crud.OrderBy("id", false))
+}
+
+func (r *userRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return r.users.Purge(ctx, before)
 }
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/cache"
+)
+
+// userCacheVersion is bumped when the cached model.User changes
+const userCacheVersion = 2
+
+// cachedUserRepository caches the users by id without their password
+// hash, the writes invalidate the entries of the user once committed.
+// GetByEmail serves the login, it always reads the database since the
+// login needs the hash and the status another process may have changed.
+type cachedUserRepository struct {
+	UserRepository
+	byID *cache.Typed[*model.User]
+}
+
+// NewCachedUserRepository creates the UserRepository caching its lookups
+func NewCachedUserRepository(r *Repository, c *conf.Config, store cache.Cache, metrics *cache.Metrics) UserRepository {
+	ttl, negative := c.Data.Cache.TTL, c.Data.Cache.NegativeTTL
+	return &cachedUserRepository{
+		UserRepository: NewUserRepository(r),
+		byID: cache.NewTyped[*model.User](store, "users", ttl, cache.WithVersion(userCacheVersion),
+			cache.WithNegative(ErrUserNotFound, negative), cache.WithMetrics(metrics)),
+	}
+}
+
+func (r *cachedUserRepository) Create(ctx context.Context, user *model.User) error {
+	if err := r.UserRepository.Create(ctx, user); err != nil {
+		return err
+	}
+	r.invalidate(ctx, user)
+	return nil
+}
+
+func (r *cachedUserRepository) Update(ctx context.Context, user *model.User) error {
+	err := r.UserRepository.Update(ctx, user)
+	if errors.Is(err, ErrUserConflict) {
+		// * The cached user lost the race, it is stale already.
+		r.evict(ctx, user)
+	}
+	if err != nil {
+		return err
+	}
+	r.invalidate(ctx, user)
+	return nil
+}
+
+// GetByID reads through the cache outside of the transactions, they may
+// see writes of their own the cache does not hold yet. The user has no
+// password hash either way, Update keeps the stored one.
+func (r *cachedUserRepository) GetByID(ctx context.Context, userID string) (*model.User, error) {
+	if scopeFrom(ctx) != nil {
+		return r.UserRepository.GetByID(ctx, userID)
+	}
+	return r.byID.Fetch(ctx, userID, func(ctx context.Context) (*model.User, error) {
+		user, err := r.UserRepository.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = ""
+		return user, nil
+	})
+}
+
+// invalidate evicts the entries of the user once the transaction of the
+// context commits, right away without a transaction.
+func (r *cachedUserRepository) invalidate(ctx context.Context, user *model.User) {
+	OnCommit(ctx, func(ctx context.Context) { r.evict(ctx, user) })
+}
+
+// evict removes the user, a failure leaves the entry until its ttl
+func (r *cachedUserRepository) evict(ctx context.Context, user *model.User) {
+	if err := r.byID.Delete(ctx, user.UserID); err != nil {
+		slog.Warn("cache invalidate failed", "key", user.UserID, "error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/cache"
+)
+
+func TestCachedUserRepository(t *testing.T) {
+	r := newTxRepo(t)
+	if err := r.DB(context.Background()).AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("migrate users error = %v", err)
+	}
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	c := &conf.Config{Data: conf.Data{Cache: conf.Cache{TTL: time.Minute, NegativeTTL: time.Minute}}}
+	users := NewCachedUserRepository(r, c, cache.NewRedis(client), cache.NewMetrics(prometheus.NewRegistry()))
+	ctx := context.Background()
+
+	user := &model.User{UserID: "u1", Email: "a@trove.dev", Nickname: "a", Password: "hash"}
+	if err := r.Transaction(ctx, func(ctx context.Context) error { return users.Create(ctx, user) }); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	got, err := users.GetByID(ctx, "u1")
+	if err != nil || got.Nickname != "a" || got.Password != "" {
+		t.Fatalf("GetByID() of a created user = %+v, %v, want it without the password hash", got, err)
+	}
+	if value, _ := server.Get("users:v2:u1"); value == "" || strings.Contains(value, "hash") {
+		t.Errorf("cached user = %q, want it without the password hash", value)
+	}
+
+	// * Reads are served from the cache until the user is updated.
+	r.DB(ctx).Exec("UPDATE users SET nickname = 'raw' WHERE user_id = 'u1'")
+	if got, err = users.GetByID(ctx, "u1"); err != nil || got.Nickname != "a" {
+		t.Errorf("GetByID() of a cached user = %+v, %v, want nickname a", got, err)
+	}
+	got.Nickname, got.Email = "b", "b@trove.dev"
+	if err = r.Transaction(ctx, func(ctx context.Context) error { return users.Update(ctx, got) }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, err = users.GetByID(ctx, "u1"); err != nil || got.Nickname != "b" || got.Version != 2 {
+		t.Errorf("GetByID() of an updated user = %+v, %v, want nickname b at version 2", got, err)
+	}
+
+	// * The update of a cached user keeps the stored hash.
+	if got, err = users.GetByEmail(ctx, "b@trove.dev"); err != nil || got == nil || got.Password != "hash" {
+		t.Errorf("GetByEmail() of the new email = %+v, %v, want it with the password hash", got, err)
+	}
+	if got, err = users.GetByEmail(ctx, "a@trove.dev"); got != nil || err != nil {
+		t.Errorf("GetByEmail() of a previous email = %+v, %v, want nil", got, err)
+	}
+
+	// * A stale version evicts the user.
+	stale := model.User{UserID: "u1", Email: "b@trove.dev", Version: 1}
+	if err = users.Update(ctx, &stale); !errors.Is(err, ErrUserConflict) {
+		t.Errorf("Update() of a stale version error = %v, want ErrUserConflict", err)
+	}
+	if server.Exists("users:v2:u1") {
+		t.Errorf("keys = %v, want the conflicting user evicted", server.Keys())
+	}
+
+	if _, err = users.GetByID(ctx, "u2"); !errors.Is(err, ErrUserNotFound) || !server.Exists("users:v2:u2") {
+		t.Errorf("GetByID() of a missing user error = %v, want ErrUserNotFound cached", err)
+	}
+}
+
+func TestCachedUserRepository_OtherProcess(t *testing.T) {
+	r := newTxRepo(t)
+	if err := r.DB(context.Background()).AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("migrate users error = %v", err)
+	}
+	// * Two processes on the database, each with a cache of its own.
+	c := &conf.Config{Data: conf.Data{Cache: conf.Cache{TTL: time.Minute, NegativeTTL: time.Minute}}}
+	server := NewCachedUserRepository(r, c, cache.NewLRU(16), cache.NewMetrics(prometheus.NewRegistry()))
+	cli := NewCachedUserRepository(r, c, cache.NewLRU(16), cache.NewMetrics(prometheus.NewRegistry()))
+	ctx := context.Background()
+
+	if err := server.Create(ctx, &model.User{UserID: "u1", Email: "a@trove.dev", Status: model.StatusActive}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := server.GetByEmail(ctx, "a@trove.dev"); err != nil {
+		t.Fatalf("GetByEmail() error = %v", err)
+	}
+	if _, err := server.GetByID(ctx, "u1"); err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+
+	user, err := cli.GetByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	user.Status = model.StatusDisabled
+	if err = cli.Update(ctx, user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if got, err := server.GetByEmail(ctx, "a@trove.dev"); err != nil || got == nil || !got.Disabled() {
+		t.Errorf("GetByEmail() of a user disabled by another process = %+v, %v, want it disabled", got, err)
+	}
+}
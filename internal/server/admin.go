@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/swagger"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/middleware"
+	"github.com/kelein/trove-fiber/internal/repository"
+	"github.com/kelein/trove-fiber/internal/slo"
+	"github.com/kelein/trove-fiber/pkg/health"
+	"github.com/kelein/trove-fiber/pkg/jobs"
+	"github.com/kelein/trove-fiber/pkg/log"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
+	"github.com/kelein/trove-fiber/pkg/server/http"
+)
+
+// AdminServer serves the operational endpoints on a separate listener
+type AdminServer struct{ *http.Server }
+
+// NewAdminServer create a new admin HTTP server instance
+func NewAdminServer(c *conf.Config, watcher *conf.Watcher, reg *prometheus.Registry, tracker *slo.Tracker,
+	probes *health.Registry, slow *repository.SlowQueries, queue *jobs.Queue,
+	sched *scheduler.Scheduler, public *http.Server) (*AdminServer, error) {
+	server := http.NewServer(
+		fiber.New(fiber.Config{DisableStartupMessage: true}),
+		http.WithHost(c.Admin.Host),
+		http.WithPort(c.Admin.Port),
+	)
+
+	guard, err := middleware.Guard(middleware.GuardConfig{
+		AllowIPs: c.Admin.AllowIPs,
+		Username: c.Admin.BasicAuth.Username,
+		Password: c.Admin.BasicAuth.Password,
+		Token:    c.Admin.Token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	app := server.App
+	app.Use(recover.New())
+	app.Use(guard)
+	app.Use(pprof.New())
+
+	app.Get(c.Metrics.Path, middleware.PromeHandler(reg))
+	app.Get("/livez", probeHandler(func(ctx *fiber.Ctx) health.Report { return probes.Live(ctx.Context()) }))
+	app.Get("/readyz", probeHandler(func(ctx *fiber.Ctx) health.Report { return probes.Ready(ctx.Context()) }))
+	app.Get("/slo", func(ctx *fiber.Ctx) error { return ctx.JSON(tracker.Report()) })
+	app.Get("/swagger/*", swagger.HandlerDefault)
+	app.Get("/config", func(ctx *fiber.Ctx) error { return ctx.JSON(watcher.Current().Redacted()) })
+	app.Post("/config/reload", func(ctx *fiber.Ctx) error {
+		if err := watcher.Reload(ctx.Context()); err != nil {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		return ctx.JSON(fiber.Map{"hash": conf.Hash(watcher.Current())})
+	})
+	app.Get("/routes", func(ctx *fiber.Ctx) error { return ctx.JSON(Routes(public.App)) })
+	app.Get("/log/level", getLogLevel)
+	app.Put("/log/level", setLogLevel)
+	app.Post("/log/debug-token", debugToken([]byte(c.Log.DebugKey)))
+	app.Get("/db/slow", slowQueries(slow))
+	app.Delete("/db/slow", func(ctx *fiber.Ctx) error {
+		slow.Reset()
+		return ctx.SendStatus(fiber.StatusNoContent)
+	})
+	app.Get("/jobs", listJobs(queue))
+	app.Post("/jobs/:id/retry", changeJob(queue.Retry))
+	app.Post("/jobs/:id/cancel", changeJob(queue.Cancel))
+	app.Get("/scheduler", func(ctx *fiber.Ctx) error { return ctx.JSON(sched.Status()) })
+	app.Get("/scheduler/runs", func(ctx *fiber.Ctx) error { return ctx.JSON(sched.History(ctx.Query("task"))) })
+	return &AdminServer{Server: server}, nil
+}
+
+// Route is a route of the route listing
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Routes lists the routes of the app, HEAD routes mirroring GET are left out
+func Routes(app *fiber.App) []Route {
+	list := make([]Route, 0)
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead {
+			continue
+		}
+		list = append(list, Route{Method: r.Method, Path: r.Path, Name: r.Name})
+	}
+	return list
+}
+
+// logLevel is the body of the log level endpoint,
+// an empty module stands for the default level.
+type logLevel struct {
+	Module   string `json:"module,omitempty"`
+	Level    string `json:"level"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// logLevels is the response of the log level endpoint
+type logLevels struct {
+	Level   string           `json:"level"`
+	Modules []log.ModuleInfo `json:"modules"`
+}
+
+func getLogLevel(ctx *fiber.Ctx) error {
+	return ctx.JSON(logLevels{Level: log.GetLevel(), Modules: log.Modules()})
+}
+
+// setLogLevel changes a module level, with a duration only debug
+// is accepted and the override expires on its own.
+func setLogLevel(ctx *fiber.Ctx) error {
+	var req logLevel
+	if err := ctx.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	var err error
+	switch {
+	case req.Duration != "":
+		var d time.Duration
+		if d, err = time.ParseDuration(req.Duration); err != nil {
+			break
+		}
+		if !strings.EqualFold(req.Level, "debug") {
+			err = errors.New("temporary override only supports debug level")
+			break
+		}
+		err = log.DebugFor(req.Module, d)
+
+	case req.Level == "" && req.Module != "":
+		err = log.ResetModuleLevel(req.Module)
+
+	default:
+		err = log.SetModuleLevel(req.Module, req.Level)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return getLogLevel(ctx)
+}
+
+// slowQueries reports the top slow query fingerprints,
+// ordered by the sort query of total, count or max.
+func slowQueries(slow *repository.SlowQueries) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		limit, err := strconv.Atoi(ctx.Query("limit", "10"))
+		if err != nil || limit < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid limit")
+		}
+		order := ctx.Query("sort", repository.SortTotal)
+		switch order {
+		case repository.SortTotal, repository.SortCount, repository.SortMax:
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, "sort must be total, count or max")
+		}
+		return ctx.JSON(slow.Top(limit, order))
+	}
+}
+
+// jobList is the response of the job listing
+type jobList struct {
+	Total int64       `json:"total"`
+	Jobs  []*jobs.Job `json:"jobs"`
+}
+
+// listJobs lists the jobs, the latest first, filtered by the status and
+// type queries.
+func listJobs(queue *jobs.Queue) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		offset, err := strconv.Atoi(ctx.Query("offset", "0"))
+		if err != nil || offset < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid offset")
+		}
+		limit, err := strconv.Atoi(ctx.Query("limit", "50"))
+		if err != nil || limit < 1 || limit > 500 {
+			return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 500")
+		}
+		list, total, err := queue.List(ctx.Context(), jobs.Filter{
+			Status: ctx.Query("status"),
+			Type:   ctx.Query("type"),
+			Offset: offset,
+			Limit:  limit,
+		})
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(jobList{Total: total, Jobs: list})
+	}
+}
+
+// changeJob applies the change to the job of the id param, a job whose
+// status does not allow it is a conflict.
+func changeJob(change func(ctx context.Context, id int64) (*jobs.Job, error)) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid job id")
+		}
+		job, err := change(ctx.Context(), id)
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case errors.Is(err, jobs.ErrState):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		case err != nil:
+			return err
+		}
+		return ctx.JSON(job)
+	}
+}
+
+// debugToken mints a signed header value forcing debug logs of a request
+func debugToken(key []byte) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if len(key) == 0 {
+			return fiber.NewError(fiber.StatusNotImplemented, "log.debug_key is not configured")
+		}
+		ttl := time.Minute * 5
+		if d := ctx.Query("duration"); d != "" {
+			var err error
+			if ttl, err = time.ParseDuration(d); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
+		return ctx.JSON(fiber.Map{
+			"header": middleware.HeaderDebugLog,
+			"value":  log.SignDebugToken(key, time.Now().Add(ttl)),
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/repository"
+	"github.com/kelein/trove-fiber/pkg/cache"
+	"github.com/kelein/trove-fiber/pkg/health"
+)
+
+// errCacheBusDown reports the near copies of the tiered cache miss the
+// invalidations of the other instances.
+var errCacheBusDown = errors.New("cache invalidation bus down")
+
+// NewHealth creates the health registry with the dependency checkers,
+// every database connection and its replicas are pinged. A shared cache
+// is pinged too, the lookups fall back to the databases while it is down.
+func NewHealth(c *conf.Config, dbs *repository.Databases, store cache.Cache) (*health.Registry, error) {
+	registry := health.NewRegistry(
+		health.WithDefaultTimeout(c.Health.Timeout),
+		health.WithDefaultCacheTTL(c.Health.CacheTTL),
+		health.WithDrainDelay(c.Health.DrainDelay),
+	)
+
+	for _, name := range dbs.Names() {
+		conn, err := dbs.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register("db:"+name, health.PingDB(conn.SQL()))
+		for i, replica := range conn.Replicas() {
+			registry.Register(fmt.Sprintf("db:%s:replica%d", name, i), health.PingDB(replica))
+		}
+		if db := c.Data.DB[name]; db.Driver == conf.DriverSQLite {
+			dir := filepath.Dir(strings.SplitN(db.DSN, "?", 2)[0])
+			registry.Register("disk:"+name, health.DiskSpace(dir, c.Health.DiskMinFree))
+		}
+	}
+	if pinger, ok := store.(interface{ Ping(context.Context) error }); ok {
+		registry.Register("cache", health.CheckerFunc(pinger.Ping), health.NonCritical())
+	}
+	if tiered, ok := store.(*cache.Tiered); ok {
+		registry.Register("cache:bus", health.CheckerFunc(func(context.Context) error {
+			if !tiered.Up() {
+				return errCacheBusDown
+			}
+			return nil
+		}), health.NonCritical())
+	}
+	return registry, nil
+}
+
+// probeHandler serves a health probe, failing probes answer 503
+func probeHandler(probe func(*fiber.Ctx) health.Report) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		report := probe(ctx)
+		if !report.Healthy() {
+			ctx.Status(fiber.StatusServiceUnavailable)
+		}
+		return ctx.JSON(report)
+	}
+}
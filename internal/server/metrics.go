@@ -0,0 +1,41 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/slo"
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// NewRegistry creates the prometheus registry served on the metrics path,
+// with the process and Go runtime collectors registered.
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		version.NewCollector(version.AppName),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// NewSLOTracker creates the SLO tracker of the configured route groups
+func NewSLOTracker(c *conf.Config, reg prometheus.Registerer) (*slo.Tracker, error) {
+	objectives := make([]slo.Objective, 0, len(c.SLO.Groups))
+	for _, g := range c.SLO.Groups {
+		objectives = append(objectives, slo.Objective{
+			Group:            g.Name,
+			Routes:           g.Routes,
+			Availability:     g.Availability,
+			Latency:          g.Latency,
+			LatencyThreshold: g.LatencyThreshold,
+		})
+	}
+	tracker := slo.NewTracker(objectives)
+	if err := reg.Register(slo.NewCollector(tracker)); err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
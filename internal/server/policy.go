@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+)
+
+// newCORS builds the CORS policy, no origins allow every origin. The
+// middleware panics on a malformed origin, it is returned as an error.
+func newCORS(c conf.CORS) (handler fiber.Handler, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("http.cors: %v", r)
+		}
+	}()
+	return cors.New(cors.Config{
+		AllowOrigins:     strings.Join(c.AllowOrigins, ","),
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}), nil
+}
+
+// newRateLimit limits the requests per client IP, rebuilding it
+// on reload starts the counters over.
+func newRateLimit(c conf.RateLimit) fiber.Handler {
+	if !c.Enabled {
+		return func(ctx *fiber.Ctx) error { return ctx.Next() }
+	}
+	return limiter.New(limiter.Config{Max: c.Max, Expiration: c.Expiration})
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+)
+
+func TestNewCORS(t *testing.T) {
+	tests := []struct {
+		name    string
+		cors    conf.CORS
+		wantErr bool
+	}{
+		{"no origins", conf.CORS{}, false},
+		{"origins", conf.CORS{AllowOrigins: []string{"https://trove.io", "https://*.trove.io"}}, false},
+		{"malformed origin", conf.CORS{AllowOrigins: []string{"trove.io"}}, true},
+		{"wildcard with credentials", conf.CORS{AllowOrigins: []string{"*"}, AllowCredentials: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCORS(tt.cors)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCORS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
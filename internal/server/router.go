@@ -2,52 +2,95 @@ package server
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/pprof"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/swagger"
-	"github.com/spf13/viper"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/kelein/trove-fiber/internal/conf"
 	"github.com/kelein/trove-fiber/internal/handler"
 	"github.com/kelein/trove-fiber/internal/middleware"
+	"github.com/kelein/trove-fiber/internal/slo"
+	"github.com/kelein/trove-fiber/pkg/health"
 	"github.com/kelein/trove-fiber/pkg/jwt"
 	"github.com/kelein/trove-fiber/pkg/server/http"
 	"github.com/kelein/trove-fiber/pkg/version"
 )
 
 // NewHTTPServer create a new HTTP server instance
-func NewHTTPServer(conf *viper.Viper, jwt *jwt.JWT, userHandler *handler.UserHandler) *http.Server {
+func NewHTTPServer(c *conf.Config, watcher *conf.Watcher, reg *prometheus.Registry, tracker *slo.Tracker,
+	probes *health.Registry, jwt *jwt.JWT, userHandler *handler.UserHandler) (*http.Server, error) {
 	server := http.NewServer(
 		fiber.New(),
-		http.WithHost(conf.GetString("http.host")),
-		http.WithPort(conf.GetInt("http.port")),
+		http.WithHost(c.HTTP.Host),
+		http.WithPort(c.HTTP.Port),
 	)
 
-	setupRouter(server.App, userHandler)
-	return server
+	if err := setupRouter(server.App, c, watcher, reg, tracker, probes, userHandler); err != nil {
+		return nil, err
+	}
+	return server, nil
 }
 
-func setupRouter(app *fiber.App, userHandler *handler.UserHandler) {
-	app.Use(etag.New())
-	app.Use(cors.New())
-	app.Use(pprof.New())
+func setupRouter(app *fiber.App, c *conf.Config, watcher *conf.Watcher, reg *prometheus.Registry,
+	tracker *slo.Tracker, probes *health.Registry, userHandler *handler.UserHandler) error {
+	// * CORS and rate limit are rebuilt when their config reloads,
+	// * a policy failing to build fails the reload and keeps the old one.
+	corsHandler, err := newCORS(c.HTTP.CORS)
+	if err != nil {
+		return err
+	}
+	corsMW := middleware.NewSwappable(corsHandler)
+	limitMW := middleware.NewSwappable(newRateLimit(c.HTTP.RateLimit))
+	watcher.Subscribe("http", []string{"http.cors", "http.rate_limit"}, func(e conf.Event) error {
+		corsHandler, err := newCORS(e.New.HTTP.CORS)
+		if err != nil {
+			return err
+		}
+		corsMW.Swap(corsHandler)
+		limitMW.Swap(newRateLimit(e.New.HTTP.RateLimit))
+		return nil
+	})
+
+	app.Use(middleware.ETag())
+	app.Use(corsMW.Run())
+	if c.Exposed(conf.ExposePprof) {
+		app.Use(pprof.New())
+	}
 	app.Use(recover.New())
 	app.Use(requestid.New())
+	app.Use(middleware.ReadYourWrites())
 
+	app.Use(middleware.DebugLog([]byte(c.Log.DebugKey)))
 	app.Use(middleware.Slogger())
-	prome := middleware.NewProme(app, version.AppName, "/metrics")
+	prome := middleware.NewProme(reg, version.AppName,
+		middleware.WithBuckets(c.Metrics.Buckets),
+		middleware.WithSizeBuckets(c.Metrics.SizeBuckets),
+		middleware.WithNativeHistogram(c.Metrics.NativeHistogramBucketFactor),
+		middleware.WithSkipPaths(c.Metrics.Path),
+	)
 	app.Use(prome.Run())
+	app.Use(middleware.SLO(tracker))
+	app.Use(limitMW.Run())
 
 	app.Get("/", index)
 	app.Get("/version", index)
-	app.Get("/healthz", index)
-	app.Get("/swagger/*", swagger.HandlerDefault)
-	app.Get("/index", func(ctx *fiber.Ctx) error {
-		ctx.Redirect("/swagger/index.html", fiber.StatusFound)
-		return nil
-	})
+	if c.Exposed(conf.ExposeMetrics) {
+		app.Get(c.Metrics.Path, middleware.PromeHandler(reg))
+	}
+	if c.Exposed(conf.ExposeHealth) {
+		app.Get("/livez", probeHandler(func(ctx *fiber.Ctx) health.Report { return probes.Live(ctx.Context()) }))
+		app.Get("/readyz", probeHandler(func(ctx *fiber.Ctx) health.Report { return probes.Ready(ctx.Context()) }))
+		app.Get("/healthz", probeHandler(func(ctx *fiber.Ctx) health.Report { return probes.Ready(ctx.Context()) }))
+	}
+	if c.Exposed(conf.ExposeSwagger) {
+		app.Get("/swagger/*", swagger.HandlerDefault)
+		app.Get("/index", func(ctx *fiber.Ctx) error {
+			ctx.Redirect("/swagger/index.html", fiber.StatusFound)
+			return nil
+		})
+	}
 
 	// // Non-strict permission routing group
 	// noStrictAuthRouter := v1.Group("/").Use(middleware.NoStrictAuth(jwt, logger))
@@ -62,6 +105,7 @@ func setupRouter(app *fiber.App, userHandler *handler.UserHandler) {
 	v1.Post("/register", userHandler.Register)
 	v1.Get("/user", userHandler.GetProfile)
 	v1.Put("/user", userHandler.UpdateProfile)
+	return nil
 }
 
 func index(ctx *fiber.Ctx) error { return ctx.JSON(version.Runtime()) }
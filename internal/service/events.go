@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/internal/repository"
+	"github.com/kelein/trove-fiber/pkg/events"
+	"github.com/kelein/trove-fiber/pkg/jobs"
+)
+
+// Event Consumers
+const (
+	ConsumerWelcomeMail = "welcome_mail"
+)
+
+// NewEventBus creates the in-process bus of the domain events with their
+// consumers subscribed, every consumer handles an event once.
+func NewEventBus(inbox events.Inbox, queue *jobs.Queue) *events.Bus {
+	bus := events.NewBus()
+	bus.Subscribe(model.EventUserRegistered, ConsumerWelcomeMail,
+		events.Idempotent(inbox, ConsumerWelcomeMail, enqueueWelcomeMail(queue)))
+	return bus
+}
+
+// NewRelay creates the relay of the outbox to the publisher
+func NewRelay(c *conf.Config, outbox repository.Outbox, pub events.Publisher, metrics *events.Metrics) *events.Relay {
+	o := c.Outbox
+	return events.NewRelay(outbox, pub,
+		events.WithInterval(o.Interval),
+		events.WithBatch(o.Batch),
+		events.WithMaxAttempts(o.MaxAttempts),
+		events.WithBackoff(o.Backoff, o.MaxBackoff),
+		events.WithMetrics(metrics),
+	)
+}
+
+// enqueueWelcomeMail queues the welcome mail of a registered user, the job
+// commits with the record of the handled event.
+func enqueueWelcomeMail(queue *jobs.Queue) events.Handler {
+	return func(ctx context.Context, e events.Event) error {
+		var registered model.UserRegistered
+		if err := e.Decode(&registered); err != nil {
+			return err
+		}
+		_, err := queue.Enqueue(ctx, JobWelcomeMail, registered, jobs.Unique("welcome:"+registered.UserID))
+		if errors.Is(err, jobs.ErrDuplicate) {
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/jobs"
+)
+
+// Job Types
+const (
+	JobWelcomeMail = "mail.welcome"
+)
+
+// NewJobQueue creates the queue of the background jobs with their
+// handlers registered
+func NewJobQueue(c *conf.Config, store jobs.Store, metrics *jobs.Metrics) *jobs.Queue {
+	j := c.Jobs
+	queue := jobs.New(store,
+		jobs.WithWorkers(j.Workers),
+		jobs.WithPoll(j.Poll),
+		jobs.WithLease(j.Lease),
+		jobs.WithMaxAttempts(j.MaxAttempts),
+		jobs.WithBackoff(j.Backoff, j.MaxBackoff),
+		jobs.WithMetrics(metrics),
+	)
+	jobs.Register(queue, JobWelcomeMail, sendWelcomeMail)
+	return queue
+}
+
+// sendWelcomeMail greets a registered user, no mailer is configured yet so
+// the mail is only logged.
+func sendWelcomeMail(ctx context.Context, registered model.UserRegistered) error {
+	slog.InfoContext(ctx, "welcome mail sent", "user_id", registered.UserID)
+	return nil
+}
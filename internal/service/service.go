@@ -1,6 +1,7 @@
 package service
 
 import (
+	"github.com/kelein/trove-fiber/internal/metrics"
 	"github.com/kelein/trove-fiber/internal/repository"
 	"github.com/kelein/trove-fiber/pkg/jwt"
 	"github.com/kelein/trove-fiber/pkg/sid"
@@ -11,13 +12,16 @@ type Service struct {
 	sid *sid.Sid
 	jwt *jwt.JWT
 	tm  repository.Transaction
+
+	metrics metrics.Recorder
 }
 
 // NewService creates a new Service instance.
-func NewService(sid *sid.Sid, jwt *jwt.JWT, tm repository.Transaction) *Service {
+func NewService(sid *sid.Sid, jwt *jwt.JWT, tm repository.Transaction, metrics metrics.Recorder) *Service {
 	return &Service{
-		sid: sid,
-		jwt: jwt,
-		tm:  tm,
+		sid:     sid,
+		jwt:     jwt,
+		tm:      tm,
+		metrics: metrics,
 	}
 }
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kelein/trove-fiber/internal/conf"
+	"github.com/kelein/trove-fiber/internal/repository"
+	"github.com/kelein/trove-fiber/pkg/scheduler"
+)
+
+// Task Names
+const (
+	TaskPurgeUsers = "purge_users"
+)
+
+// NewScheduler creates the scheduler of the periodic tasks, the tasks
+// without a spec are left out.
+func NewScheduler(c *conf.Config, locker scheduler.Locker, userRepo repository.UserRepository,
+	metrics *scheduler.Metrics) (*scheduler.Scheduler, error) {
+	sc := c.Scheduler
+	s := scheduler.New(
+		scheduler.WithLocker(locker, sc.LeaderTTL),
+		scheduler.WithHistory(sc.History),
+		scheduler.WithMetrics(metrics),
+	)
+	tasks := []struct {
+		name string
+		conf conf.Task
+		run  func(ctx context.Context) error
+	}{
+		{TaskPurgeUsers, sc.PurgeUsers.Task, purgeUsers(userRepo, sc.PurgeUsers.After)},
+	}
+	for _, t := range tasks {
+		if t.conf.Spec == "" {
+			continue
+		}
+		err := s.Add(scheduler.Task{
+			Name:    t.name,
+			Spec:    t.conf.Spec,
+			Jitter:  t.conf.Jitter,
+			Overlap: t.conf.Overlap,
+			Timeout: t.conf.Timeout,
+			Run:     t.run,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// purgeUsers hard deletes the users soft deleted for longer than after
+func purgeUsers(userRepo repository.UserRepository, after time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		n, err := userRepo.Purge(ctx, time.Now().Add(-after))
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "deleted users purged", "users", n)
+		return nil
+	}
+}
@@ -3,34 +3,56 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	v1 "github.com/kelein/trove-fiber/internal/api/v1"
+	"github.com/kelein/trove-fiber/internal/metrics"
 	"github.com/kelein/trove-fiber/internal/model"
 	"github.com/kelein/trove-fiber/internal/repository"
+	"github.com/kelein/trove-fiber/pkg/crud"
 )
 
 // User Service Errors
 var (
-	ErrUserNotFound  = errors.New("user not found")
+	ErrUserNotFound  = repository.ErrUserNotFound
+	ErrUserConflict  = repository.ErrUserConflict
 	ErrUserYetExist  = errors.New("user email already exists")
+	ErrUserDisabled  = errors.New("user is disabled")
+	ErrInvalidRole   = errors.New("invalid user role")
 	ErrDatabaseQuery = errors.New("database error when querying")
 )
 
+// tokenTTL is how long an issued access token stays valid
+const tokenTTL = time.Hour * 24 * 90
+
+// Page sizes of the user listing
+const (
+	defaultPageSize = 20
+	maxPageSize     = 500
+)
+
 // UserService abstracts the user-related operations
 type UserService interface {
 	Register(ctx context.Context, req *v1.RegisterRequest) error
 	Login(ctx context.Context, req *v1.LoginRequest) (string, error)
 	GetProfile(ctx context.Context, userID string) (*v1.GetProfileResponseData, error)
-	UpdateProfile(ctx context.Context, userID string, req *v1.UpdateProfileRequest) error
+	UpdateProfile(ctx context.Context, userID string, version int64, req *v1.UpdateProfileRequest) (int64, error)
+
+	CreateUser(ctx context.Context, req *v1.CreateUserRequest) (*v1.UserInfo, error)
+	ListUsers(ctx context.Context, req *v1.ListUsersRequest) (*v1.ListUsersResponseData, error)
+	DisableUser(ctx context.Context, userID string) (*v1.UserInfo, error)
+	SetRole(ctx context.Context, userID, role string) (*v1.UserInfo, error)
+	IssueToken(ctx context.Context, userID string, ttl time.Duration) (string, error)
 }
 
 // NewUserService create a new UserService instance
-func NewUserService(service *Service, userRepo repository.UserRepository) UserService {
+func NewUserService(service *Service, userRepo repository.UserRepository, outbox repository.Outbox) UserService {
 	return &userService{
 		userRepo: userRepo,
+		outbox:   outbox,
 		Service:  service,
 	}
 }
@@ -38,54 +60,73 @@ func NewUserService(service *Service, userRepo repository.UserRepository) UserSe
 type userService struct {
 	*Service
 	userRepo repository.UserRepository
+	outbox   repository.Outbox
 }
 
 func (s *userService) Register(ctx context.Context, req *v1.RegisterRequest) error {
+	_, err := s.create(ctx, &model.User{Email: req.Email, Role: model.RoleUser}, req.Password)
+	return err
+}
+
+// create registers a new active user with a unique email
+func (s *userService) create(ctx context.Context, user *model.User, password string) (*model.User, error) {
 	// check username
-	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	exist, err := s.userRepo.GetByEmail(ctx, user.Email)
 	if err != nil {
-		return ErrDatabaseQuery
+		return nil, ErrDatabaseQuery
 	}
-	if err == nil && user != nil {
-		return ErrUserYetExist
+	if exist != nil {
+		return nil, ErrUserYetExist
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Generate user ID
-	userID, err := s.sid.GenString()
-	if err != nil {
-		return err
-	}
-	user = &model.User{
-		UserID:   userID,
-		Email:    req.Email,
-		Password: string(hashedPassword),
+	if user.UserID, err = s.sid.GenString(); err != nil {
+		return nil, err
 	}
+	user.Password = string(hashedPassword)
+	user.Status = model.StatusActive
 
 	// TODO: Move transaction to repository layer
 	err = s.tm.Transaction(ctx, func(ctx context.Context) error {
-		return s.userRepo.Create(ctx, user)
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		registered := model.UserRegistered{UserID: user.UserID, Email: user.Email}
+		return s.outbox.Record(ctx, model.EventUserRegistered, user.UserID, registered)
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.UserRegistered()
+	return user, nil
 }
 
 func (s *userService) Login(ctx context.Context, req *v1.LoginRequest) (string, error) {
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil || user == nil {
+		s.metrics.LoginFailed(metrics.ReasonUserNotFound)
 		return "", ErrUserNotFound
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
+		s.metrics.LoginFailed(metrics.ReasonBadPassword)
 		return "", err
 	}
-	token, err := s.jwt.GenToken(user.UserID, time.Now().Add(time.Hour*24*90))
+	if user.Disabled() {
+		s.metrics.LoginFailed(metrics.ReasonUserDisabled)
+		return "", ErrUserDisabled
+	}
+	token, err := s.jwt.GenToken(user.UserID, time.Now().Add(tokenTTL))
 	if err != nil {
+		s.metrics.LoginFailed(metrics.ReasonTokenError)
 		return "", err
 	}
+	s.metrics.LoginSucceeded()
 	return token, nil
 }
 
@@ -98,19 +139,112 @@ func (s *userService) GetProfile(ctx context.Context, userID string) (*v1.GetPro
 	return &v1.GetProfileResponseData{
 		UserId:   user.UserID,
 		Nickname: user.Nickname,
+		Version:  user.Version,
 	}, nil
 }
 
-func (s *userService) UpdateProfile(ctx context.Context, userID string, req *v1.UpdateProfileRequest) error {
+// UpdateProfile updates the profile of the user at the version, any
+// version when zero, and returns the new version.
+func (s *userService) UpdateProfile(ctx context.Context, userID string, version int64, req *v1.UpdateProfileRequest) (int64, error) {
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if version > 0 && user.Version != version {
+		return 0, &crud.ConflictError{Version: version}
 	}
 
 	user.Email = req.Email
 	user.Nickname = req.Nickname
 	if err = s.userRepo.Update(ctx, user); err != nil {
-		return err
+		return 0, err
+	}
+	s.metrics.ProfileUpdated()
+	return user.Version, nil
+}
+
+func (s *userService) CreateUser(ctx context.Context, req *v1.CreateUserRequest) (*v1.UserInfo, error) {
+	role := req.Role
+	if role == "" {
+		role = model.RoleUser
+	}
+	if !slices.Contains(model.Roles, role) {
+		return nil, ErrInvalidRole
+	}
+	user, err := s.create(ctx, &model.User{Email: req.Email, Nickname: req.Nickname, Role: role}, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	info := userInfo(user)
+	return &info, nil
+}
+
+func (s *userService) ListUsers(ctx context.Context, req *v1.ListUsersRequest) (*v1.ListUsersResponseData, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	limit = min(limit, maxPageSize)
+	users, total, err := s.userRepo.List(ctx, max(req.Offset, 0), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &v1.ListUsersResponseData{Total: total, Users: make([]v1.UserInfo, 0, len(users))}
+	for _, user := range users {
+		data.Users = append(data.Users, userInfo(user))
+	}
+	return data, nil
+}
+
+func (s *userService) DisableUser(ctx context.Context, userID string) (*v1.UserInfo, error) {
+	return s.change(ctx, userID, func(user *model.User) { user.Status = model.StatusDisabled })
+}
+
+func (s *userService) SetRole(ctx context.Context, userID, role string) (*v1.UserInfo, error) {
+	if !slices.Contains(model.Roles, role) {
+		return nil, ErrInvalidRole
+	}
+	return s.change(ctx, userID, func(user *model.User) { user.Role = role })
+}
+
+// change applies an admin change to the user and saves it
+func (s *userService) change(ctx context.Context, userID string, fn func(user *model.User)) (*v1.UserInfo, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	fn(user)
+	if err = s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	info := userInfo(user)
+	return &info, nil
+}
+
+// IssueToken issues an access token of an active user, a zero ttl
+// stands for the login token ttl.
+func (s *userService) IssueToken(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if user.Disabled() {
+		return "", ErrUserDisabled
+	}
+	if ttl <= 0 {
+		ttl = tokenTTL
+	}
+	return s.jwt.GenToken(user.UserID, time.Now().Add(ttl))
+}
+
+func userInfo(user *model.User) v1.UserInfo {
+	return v1.UserInfo{
+		UserId:    user.UserID,
+		Email:     user.Email,
+		Nickname:  user.Nickname,
+		Role:      user.Role,
+		Status:    user.Status,
+		CreatedAt: user.CreatedAt,
 	}
-	return nil
 }
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	v1 "github.com/kelein/trove-fiber/internal/api/v1"
+	"github.com/kelein/trove-fiber/internal/metrics"
+	"github.com/kelein/trove-fiber/internal/model"
+	"github.com/kelein/trove-fiber/pkg/jwt"
+)
+
+type fakeUserRepo struct {
+	users map[string]*model.User
+}
+
+func (r *fakeUserRepo) Create(_ context.Context, user *model.User) error {
+	r.users[user.UserID] = user
+	return nil
+}
+
+func (r *fakeUserRepo) Update(_ context.Context, user *model.User) error {
+	user.Version++
+	r.users[user.UserID] = user
+	return nil
+}
+
+func (r *fakeUserRepo) GetByID(_ context.Context, id string) (*model.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *fakeUserRepo) GetByEmail(_ context.Context, email string) (*model.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) List(_ context.Context, offset, limit int) ([]*model.User, int64, error) {
+	ids := make([]string, 0, len(r.users))
+	for id := range r.users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var users []*model.User
+	for _, id := range ids[min(offset, len(ids)):min(offset+limit, len(ids))] {
+		users = append(users, r.users[id])
+	}
+	return users, int64(len(ids)), nil
+}
+
+func (r *fakeUserRepo) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+type fakeRecorder struct {
+	metrics.Recorder
+	logins   int
+	failures map[string]int
+}
+
+func (r *fakeRecorder) LoginSucceeded()           { r.logins++ }
+func (r *fakeRecorder) LoginFailed(reason string) { r.failures[reason]++ }
+
+func TestUserService_Login(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password error = %v", err)
+	}
+	repo := &fakeUserRepo{users: map[string]*model.User{
+		"u1": {UserID: "u1", Email: "u1@trove.io", Password: string(hashed)},
+	}}
+	recorder := &fakeRecorder{Recorder: metrics.NewNopRecorder(), failures: map[string]int{}}
+	svc := NewUserService(NewService(nil, jwt.NewJwt("test-key"), nil, recorder), repo, nil)
+
+	tests := []struct {
+		name   string
+		req    v1.LoginRequest
+		reason string
+	}{
+		{"success", v1.LoginRequest{Email: "u1@trove.io", Password: "secret"}, ""},
+		{"unknown email", v1.LoginRequest{Email: "u2@trove.io", Password: "secret"}, metrics.ReasonUserNotFound},
+		{"wrong password", v1.LoginRequest{Email: "u1@trove.io", Password: "wrong"}, metrics.ReasonBadPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := recorder.failures[tt.reason]
+			_, err := svc.Login(context.Background(), &tt.req)
+			if (err != nil) != (tt.reason != "") {
+				t.Fatalf("Login() error = %v, want failure %q", err, tt.reason)
+			}
+			if tt.reason != "" && recorder.failures[tt.reason] != before+1 {
+				t.Errorf("failures[%s] = %d, want %d", tt.reason, recorder.failures[tt.reason], before+1)
+			}
+		})
+	}
+	if recorder.logins != 1 {
+		t.Errorf("logins = %d, want 1", recorder.logins)
+	}
+	if _, err = svc.Login(context.Background(), &v1.LoginRequest{Email: "u2@trove.io"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Login() error = %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestUserService_Admin(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password error = %v", err)
+	}
+	repo := &fakeUserRepo{users: map[string]*model.User{
+		"u1": {UserID: "u1", Email: "u1@trove.io", Password: string(hashed), Role: model.RoleUser, Status: model.StatusActive},
+		"u2": {UserID: "u2", Email: "u2@trove.io", Role: model.RoleUser, Status: model.StatusActive},
+	}}
+	recorder := &fakeRecorder{Recorder: metrics.NewNopRecorder(), failures: map[string]int{}}
+	svc := NewUserService(NewService(nil, jwt.NewJwt("test-key"), nil, recorder), repo, nil)
+	ctx := context.Background()
+
+	if _, err = svc.CreateUser(ctx, &v1.CreateUserRequest{Email: "u3@trove.io", Role: "root"}); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("CreateUser() error = %v, want %v", err, ErrInvalidRole)
+	}
+	if _, err = svc.SetRole(ctx, "u1", "root"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("SetRole() error = %v, want %v", err, ErrInvalidRole)
+	}
+	info, err := svc.SetRole(ctx, "u2", model.RoleAdmin)
+	if err != nil || info.Role != model.RoleAdmin || repo.users["u2"].Role != model.RoleAdmin {
+		t.Errorf("SetRole() = %+v, %v, want admin", info, err)
+	}
+	if _, err = svc.SetRole(ctx, "u9", model.RoleAdmin); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetRole() of unknown user error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	list, err := svc.ListUsers(ctx, &v1.ListUsersRequest{Offset: 1})
+	if err != nil || list.Total != 2 || len(list.Users) != 1 || list.Users[0].UserId != "u2" {
+		t.Errorf("ListUsers() = %+v, %v, want u2 of 2", list, err)
+	}
+
+	token, err := svc.IssueToken(ctx, "u1", time.Minute)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	claims, err := jwt.NewJwt("test-key").ParseToken(token)
+	if err != nil || claims.UserId != "u1" || time.Until(claims.ExpiresAt.Time) > time.Minute {
+		t.Errorf("issued token claims = %+v, %v, want u1 for a minute", claims, err)
+	}
+
+	if info, err = svc.DisableUser(ctx, "u1"); err != nil || info.Status != model.StatusDisabled {
+		t.Fatalf("DisableUser() = %+v, %v, want disabled", info, err)
+	}
+	if _, err = svc.Login(ctx, &v1.LoginRequest{Email: "u1@trove.io", Password: "secret"}); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("Login() of disabled user error = %v, want %v", err, ErrUserDisabled)
+	}
+	if recorder.failures[metrics.ReasonUserDisabled] != 1 {
+		t.Errorf("failures[%s] = %d, want 1", metrics.ReasonUserDisabled, recorder.failures[metrics.ReasonUserDisabled])
+	}
+	if _, err = svc.IssueToken(ctx, "u1", 0); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("IssueToken() of disabled user error = %v, want %v", err, ErrUserDisabled)
+	}
+}
+
+func TestUserService_UpdateProfile(t *testing.T) {
+	repo := &fakeUserRepo{users: map[string]*model.User{
+		"u1": {UserID: "u1", Email: "u1@trove.io", Version: 3},
+	}}
+	recorder := &fakeRecorder{Recorder: metrics.NewNopRecorder(), failures: map[string]int{}}
+	svc := NewUserService(NewService(nil, jwt.NewJwt("test-key"), nil, recorder), repo, nil)
+	req := &v1.UpdateProfileRequest{Email: "u1@trove.io", Nickname: "one"}
+
+	tests := []struct {
+		name    string
+		version int64
+		want    int64
+		err     error
+	}{
+		{"at the version", 3, 4, nil},
+		{"stale version", 3, 0, ErrUserConflict},
+		{"any version", 0, 5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := svc.UpdateProfile(context.Background(), "u1", tt.version, req)
+			if version != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("UpdateProfile() = %d, %v, want %d, %v", version, err, tt.want, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package slo
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// Collector exports the SLIs of a Tracker, they are computed at scrape time
+type Collector struct {
+	tracker   *Tracker
+	ratio     *prometheus.Desc
+	burnRate  *prometheus.Desc
+	objective *prometheus.Desc
+	firing    *prometheus.Desc
+}
+
+// NewCollector creates a new Collector of the tracker
+func NewCollector(tracker *Tracker) *Collector {
+	name := func(metric string) string {
+		return prometheus.BuildFQName(version.Namespace(), "slo", metric)
+	}
+	return &Collector{
+		tracker: tracker,
+		ratio: prometheus.NewDesc(name("sli_ratio"),
+			"Ratio of good requests over the window, with labels group, sli and window.",
+			[]string{"group", "sli", "window"}, nil),
+		burnRate: prometheus.NewDesc(name("burn_rate"),
+			"Error budget burn rate over the window, with labels group, sli and window.",
+			[]string{"group", "sli", "window"}, nil),
+		objective: prometheus.NewDesc(name("objective_ratio"),
+			"Target ratio of good requests, with labels group and sli.",
+			[]string{"group", "sli"}, nil),
+		firing: prometheus.NewDesc(name("alert_firing"),
+			"Whether the multi-window burn rate alert fires, with labels group, sli and severity.",
+			[]string{"group", "sli", "severity"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.ratio
+	ch <- c.burnRate
+	ch <- c.objective
+	ch <- c.firing
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, report := range c.tracker.Report() {
+		for sli, rep := range report.SLIs {
+			ch <- prometheus.MustNewConstMetric(c.objective, prometheus.GaugeValue, rep.Target, report.Group, sli)
+			for _, w := range rep.Windows {
+				ch <- prometheus.MustNewConstMetric(c.ratio, prometheus.GaugeValue, w.Ratio, report.Group, sli, w.Window)
+				ch <- prometheus.MustNewConstMetric(c.burnRate, prometheus.GaugeValue, w.BurnRate, report.Group, sli, w.Window)
+			}
+			for _, a := range rep.Alerts {
+				firing := 0.0
+				if a.Firing {
+					firing = 1
+				}
+				ch <- prometheus.MustNewConstMetric(c.firing, prometheus.GaugeValue, firing, report.Group, sli, a.Severity)
+			}
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package slo
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SLI Names
+const (
+	Availability = "availability"
+	Latency      = "latency"
+)
+
+// Window is a named lookback window of the burn rate
+type Window struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"-"`
+}
+
+// Alert pairs a long and a short window, it fires only when both
+// windows burn the error budget faster than the threshold.
+type Alert struct {
+	Severity  string  `json:"severity"`
+	Long      Window  `json:"long"`
+	Short     Window  `json:"short"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Default Windows and Alerts of the multi-window burn rate
+var (
+	Windows = []Window{
+		{"5m", 5 * time.Minute},
+		{"30m", 30 * time.Minute},
+		{"1h", time.Hour},
+		{"6h", 6 * time.Hour},
+	}
+	Alerts = []Alert{
+		{Severity: "page", Long: Windows[2], Short: Windows[0], Threshold: 14.4},
+		{Severity: "ticket", Long: Windows[3], Short: Windows[1], Threshold: 6},
+	}
+)
+
+// bucketSize is the resolution of the recorded events
+const bucketSize = time.Minute
+
+// Objective is the SLO of a route group
+type Objective struct {
+	Group  string
+	Routes []string
+
+	// Availability is the target ratio of requests served without a 5xx
+	Availability float64
+	// Latency is the target ratio of requests faster than LatencyThreshold
+	Latency          float64
+	LatencyThreshold time.Duration
+}
+
+// Tracker records request outcomes per route group and computes SLIs
+type Tracker struct {
+	mu         sync.Mutex
+	objectives []Objective
+	series     map[string]*ring
+	now        func() time.Time
+}
+
+// Option setup the tracker
+type Option func(t *Tracker)
+
+// WithClock setup the clock of the tracker
+func WithClock(now func() time.Time) Option {
+	return func(t *Tracker) { t.now = now }
+}
+
+// NewTracker creates a new Tracker for the given objectives
+func NewTracker(objectives []Objective, opts ...Option) *Tracker {
+	t := &Tracker{
+		objectives: objectives,
+		series:     make(map[string]*ring, len(objectives)),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	size := int(Windows[len(Windows)-1].Duration / bucketSize)
+	for _, o := range objectives {
+		t.series[o.Group] = newRing(size)
+	}
+	return t
+}
+
+// Group returns the route group of the given route path,
+// the longest matching route prefix wins.
+func (t *Tracker) Group(route string) (string, bool) {
+	group, longest := "", -1
+	for _, o := range t.objectives {
+		for _, prefix := range o.Routes {
+			if matchRoute(route, prefix) && len(prefix) > longest {
+				group, longest = o.Group, len(prefix)
+			}
+		}
+	}
+	return group, longest >= 0
+}
+
+// matchRoute reports whether the route is the prefix or below it, whole
+// path segments only so /v1/users is not under /v1/user.
+func matchRoute(route, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return route == prefix || strings.HasPrefix(route, prefix+"/")
+}
+
+// Record records a request outcome of the route group
+func (t *Tracker) Record(group string, failed bool, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.series[group]
+	if !ok {
+		return
+	}
+	o := t.objective(group)
+	slow := o.LatencyThreshold > 0 && latency > o.LatencyThreshold
+	r.add(t.now().Truncate(bucketSize).Unix(), failed, slow)
+}
+
+// WindowReport is the SLI of a window
+type WindowReport struct {
+	Window   string  `json:"window"`
+	Total    uint64  `json:"total"`
+	Bad      uint64  `json:"bad"`
+	Ratio    float64 `json:"ratio"`
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// AlertReport is the state of a multi-window alert
+type AlertReport struct {
+	Alert
+	Firing bool `json:"firing"`
+}
+
+// SLIReport is the SLI of an objective over all windows
+type SLIReport struct {
+	Target  float64        `json:"target"`
+	Windows []WindowReport `json:"windows"`
+	Alerts  []AlertReport  `json:"alerts"`
+}
+
+// Report is the SLIs of a route group
+type Report struct {
+	Group            string               `json:"group"`
+	Routes           []string             `json:"routes"`
+	LatencyThreshold string               `json:"latency_threshold"`
+	SLIs             map[string]SLIReport `json:"slis"`
+}
+
+// Report computes the SLIs of all route groups
+func (t *Tracker) Report() []Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now().Truncate(bucketSize).Unix()
+	reports := make([]Report, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		r := t.series[o.Group]
+		reports = append(reports, Report{
+			Group:            o.Group,
+			Routes:           o.Routes,
+			LatencyThreshold: o.LatencyThreshold.String(),
+			SLIs: map[string]SLIReport{
+				Availability: r.report(now, o.Availability, func(c counts) uint64 { return c.failed }),
+				Latency:      r.report(now, o.Latency, func(c counts) uint64 { return c.slow }),
+			},
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Group < reports[j].Group })
+	return reports
+}
+
+func (t *Tracker) objective(group string) Objective {
+	for _, o := range t.objectives {
+		if o.Group == group {
+			return o
+		}
+	}
+	return Objective{}
+}
+
+type counts struct {
+	total  uint64
+	failed uint64
+	slow   uint64
+}
+
+type bucket struct {
+	minute int64
+	counts
+}
+
+// ring keeps per-minute counts of the longest window
+type ring struct {
+	buckets []bucket
+}
+
+func newRing(size int) *ring {
+	return &ring{buckets: make([]bucket, size)}
+}
+
+func (r *ring) add(minute int64, failed, slow bool) {
+	b := &r.buckets[int(minute/60)%len(r.buckets)]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+func (r *ring) sum(now int64, window time.Duration) counts {
+	var c counts
+	since := now - int64(window/time.Second)
+	for _, b := range r.buckets {
+		if b.minute > since && b.minute <= now {
+			c.total += b.total
+			c.failed += b.failed
+			c.slow += b.slow
+		}
+	}
+	return c
+}
+
+func (r *ring) report(now int64, target float64, bad func(counts) uint64) SLIReport {
+	rep := SLIReport{Target: target}
+	rates := make(map[string]float64, len(Windows))
+	for _, w := range Windows {
+		c := r.sum(now, w.Duration)
+		wr := WindowReport{Window: w.Name, Total: c.total, Bad: bad(c), Ratio: 1}
+		if c.total > 0 {
+			wr.Ratio = 1 - float64(wr.Bad)/float64(c.total)
+		}
+		wr.BurnRate = BurnRate(wr.Ratio, target)
+		rates[w.Name] = wr.BurnRate
+		rep.Windows = append(rep.Windows, wr)
+	}
+	for _, a := range Alerts {
+		rep.Alerts = append(rep.Alerts, AlertReport{
+			Alert:  a,
+			Firing: rates[a.Long.Name] > a.Threshold && rates[a.Short.Name] > a.Threshold,
+		})
+	}
+	return rep
+}
+
+// BurnRate returns how fast the error budget of the target is consumed,
+// a burn rate of 1 consumes exactly the whole budget over the SLO period.
+func BurnRate(ratio, target float64) float64 {
+	budget := 1 - target
+	if budget <= 0 {
+		return 0
+	}
+	return (1 - ratio) / budget
+}
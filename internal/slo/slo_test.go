@@ -0,0 +1,115 @@
+package slo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTracker_Group(t *testing.T) {
+	tracker := NewTracker([]Objective{
+		{Group: "v1", Routes: []string{"/v1"}},
+		{Group: "user", Routes: []string{"/v1/user"}},
+		{Group: "token", Routes: []string{"/v1/token/"}},
+	})
+	tests := []struct {
+		route string
+		want  string
+		ok    bool
+	}{
+		{"/v1/login", "v1", true},
+		{"/v1/user", "user", true},
+		{"/v1/user/avatar", "user", true},
+		{"/v1/users", "v1", true},
+		{"/v1/token/refresh", "token", true},
+		{"/v1/tokens", "v1", true},
+		{"/v1x", "", false},
+		{"/healthz", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			got, ok := tracker.Group(tt.route)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Group(%q) = %q, %v, want %q, %v", tt.route, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTracker_Report(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker([]Objective{{
+		Group:            "user",
+		Routes:           []string{"/v1/user"},
+		Availability:     0.99,
+		Latency:          0.9,
+		LatencyThreshold: 100 * time.Millisecond,
+	}}, WithClock(func() time.Time { return now }))
+
+	// * Two hours ago: 100 requests, all good.
+	now = now.Add(-2 * time.Hour)
+	for range 100 {
+		tracker.Record("user", false, 10*time.Millisecond)
+	}
+	// * Now: 100 requests, 20 failed and 50 slow.
+	now = now.Add(2 * time.Hour)
+	for i := range 100 {
+		tracker.Record("user", i < 20, time.Duration(i)*3*time.Millisecond)
+	}
+
+	reports := tracker.Report()
+	if len(reports) != 1 {
+		t.Fatalf("Report() groups = %d, want 1", len(reports))
+	}
+
+	availability := reports[0].SLIs[Availability]
+	tests := []struct {
+		window string
+		total  uint64
+		ratio  float64
+		burn   float64
+	}{
+		{"5m", 100, 0.8, 20},
+		{"1h", 100, 0.8, 20},
+		{"6h", 200, 0.9, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			for _, w := range availability.Windows {
+				if w.Window != tt.window {
+					continue
+				}
+				if w.Total != tt.total || !near(w.Ratio, tt.ratio) || !near(w.BurnRate, tt.burn) {
+					t.Errorf("window %s = %+v, want total %d ratio %v burn %v",
+						tt.window, w, tt.total, tt.ratio, tt.burn)
+				}
+				return
+			}
+			t.Errorf("window %s missing", tt.window)
+		})
+	}
+
+	firing := map[string]bool{}
+	for _, a := range availability.Alerts {
+		firing[a.Severity] = a.Firing
+	}
+	if !firing["page"] || !firing["ticket"] {
+		t.Errorf("availability alerts = %v, want page and ticket firing", firing)
+	}
+
+	latency := reports[0].SLIs[Latency]
+	if got := latency.Windows[0]; got.Bad != 66 {
+		t.Errorf("latency bad requests = %d, want 66", got.Bad)
+	}
+}
+
+func TestBurnRate(t *testing.T) {
+	if got := BurnRate(0.999, 0.999); !near(got, 1) {
+		t.Errorf("BurnRate at target = %v, want 1", got)
+	}
+	if got := BurnRate(0.5, 1); got != 0 {
+		t.Errorf("BurnRate without budget = %v, want 0", got)
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
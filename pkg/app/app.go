@@ -15,8 +15,10 @@ type Option func(a *App)
 
 // App stands for application
 type App struct {
-	name    string
-	servers []server.Server
+	name       string
+	servers    []server.Server
+	beforeStop []func(context.Context) error
+	reload     []func(context.Context) error
 }
 
 // NewApp initializes a new App instance with options
@@ -38,6 +40,16 @@ func WithName(name string) Option {
 	return func(a *App) { a.name = name }
 }
 
+// WithBeforeStop sets the hooks run before servers are stopped
+func WithBeforeStop(fns ...func(context.Context) error) Option {
+	return func(a *App) { a.beforeStop = append(a.beforeStop, fns...) }
+}
+
+// WithReload sets the hooks run when the App receives SIGHUP
+func WithReload(fns ...func(context.Context) error) Option {
+	return func(a *App) { a.reload = append(a.reload, fns...) }
+}
+
 // Run bootstraps the application and all servers
 func (a *App) Run(ctx context.Context) error {
 	var cancel context.CancelFunc
@@ -46,6 +58,10 @@ func (a *App) Run(ctx context.Context) error {
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go a.watchReload(ctx, hup)
 	for _, serv := range a.servers {
 		go func(serv server.Server) {
 			if err := serv.Start(ctx); err != nil {
@@ -61,6 +77,11 @@ func (a *App) Run(ctx context.Context) error {
 		slog.Info("Server context canceled")
 	}
 
+	for _, fn := range a.beforeStop {
+		if err := fn(context.WithoutCancel(ctx)); err != nil {
+			slog.Error("Before stop hook failed", "error", err)
+		}
+	}
 	for _, serv := range a.servers {
 		if err := serv.Stop(ctx); err != nil {
 			slog.Error("Server stop failed", "error", err)
@@ -68,3 +89,19 @@ func (a *App) Run(ctx context.Context) error {
 	}
 	return nil
 }
+
+func (a *App) watchReload(ctx context.Context, hup <-chan os.Signal) {
+	for {
+		select {
+		case <-hup:
+			slog.Info("Received SIGHUP, reloading...")
+			for _, fn := range a.reload {
+				if err := fn(ctx); err != nil {
+					slog.Error("Reload hook failed", "error", err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// busBuffer is how many payloads a subscription holds before the
+// publisher waits
+const busBuffer = 64
+
+// Bus broadcasts payloads to every subscribed instance
+type Bus interface {
+	// Publish sends the payload to the subscriptions, the ones of the
+	// publisher included.
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe delivers the payloads published from now on, the channel
+	// closes when ctx is done or the subscription is lost.
+	Subscribe(ctx context.Context) (<-chan []byte, error)
+}
+
+// MemoryBus is a Bus within the process, the instances of a test share
+// one to talk to each other.
+type MemoryBus struct {
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+	err  error
+}
+
+// NewMemoryBus creates a MemoryBus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[chan []byte]struct{})}
+}
+
+// Publish sends the payload to the subscriptions
+func (b *MemoryBus) Publish(ctx context.Context, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- slices.Clone(payload):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe delivers the payloads until ctx is done
+func (b *MemoryBus) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	ch := make(chan []byte, busBuffer)
+	b.subs[ch] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.unsubscribe(ch)
+	}()
+	return ch, nil
+}
+
+// Fail takes the bus down with err, the subscriptions are lost and the
+// calls fail until Fail(nil) brings it back.
+func (b *MemoryBus) Fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+	if err != nil {
+		for ch := range b.subs {
+			b.unsubscribe(ch)
+		}
+	}
+}
+
+func (b *MemoryBus) unsubscribe(ch chan []byte) {
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// RedisBus is a Bus on a Redis pub/sub channel
+type RedisBus struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewRedisBus creates a RedisBus on the channel
+func NewRedisBus(client redis.UniversalClient, channel string) *RedisBus {
+	return &RedisBus{client: client, channel: channel}
+}
+
+// Publish sends the payload to the channel
+func (b *RedisBus) Publish(ctx context.Context, payload []byte) error {
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Subscribe delivers the payloads of the channel, a connection failure
+// closes the subscription rather than reconnecting, so that the missed
+// payloads are noticed.
+func (b *RedisBus) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	sub := b.client.Subscribe(ctx, b.channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	ch := make(chan []byte, busBuffer)
+	go func() {
+		defer close(ch)
+		defer sub.Close()
+		for {
+			msg, err := sub.ReceiveMessage(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- []byte(msg.Payload):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
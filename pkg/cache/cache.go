@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned when the cache has no value for a key
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores the encoded values by key for a while, a zero ttl keeps a
+// value until it is evicted.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Writer is a Cache telling the writes apart from the fills of the
+// lookups, a write evicts the copies the other instances keep.
+type Writer interface {
+	Write(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Expirer is a Cache returning the remaining ttl of a value with it,
+// zero for a value kept until it is evicted.
+type Expirer interface {
+	GetTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+var errNotFound = errors.New("not found")
+
+type item struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return NewRedis(client), server
+}
+
+func TestCache(t *testing.T) {
+	lru := NewLRU(10)
+	now := time.Now()
+	lru.now = func() time.Time { return now }
+	rdb, server := newRedis(t)
+
+	tests := []struct {
+		name    string
+		cache   Cache
+		advance func(d time.Duration)
+	}{
+		{"lru", lru, func(d time.Duration) { now = now.Add(d) }},
+		{"redis", rdb, server.FastForward},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := tt.cache.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+				t.Errorf("Get() of a missing key error = %v, want ErrMiss", err)
+			}
+			_ = tt.cache.Set(ctx, "a", []byte("1"), time.Second)
+			_ = tt.cache.Set(ctx, "b", []byte("2"), 0)
+			if got, err := tt.cache.Get(ctx, "a"); err != nil || string(got) != "1" {
+				t.Errorf("Get(a) = %s, %v, want 1", got, err)
+			}
+			if _, ttl, err := tt.cache.(Expirer).GetTTL(ctx, "a"); err != nil || ttl <= 0 || ttl > time.Second {
+				t.Errorf("GetTTL(a) ttl = %v, %v, want at most 1s", ttl, err)
+			}
+			if _, ttl, err := tt.cache.(Expirer).GetTTL(ctx, "b"); err != nil || ttl != 0 {
+				t.Errorf("GetTTL(b) without ttl = %v, %v, want 0", ttl, err)
+			}
+
+			tt.advance(time.Second * 2)
+			if _, err := tt.cache.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+				t.Errorf("Get() of an expired key error = %v, want ErrMiss", err)
+			}
+			if got, err := tt.cache.Get(ctx, "b"); err != nil || string(got) != "2" {
+				t.Errorf("Get(b) without ttl = %s, %v, want 2", got, err)
+			}
+			if err := tt.cache.Delete(ctx, "b", "c"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err := tt.cache.Get(ctx, "b"); !errors.Is(err, ErrMiss) {
+				t.Errorf("Get() of a deleted key error = %v, want ErrMiss", err)
+			}
+		})
+	}
+}
+
+func TestLRU_Evict(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(2)
+	_ = lru.Set(ctx, "a", []byte("1"), 0)
+	_ = lru.Set(ctx, "b", []byte("2"), 0)
+	_, _ = lru.Get(ctx, "a")
+	_ = lru.Set(ctx, "c", []byte("3"), 0)
+
+	if _, err := lru.Get(ctx, "b"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get() of the least recently used key error = %v, want ErrMiss", err)
+	}
+	if _, err := lru.Get(ctx, "a"); err != nil {
+		t.Errorf("Get() of a recently used key error = %v", err)
+	}
+	if lru.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", lru.Len())
+	}
+}
+
+func TestTyped_Fetch(t *testing.T) {
+	rdb, server := newRedis(t)
+	metrics := NewMetrics(prometheus.NewRegistry())
+	items := NewTyped[*item](rdb, "items", time.Minute, WithVersion(2), WithNegative(errNotFound, time.Second), WithMetrics(metrics))
+	ctx := context.Background()
+
+	// * Concurrent misses share one load.
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (*item, error) {
+		loads.Add(1)
+		<-release
+		return &item{Name: "a", Count: 1}, nil
+	}
+	var wg sync.WaitGroup
+	got := make([]*item, 5)
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], _ = items.Fetch(ctx, "a", load)
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	wg.Wait()
+	if loads.Load() != 1 {
+		t.Errorf("loads = %d, want 1", loads.Load())
+	}
+	got[0].Count = 9
+	if got[1].Count != 1 {
+		t.Errorf("fetched values share the load, want a value of their own")
+	}
+
+	if !server.Exists("items:v2:a") {
+		t.Errorf("keys = %v, want items:v2:a", server.Keys())
+	}
+	v, err := items.Fetch(ctx, "a", func(context.Context) (*item, error) { return nil, errors.New("loaded") })
+	if err != nil || v.Name != "a" {
+		t.Errorf("Fetch() of a cached key = %+v, %v", v, err)
+	}
+
+	// * A not found is cached for the negative ttl.
+	missing := func(context.Context) (*item, error) { loads.Add(1); return nil, errNotFound }
+	loads.Store(0)
+	for range 2 {
+		if _, err = items.Fetch(ctx, "b", missing); !errors.Is(err, errNotFound) {
+			t.Errorf("Fetch() of a missing key error = %v, want errNotFound", err)
+		}
+	}
+	server.FastForward(time.Second * 2)
+	_, _ = items.Fetch(ctx, "b", missing)
+	if loads.Load() != 2 {
+		t.Errorf("loads of a missing key = %d, want 2", loads.Load())
+	}
+
+	// * Other versions never read the entries.
+	v1 := NewTyped[*item](rdb, "items", time.Minute)
+	if _, err = v1.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get() of another version error = %v, want ErrMiss", err)
+	}
+	if err = items.Delete(ctx, "a"); err != nil || server.Exists("items:v2:a") {
+		t.Errorf("Delete() = %v, want items:v2:a gone", err)
+	}
+
+	for result, want := range map[string]float64{ResultHit: 1, ResultNegative: 1, ResultMiss: 7} {
+		if got := testutil.ToFloat64(metrics.lookups.WithLabelValues("items", result)); got != want {
+			t.Errorf("lookups{result=%s} = %v, want %v", result, got, want)
+		}
+	}
+}
+
+func TestTyped_Unavailable(t *testing.T) {
+	rdb, server := newRedis(t)
+	items := NewTyped[item](rdb, "items", time.Minute)
+	server.Close()
+
+	// * The load answers while the cache is down.
+	v, err := items.Fetch(context.Background(), "a", func(context.Context) (item, error) {
+		return item{Name: "a"}, nil
+	})
+	if err != nil || v.Name != "a" {
+		t.Errorf("Fetch() with the cache down = %+v, %v, want the loaded value", v, err)
+	}
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LRU is an in-memory Cache evicting the least recently used value
+// beyond its size.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU creates an LRU of at most size values
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  max(size, 1),
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Get returns the value of the key
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, error) {
+	value, _, err := c.GetTTL(ctx, key)
+	return value, err
+}
+
+// GetTTL returns the value of the key and its remaining ttl
+func (c *LRU) GetTTL(_ context.Context, key string) ([]byte, time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, 0, ErrMiss
+	}
+	entry := elem.Value.(*lruEntry)
+	var ttl time.Duration
+	if !entry.expires.IsZero() {
+		if ttl = entry.expires.Sub(c.now()); ttl <= 0 {
+			c.remove(elem)
+			return nil, 0, ErrMiss
+		}
+	}
+	c.order.MoveToFront(elem)
+	return slices.Clone(entry.value), ttl, nil
+}
+
+// Set stores the value of the key for the ttl
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := &lruEntry{key: key, value: slices.Clone(value)}
+	if ttl > 0 {
+		entry.expires = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return nil
+	}
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Delete removes the values of the keys
+func (c *LRU) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+	}
+	return nil
+}
+
+// Clear removes every value
+func (c *LRU) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.items)
+}
+
+// Len returns the number of values, the expired ones included
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// Lookup Results
+const (
+	ResultHit      = "hit"
+	ResultNegative = "negative"
+	ResultMiss     = "miss"
+	ResultError    = "error"
+)
+
+// Metrics counts the lookups of the typed caches
+type Metrics struct {
+	lookups *prometheus.CounterVec
+}
+
+// NewMetrics creates the Metrics registered on the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{}
+	m.lookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "cache",
+		Name:      "lookups_total",
+		Help:      "How many cache lookups were made, with labels cache and result, hit, negative, miss or error.",
+	}, []string{"cache", "result"})
+	reg.MustRegister(m.lookups)
+	return m
+}
+
+func (m *Metrics) observe(cache, result string) {
+	if m != nil {
+		m.lookups.WithLabelValues(cache, result).Inc()
+	}
+}
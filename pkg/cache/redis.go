@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Cache on a Redis server shared by the instances
+type Redis struct {
+	client redis.UniversalClient
+}
+
+// NewRedis creates a Redis cache on the client
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{client: client}
+}
+
+// Get returns the value of the key
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// GetTTL returns the value of the key and its remaining ttl
+func (c *Redis) GetTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get, pttl = pipe.Get(ctx, key), pipe.PTTL(ctx, key)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, 0, ErrMiss
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	value, err := get.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	// * A key without an expiry has a negative ttl.
+	return value, max(pttl.Val(), 0), nil
+}
+
+// Set stores the value of the key for the ttl
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Delete removes the values of the keys
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// Ping checks the Redis server answers
+func (c *Redis) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
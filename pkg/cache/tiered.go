@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// protocolVersion of the invalidation messages, a message of another
+// version clears the near tier since its keys cannot be read.
+const protocolVersion = 1
+
+// invalidation is the message broadcast by a write
+type invalidation struct {
+	Version int      `json:"v"`
+	Origin  string   `json:"origin"`
+	Keys    []string `json:"keys"`
+}
+
+// TieredOption configures a Tiered cache
+type TieredOption func(*tieredOptions)
+
+type tieredOptions struct {
+	nearTTL     time.Duration
+	fallbackTTL time.Duration
+	retry       time.Duration
+}
+
+// WithNearTTL bounds how long the near tier keeps a copy while the bus is up
+func WithNearTTL(ttl time.Duration) TieredOption {
+	return func(o *tieredOptions) { o.nearTTL = ttl }
+}
+
+// WithFallbackTTL bounds how long the near tier keeps a copy while the bus
+// is down, the invalidations of the other instances are missed then.
+func WithFallbackTTL(ttl time.Duration) TieredOption {
+	return func(o *tieredOptions) { o.fallbackTTL = ttl }
+}
+
+// WithRetry sets the interval between the subscriptions to a down bus
+func WithRetry(interval time.Duration) TieredOption {
+	return func(o *tieredOptions) { o.retry = interval }
+}
+
+// Tiered is a Cache keeping near copies of the shared entries in process.
+// The writes and the deletes broadcast an invalidation on the bus so that
+// every instance evicts its copy, while the bus is down the copies only
+// live for the fallback ttl. A copy never outlives the shared value of an
+// Expirer shared tier.
+type Tiered struct {
+	near   *LRU
+	shared Cache
+	bus    Bus
+	origin string
+	opts   tieredOptions
+
+	up atomic.Bool
+	// * gen counts the evictions, a read racing one drops its copy.
+	gen atomic.Uint64
+}
+
+// NewTiered creates a Tiered cache, Run receives the invalidations
+func NewTiered(near *LRU, shared Cache, bus Bus, opts ...TieredOption) *Tiered {
+	o := tieredOptions{nearTTL: time.Minute, fallbackTTL: time.Second * 5, retry: time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	origin := make([]byte, 8)
+	_, _ = rand.Read(origin)
+	return &Tiered{near: near, shared: shared, bus: bus, origin: hex.EncodeToString(origin), opts: o}
+}
+
+// Get returns the near copy of the key, else the shared value
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, err := t.near.Get(ctx, key); err == nil {
+		return value, nil
+	}
+	gen := t.gen.Load()
+	value, ttl, err := t.getShared(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	near := t.nearTTL()
+	if ttl > 0 {
+		near = min(near, ttl)
+	}
+	_ = t.near.Set(ctx, key, value, near)
+	if t.gen.Load() != gen {
+		_ = t.near.Delete(ctx, key)
+	}
+	return value, nil
+}
+
+// getShared returns the shared value of the key and its remaining ttl,
+// zero when the shared tier does not tell.
+func (t *Tiered) getShared(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	if e, ok := t.shared.(Expirer); ok {
+		return e.GetTTL(ctx, key)
+	}
+	value, err := t.shared.Get(ctx, key)
+	return value, 0, err
+}
+
+// Set stores the shared value of a lookup fill, the copies of the other
+// instances are left alone. Write broadcasts.
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	err := t.shared.Set(ctx, key, value, ttl)
+	t.evict(ctx, key)
+	return err
+}
+
+// Write stores the shared value of the key, the copies of every instance
+// are evicted.
+func (t *Tiered) Write(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	err := t.shared.Set(ctx, key, value, ttl)
+	t.invalidate(ctx, key)
+	return err
+}
+
+// Delete removes the shared values of the keys and their copies
+func (t *Tiered) Delete(ctx context.Context, keys ...string) error {
+	err := t.shared.Delete(ctx, keys...)
+	t.invalidate(ctx, keys...)
+	return err
+}
+
+// Ping checks the shared tier answers
+func (t *Tiered) Ping(ctx context.Context) error {
+	if pinger, ok := t.shared.(interface{ Ping(context.Context) error }); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Up reports whether the invalidations of the other instances are received
+func (t *Tiered) Up() bool {
+	return t.up.Load()
+}
+
+// Run receives the invalidations of the other instances until ctx is done,
+// a lost subscription is retried after the retry interval.
+func (t *Tiered) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		payloads, err := t.bus.Subscribe(ctx)
+		if err == nil {
+			t.setUp(true, nil)
+			for payload := range payloads {
+				t.receive(payload)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		t.setUp(false, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(t.opts.retry):
+		}
+	}
+}
+
+// setUp switches the bus state, the near tier is cleared since the copies
+// were kept for the ttl of the previous state or missed invalidations.
+func (t *Tiered) setUp(up bool, err error) {
+	if t.up.Swap(up) == up {
+		return
+	}
+	t.clear()
+	if up {
+		slog.Info("cache bus up, near copies are invalidated")
+		return
+	}
+	slog.Warn("cache bus down, near copies expire on ttl", "fallback_ttl", t.opts.fallbackTTL, "error", err)
+}
+
+func (t *Tiered) nearTTL() time.Duration {
+	if t.up.Load() {
+		return t.opts.nearTTL
+	}
+	return t.opts.fallbackTTL
+}
+
+// invalidate evicts the copies of the keys and broadcasts it, a failed
+// broadcast leaves the copies of the other instances until their ttl.
+func (t *Tiered) invalidate(ctx context.Context, keys ...string) {
+	t.evict(ctx, keys...)
+	payload, _ := json.Marshal(invalidation{Version: protocolVersion, Origin: t.origin, Keys: keys})
+	if err := t.bus.Publish(ctx, payload); err != nil {
+		slog.Warn("cache invalidation broadcast failed", "keys", keys, "error", err)
+	}
+}
+
+func (t *Tiered) receive(payload []byte) {
+	var msg invalidation
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Version != protocolVersion {
+		slog.Warn("cache invalidation unreadable, clearing near copies", "version", msg.Version, "error", err)
+		t.clear()
+		return
+	}
+	if msg.Origin != t.origin {
+		t.evict(context.Background(), msg.Keys...)
+	}
+}
+
+func (t *Tiered) evict(ctx context.Context, keys ...string) {
+	t.gen.Add(1)
+	_ = t.near.Delete(ctx, keys...)
+}
+
+func (t *Tiered) clear() {
+	t.gen.Add(1)
+	t.near.Clear()
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errBusDown = errors.New("bus down")
+
+// instance is a Tiered cache of one of the instances sharing a tier
+type instance struct {
+	*Tiered
+	now *time.Time
+}
+
+func newInstance(t *testing.T, shared Cache, bus Bus) instance {
+	t.Helper()
+	now := time.Now()
+	near := NewLRU(10)
+	near.now = func() time.Time { return now }
+	tiered := NewTiered(near, shared, bus, WithNearTTL(time.Minute), WithFallbackTTL(time.Second), WithRetry(time.Millisecond*10))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { defer close(done); tiered.Run(ctx) }()
+	t.Cleanup(func() { cancel(); <-done })
+	return instance{Tiered: tiered, now: &now}
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func get(t *testing.T, c Cache, key string) string {
+	t.Helper()
+	value, err := c.Get(context.Background(), key)
+	if err != nil {
+		return err.Error()
+	}
+	return string(value)
+}
+
+func TestTiered(t *testing.T) {
+	shared, bus := NewLRU(10), NewMemoryBus()
+	a, b := newInstance(t, shared, bus), newInstance(t, shared, bus)
+	eventually(t, "the subscriptions", func() bool { return a.Up() && b.Up() })
+	ctx := context.Background()
+
+	// * The near copy answers until a write of another instance.
+	_ = a.Set(ctx, "k", []byte("1"), 0)
+	if got := get(t, b, "k"); got != "1" {
+		t.Fatalf("Get() = %s, want 1", got)
+	}
+	_ = shared.Set(ctx, "k", []byte("behind"), 0)
+	if got := get(t, b, "k"); got != "1" {
+		t.Errorf("Get() of a near copy = %s, want 1", got)
+	}
+	_ = a.Write(ctx, "k", []byte("2"), 0)
+	eventually(t, "the invalidation", func() bool { return get(t, b, "k") == "2" })
+
+	// * A fill of another instance leaves the near copy.
+	_ = shared.Set(ctx, "k", []byte("behind"), 0)
+	_ = a.Set(ctx, "k", []byte("filled"), 0)
+	time.Sleep(time.Millisecond * 20)
+	if got := get(t, b, "k"); got != "2" {
+		t.Errorf("Get() after a fill of another instance = %s, want the near copy 2", got)
+	}
+
+	_ = a.Delete(ctx, "k")
+	eventually(t, "the deletion", func() bool { return get(t, b, "k") == ErrMiss.Error() })
+
+	// * A message of another version clears the near copies.
+	_ = b.Set(ctx, "k", []byte("3"), 0)
+	_ = get(t, b, "k")
+	_ = shared.Set(ctx, "k", []byte("4"), 0)
+	_ = bus.Publish(ctx, []byte(`{"v":2,"origin":"next","keys":["other"]}`))
+	eventually(t, "the clear", func() bool { return get(t, b, "k") == "4" })
+}
+
+func TestTiered_BusDown(t *testing.T) {
+	shared, bus := NewLRU(10), NewMemoryBus()
+	a, b := newInstance(t, shared, bus), newInstance(t, shared, bus)
+	eventually(t, "the subscriptions", func() bool { return a.Up() && b.Up() })
+	ctx := context.Background()
+
+	bus.Fail(errBusDown)
+	eventually(t, "the bus down", func() bool { return !a.Up() && !b.Up() })
+
+	// * The near copies expire on the fallback ttl.
+	_ = shared.Set(ctx, "k", []byte("1"), 0)
+	_ = get(t, b, "k")
+	if err := a.Write(ctx, "k", []byte("2"), 0); err != nil {
+		t.Fatalf("Write() with the bus down error = %v", err)
+	}
+	if got := get(t, b, "k"); got != "1" {
+		t.Errorf("Get() of a missed invalidation = %s, want the near copy 1", got)
+	}
+	*b.now = b.now.Add(time.Second * 2)
+	if got := get(t, b, "k"); got != "2" {
+		t.Errorf("Get() past the fallback ttl = %s, want 2", got)
+	}
+
+	// * The copies kept while down are dropped once back.
+	_ = shared.Set(ctx, "k", []byte("3"), 0)
+	bus.Fail(nil)
+	eventually(t, "the bus up", func() bool { return a.Up() && b.Up() })
+	if got := get(t, b, "k"); got != "3" {
+		t.Errorf("Get() after the bus is back = %s, want 3", got)
+	}
+}
+
+func TestTiered_SharedTTL(t *testing.T) {
+	shared, bus := NewLRU(10), NewMemoryBus()
+	a := newInstance(t, shared, bus)
+	shared.now = func() time.Time { return *a.now }
+	ctx := context.Background()
+
+	// * The near copy expires with the shared value.
+	_ = a.Set(ctx, "k", []byte("1"), time.Second*2)
+	if got := get(t, a, "k"); got != "1" {
+		t.Fatalf("Get() = %s, want 1", got)
+	}
+	*a.now = a.now.Add(time.Second * 3)
+	if got := get(t, a, "k"); got != ErrMiss.Error() {
+		t.Errorf("Get() past the shared ttl = %s, want a miss", got)
+	}
+}
+
+func TestRedisBus(t *testing.T) {
+	rdb, server := newRedis(t)
+	bus := NewRedisBus(rdb.client, "invalidate")
+	ctx := context.Background()
+
+	payloads, err := bus.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err = bus.Publish(ctx, []byte("k")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := <-payloads; string(got) != "k" {
+		t.Errorf("payload = %s, want k", got)
+	}
+
+	// * A lost connection closes the subscription.
+	server.Close()
+	select {
+	case _, ok := <-payloads:
+		if ok {
+			t.Errorf("payload after the server closed, want the subscription closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("subscription still open after the server closed")
+	}
+	if _, err = bus.Subscribe(ctx); err == nil {
+		t.Errorf("Subscribe() with the server closed error = nil")
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Entry Markers
+const (
+	markValue    = '+'
+	markNegative = '-'
+)
+
+// Option configures a Typed cache
+type Option func(*options)
+
+type options struct {
+	version     int
+	negative    error
+	negativeTTL time.Duration
+	metrics     *Metrics
+}
+
+// WithVersion sets the version of the keys, bumping it when the cached
+// type changes ignores the entries of the previous one.
+func WithVersion(version int) Option {
+	return func(o *options) { o.version = version }
+}
+
+// WithNegative caches the loads failing with err for the ttl, a lookup
+// of the key returns err until then.
+func WithNegative(err error, ttl time.Duration) Option {
+	return func(o *options) { o.negative, o.negativeTTL = err, ttl }
+}
+
+// WithMetrics counts the lookups
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// Typed is a cache-aside of the values of type T on a Cache, the values
+// are JSON encoded under <name>:v<version>:<key>.
+type Typed[T any] struct {
+	cache Cache
+	name  string
+	ttl   time.Duration
+	opts  options
+	group singleflight.Group
+}
+
+// NewTyped creates the named Typed cache keeping the values for the ttl
+func NewTyped[T any](c Cache, name string, ttl time.Duration, opts ...Option) *Typed[T] {
+	o := options{version: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Typed[T]{cache: c, name: name, ttl: ttl, opts: o}
+}
+
+// Key returns the cache key of the key
+func (t *Typed[T]) Key(key string) string {
+	return fmt.Sprintf("%s:v%d:%s", t.name, t.opts.version, key)
+}
+
+// Get returns the value of the key, ErrMiss or the negative error
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, error) {
+	var v T
+	data, err := t.cache.Get(ctx, t.Key(key))
+	switch {
+	case errors.Is(err, ErrMiss):
+		t.opts.metrics.observe(t.name, ResultMiss)
+		return v, ErrMiss
+	case err != nil:
+		t.opts.metrics.observe(t.name, ResultError)
+		return v, err
+	case len(data) > 0 && data[0] == markNegative && t.opts.negative != nil:
+		t.opts.metrics.observe(t.name, ResultNegative)
+		return v, t.opts.negative
+	case len(data) == 0 || data[0] != markValue:
+		t.opts.metrics.observe(t.name, ResultError)
+		return v, fmt.Errorf("cache %s: malformed entry of %s", t.name, key)
+	}
+	if err = json.Unmarshal(data[1:], &v); err != nil {
+		t.opts.metrics.observe(t.name, ResultError)
+		return v, fmt.Errorf("cache %s: decode %s: %w", t.name, key, err)
+	}
+	t.opts.metrics.observe(t.name, ResultHit)
+	return v, nil
+}
+
+// Set writes the value of the key, a Writer cache evicts the copies the
+// other instances keep.
+func (t *Typed[T]) Set(ctx context.Context, key string, v T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	entry := append([]byte{markValue}, data...)
+	if w, ok := t.cache.(Writer); ok {
+		return w.Write(ctx, t.Key(key), entry, t.ttl)
+	}
+	return t.cache.Set(ctx, t.Key(key), entry, t.ttl)
+}
+
+// Delete removes the values of the keys
+func (t *Typed[T]) Delete(ctx context.Context, keys ...string) error {
+	full := make([]string, len(keys))
+	for i, key := range keys {
+		full[i] = t.Key(key)
+	}
+	return t.cache.Delete(ctx, full...)
+}
+
+// Fetch returns the value of the key, it loads and stores it on a miss.
+// The concurrent fetches of a key share one load. A failing cache falls
+// back to the load.
+func (t *Typed[T]) Fetch(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if v, err := t.Get(ctx, key); err == nil || (t.opts.negative != nil && errors.Is(err, t.opts.negative)) {
+		return v, err
+	}
+
+	// * The shared load hands out the encoded value, every caller decodes
+	// * a value of its own.
+	shared, err, _ := t.group.Do(t.Key(key), func() (any, error) {
+		v, err := load(ctx)
+		if err != nil {
+			if t.opts.negative != nil && errors.Is(err, t.opts.negative) && t.opts.negativeTTL > 0 {
+				t.store(ctx, key, []byte{markNegative}, t.opts.negativeTTL)
+			}
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		t.store(ctx, key, append([]byte{markValue}, data...), t.ttl)
+		return data, nil
+	})
+	var v T
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(shared.([]byte), &v)
+	return v, err
+}
+
+// store stores an entry of a load, a failure only costs the next lookup
+func (t *Typed[T]) store(ctx context.Context, key string, entry []byte, ttl time.Duration) {
+	if err := t.cache.Set(ctx, t.Key(key), entry, ttl); err != nil {
+		slog.Warn("cache store failed", "cache", t.name, "key", key, "error", err)
+	}
+}
@@ -1,29 +1,498 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
 )
 
-// NewConfig creates a new viper instance
-func NewConfig(p string) *viper.Viper {
-	envConf := os.Getenv("APP_CONF")
-	if envConf == "" {
-		envConf = p
+// Layout of the layered config files
+const (
+	// BaseFile is the base config of a config directory
+	BaseFile = "base.yaml"
+	// IncludeKey lists the files merged before the file declaring it,
+	// relative paths are resolved against the declaring file.
+	IncludeKey = "include"
+	// ReplaceTag marks a list or map replacing the value of the lower
+	// layers instead of being merged into it, e.g. `origins: !replace []`.
+	ReplaceTag = "!replace"
+	// localSuffix marks the uncommitted overrides of a layer,
+	// base.local.yaml overrides base.yaml for instance.
+	localSuffix = ".local"
+)
+
+// EnvProfile is the environment variable picking the profile overlay
+// when no profile is given, the env key of the base config otherwise.
+const EnvProfile = EnvPrefix + "_ENV"
+
+// replace wraps a value tagged with ReplaceTag
+type replace struct {
+	value any
+}
+
+// Source is a layered config, the base file and its local override,
+// then the profile overlay and its local override, each preceded by
+// its includes. Maps are merged deeply, lists are appended unless
+// tagged with ReplaceTag and scalars of upper layers win.
+type Source struct {
+	*viper.Viper
+
+	base    string
+	profile string
+
+	mu      sync.RWMutex
+	active  string
+	files   []string
+	watched []string
+	origins map[string][]string
+}
+
+// NewConfig loads the layered config of the path, a config directory
+// or its base file, with the given profile overlay. APP_CONF overrides
+// the path.
+func NewConfig(path, profile string) (*Source, error) {
+	if envConf := os.Getenv("APP_CONF"); envConf != "" {
+		path = envConf
+	}
+	s, err := NewSource(path, profile)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("loading config files from", "profile", s.Profile(), "files", s.Files())
+	return s, nil
+}
+
+// NewSource loads the layered config of the path with the given profile,
+// an empty profile falls back to APP_ENV and then to the env key.
+func NewSource(path, profile string) (*Source, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, BaseFile)
+	}
+	s := &Source{Viper: viper.New(), base: filepath.Clean(path), profile: profile}
+	s.SetConfigType("yaml")
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads all layers again and replaces the settings of viper
+func (s *Source) Reload() error {
+	next, err := s.Next()
+	if err != nil {
+		return err
+	}
+	s.Swap(next)
+	return nil
+}
+
+// Next reads all layers again into a new Source, the loaded settings,
+// files and origins stay in effect until Swap.
+func (s *Source) Next() (*Source, error) {
+	return NewSource(s.base, s.profile)
+}
+
+// Swap takes over the settings, files and origins of the next Source
+func (s *Source) Swap(next *Source) {
+	next.mu.RLock()
+	active, files, watched, origins := next.active, next.files, next.watched, next.origins
+	next.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Viper = next.Viper
+	s.active, s.files, s.watched, s.origins = active, files, watched, origins
+}
+
+// read merges all layers into the settings of viper
+func (s *Source) read() error {
+	l := &layers{merged: map[string]any{}, origins: map[string][]string{}}
+	if err := l.layer(s.base); err != nil {
+		return err
+	}
+
+	active := s.profile
+	if active == "" {
+		active = os.Getenv(EnvProfile)
+	}
+	if active == "" {
+		active, _ = l.merged["env"].(string)
+	}
+	if active != "" {
+		overlay := filepath.Join(filepath.Dir(s.base), active+filepath.Ext(s.base))
+		if overlay != s.base {
+			if err := l.layer(overlay); err != nil {
+				return fmt.Errorf("profile %s: %w", active, err)
+			}
+		}
+		l.merged["env"] = active
+		if s.profile != "" {
+			l.origins["env"] = []string{"--profile"}
+		}
+	}
+
+	data, err := yaml.Marshal(l.merged)
+	if err != nil {
+		return err
+	}
+	if err = s.ReadConfig(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active, s.files, s.watched, s.origins = active, l.files, l.watched, l.origins
+	return nil
+}
+
+// Profile returns the profile of the overlay in effect
+func (s *Source) Profile() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// Files returns the loaded files in merge order
+func (s *Source) Files() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.files)
+}
+
+// Origin returns where the effective value of a key came from, the
+// environment variable overriding it or the files setting it. Keys no
+// file sets have no origin, they are defaults.
+func (s *Source) Origin(key string) string {
+	key = strings.ToLower(key)
+	name := EnvPrefix + "_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	if os.Getenv(name) != "" {
+		return "env " + name
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return strings.Join(s.origins[key], ", ")
+}
+
+// Origins returns the files setting every key of the loaded files
+func (s *Source) Origins() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	origins := make(map[string]string, len(s.origins))
+	for key, files := range s.origins {
+		origins[key] = strings.Join(files, ", ")
+	}
+	return origins
+}
+
+// Watch calls fn whenever a layer file changes, local overrides and
+// overlays created later included, until stop is called.
+func (s *Source) Watch(fn func()) (stop func() error, err error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
 	}
-	slog.Info("loading config file from", "path", envConf)
-	return getConfig(envConf)
+	s.mu.RLock()
+	var dirs []string
+	for _, file := range s.watched {
+		if dir := filepath.Dir(file); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	s.mu.RUnlock()
+	for _, dir := range dirs {
+		if err = fw.Add(dir); err != nil {
+			_ = fw.Close()
+			return nil, err
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Chmod) || !s.watches(event.Name) {
+					continue
+				}
+				fn()
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("watch config files failed", "error", err)
+			}
+		}
+	}()
+	return fw.Close, nil
+}
+
+func (s *Source) watches(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Contains(s.watched, filepath.Clean(name))
+}
+
+// layers merges the config files into settings and records the files
+// setting every key.
+type layers struct {
+	merged  map[string]any
+	origins map[string][]string
+	files   []string
+	watched []string
+	stack   []string
 }
 
-func getConfig(path string) *viper.Viper {
-	conf := viper.New()
-	conf.SetConfigFile(path)
-	err := conf.ReadInConfig()
+// layer merges the file and its optional local override
+func (l *layers) layer(path string) error {
+	if err := l.load(path); err != nil {
+		return err
+	}
+	local := strings.TrimSuffix(path, filepath.Ext(path)) + localSuffix + filepath.Ext(path)
+	if _, err := os.Stat(local); errors.Is(err, fs.ErrNotExist) {
+		// * Watched anyway, creating the override triggers a reload.
+		l.watched = append(l.watched, filepath.Clean(local))
+		return nil
+	}
+	return l.load(local)
+}
+
+// load merges the includes of the file, then the file itself
+func (l *layers) load(path string) error {
+	path = filepath.Clean(path)
+	if slices.Contains(l.stack, path) {
+		return fmt.Errorf("include cycle: %s -> %s", strings.Join(l.stack, " -> "), path)
+	}
+	l.watched = append(l.watched, path)
+	data, err := os.ReadFile(path)
 	if err != nil {
-		slog.Error("loaded config faile", "path", path, "error", err)
-		os.Exit(1)
+		return err
+	}
+	settings, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	includes, err := includeList(settings[IncludeKey])
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	delete(settings, IncludeKey)
+	l.stack = append(l.stack, path)
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		if err = l.load(include); err != nil {
+			return err
+		}
+	}
+	l.stack = l.stack[:len(l.stack)-1]
+
+	l.files = append(l.files, path)
+	merge(l.merged, settings, "", path, l.origins)
+	return nil
+}
+
+func includeList(v any) ([]string, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{val}, nil
+	case []any:
+		list := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must list file paths", IncludeKey)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("%s must be a file path or a list of them", IncludeKey)
+}
+
+// merge merges the src settings of a file into dst
+func merge(dst, src map[string]any, prefix, file string, origins map[string][]string) {
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := joinKey(prefix, k)
+		switch val := src[k].(type) {
+		case replace:
+			clearOrigins(origins, key)
+			dst[k] = plain(val.value)
+			markOrigins(origins, key, dst[k], file)
+		case map[string]any:
+			if m, ok := dst[k].(map[string]any); ok {
+				merge(m, val, key, file, origins)
+				continue
+			}
+			clearOrigins(origins, key)
+			m := map[string]any{}
+			merge(m, val, key, file, origins)
+			dst[k] = m
+		case []any:
+			if list, ok := dst[k].([]any); ok {
+				dst[k] = appendUnique(list, plain(val).([]any))
+				if !slices.Contains(origins[key], file) {
+					origins[key] = append(origins[key], file)
+				}
+				continue
+			}
+			clearOrigins(origins, key)
+			dst[k] = plain(val)
+			origins[key] = []string{file}
+		default:
+			clearOrigins(origins, key)
+			dst[k] = val
+			if val != nil {
+				origins[key] = []string{file}
+			}
+		}
+	}
+}
+
+// appendUnique appends the items missing from the list
+func appendUnique(list, items []any) []any {
+	out := slices.Clone(list)
+	for _, item := range items {
+		if !slices.ContainsFunc(out, func(v any) bool { return reflect.DeepEqual(v, item) }) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// plain drops the replace markers nested in a value
+func plain(v any) any {
+	switch val := v.(type) {
+	case replace:
+		return plain(val.value)
+	case map[string]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			m[k] = plain(item)
+		}
+		return m
+	case []any:
+		list := make([]any, len(val))
+		for i, item := range val {
+			list[i] = plain(item)
+		}
+		return list
+	}
+	return v
+}
+
+func clearOrigins(origins map[string][]string, key string) {
+	for k := range origins {
+		if k == key || strings.HasPrefix(k, key+".") {
+			delete(origins, k)
+		}
+	}
+}
+
+func markOrigins(origins map[string][]string, key string, v any, file string) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		if v != nil {
+			origins[key] = []string{file}
+		}
+		return
+	}
+	for k := range Flatten(m) {
+		origins[key+"."+k] = []string{file}
+	}
+}
+
+// parse decodes a YAML file into settings with lower case keys,
+// the values tagged with ReplaceTag are wrapped.
+func parse(data []byte) (map[string]any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return map[string]any{}, nil
+	}
+	v, err := decodeNode(doc.Content[0])
+	if err != nil || v == nil {
+		return map[string]any{}, err
+	}
+	settings, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("top level must be a map")
+	}
+	return settings, nil
+}
+
+func decodeNode(n *yaml.Node) (any, error) {
+	var v any
+	switch n.Kind {
+	case yaml.AliasNode:
+		return decodeNode(n.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			item, err := decodeNode(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if n.Content[i].Tag == "!!merge" {
+				if anchor, ok := item.(map[string]any); ok {
+					for k, val := range anchor {
+						if _, exists := m[k]; !exists {
+							m[k] = val
+						}
+					}
+				}
+				continue
+			}
+			m[strings.ToLower(n.Content[i].Value)] = item
+		}
+		v = m
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			item, err := decodeNode(c)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		v = list
+	default:
+		scalar := *n
+		if scalar.Tag == ReplaceTag {
+			scalar.Tag = ""
+		}
+		if err := scalar.Decode(&v); err != nil {
+			return nil, err
+		}
+	}
+	if n.Tag == ReplaceTag {
+		return replace{value: v}, nil
 	}
-	return conf
+	return v, nil
 }
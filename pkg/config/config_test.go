@@ -0,0 +1,185 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s error = %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestNewSource(t *testing.T) {
+	t.Setenv(EnvProfile, "")
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": `
+env: dev
+include: [shared/slo.yaml]
+http:
+  port: 7080
+  expose: [health]
+  cors:
+    allow_origins: ["*"]
+log:
+  modules: {orm: warn, http: info}
+  sinks:
+    - {type: stdout}
+`,
+		"shared/slo.yaml": `
+slo:
+  groups: [{name: auth}]
+http:
+  port: 1
+`,
+		"base.local.yaml": "http: {host: 127.0.0.1}\n",
+		"dev.yaml": `
+http:
+  expose: [swagger, health]
+  cors:
+    allow_origins: !replace [https://trove.io]
+log:
+  modules: {orm: info}
+  sinks: !replace
+    - {type: file, path: trove.log}
+`,
+		"prod.yaml": "http: {port: 8000}\n",
+	})
+
+	s, err := NewSource(dir, "")
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	if s.Profile() != "dev" {
+		t.Errorf("Profile() = %q, want dev of the env key", s.Profile())
+	}
+	want := []string{"shared/slo.yaml", "base.yaml", "base.local.yaml", "dev.yaml"}
+	for i, name := range want {
+		want[i] = filepath.Join(dir, name)
+	}
+	if files := s.Files(); !reflect.DeepEqual(files, want) {
+		t.Errorf("Files() = %v, want %v", files, want)
+	}
+
+	tests := []struct {
+		key    string
+		want   any
+		origin string
+	}{
+		{"http.port", 7080, "base.yaml"},
+		{"http.host", "127.0.0.1", "base.local.yaml"},
+		{"http.expose", []any{"health", "swagger"}, "base.yaml, dev.yaml"},
+		{"http.cors.allow_origins", []any{"https://trove.io"}, "dev.yaml"},
+		{"log.modules.orm", "info", "dev.yaml"},
+		{"log.modules.http", "info", "base.yaml"},
+		{"log.sinks", []any{map[string]any{"type": "file", "path": "trove.log"}}, "dev.yaml"},
+		{"slo.groups", []any{map[string]any{"name": "auth"}}, "shared/slo.yaml"},
+		{"env", "dev", "base.yaml"},
+	}
+	for _, tt := range tests {
+		if got := s.Get(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%s) = %#v, want %#v", tt.key, got, tt.want)
+		}
+		origin := strings.ReplaceAll(s.Origin(tt.key), dir+string(filepath.Separator), "")
+		if origin != tt.origin {
+			t.Errorf("Origin(%s) = %q, want %q", tt.key, origin, tt.origin)
+		}
+	}
+	if s.IsSet(IncludeKey) {
+		t.Errorf("include key is set, want it dropped")
+	}
+
+	t.Setenv("APP_HTTP_PORT", "9000")
+	if origin := s.Origin("http.port"); origin != "env APP_HTTP_PORT" {
+		t.Errorf("Origin(http.port) = %q, want the env override", origin)
+	}
+}
+
+func TestNewSource_Profile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": "env: dev\nhttp: {port: 7080}\n",
+		"dev.yaml":  "",
+		"prod.yaml": "http: {port: 8000}\n",
+	})
+
+	t.Setenv(EnvProfile, "prod")
+	s, err := NewSource(filepath.Join(dir, "base.yaml"), "")
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	if s.GetInt("http.port") != 8000 || s.GetString("env") != "prod" {
+		t.Errorf("port %d of env %s, want 8000 of APP_ENV prod", s.GetInt("http.port"), s.GetString("env"))
+	}
+
+	t.Setenv(EnvProfile, "")
+	if s, err = NewSource(dir, "dev"); err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	if s.GetInt("http.port") != 7080 {
+		t.Errorf("port = %d, want 7080 of the dev profile flag", s.GetInt("http.port"))
+	}
+	if origin := s.Origin("env"); origin != "--profile" {
+		t.Errorf("Origin(env) = %q, want --profile", origin)
+	}
+
+	if _, err = NewSource(dir, "staging"); err == nil || !strings.Contains(err.Error(), "staging") {
+		t.Errorf("NewSource() of a missing profile error = %v, want it named", err)
+	}
+}
+
+func TestNewSource_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"cycle", map[string]string{"base.yaml": "include: a.yaml\n", "a.yaml": "include: base.yaml\n"}, "include cycle"},
+		{"missing include", map[string]string{"base.yaml": "include: [nope.yaml]\n"}, "nope.yaml"},
+		{"bad include", map[string]string{"base.yaml": "include: {a: 1}\n"}, "include must"},
+		{"not a map", map[string]string{"base.yaml": "- a\n"}, "top level"},
+		{"missing include of local", map[string]string{"base.yaml": "", "base.local.yaml": "include: nope.yaml\n"}, "nope.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSource(writeFiles(t, tt.files), "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewSource() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSource_Reload(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"base.yaml": "http: {port: 7080}\n"})
+	s, err := NewSource(dir, "")
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+
+	local := filepath.Join(dir, "base.local.yaml")
+	if !s.watches(local) {
+		t.Errorf("missing local override is not watched")
+	}
+	if err = os.WriteFile(local, []byte("http: {port: 7090}\n"), 0o600); err != nil {
+		t.Fatalf("write error = %v", err)
+	}
+	if err = s.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if port := s.GetInt("http.port"); port != 7090 {
+		t.Errorf("port = %d, want 7090 of the local override", port)
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redacted is the placeholder of secret values
+const Redacted = "******"
+
+// secretKey matches the config keys holding secrets
+var secretKey = regexp.MustCompile(`(?i)(password|secret|security|token|dsn|key)$`)
+
+// Redact returns the settings with the secret values masked, the values
+// of secret keys and any value containing one of the given secrets.
+func Redact(settings map[string]any, secrets ...string) map[string]any {
+	out := make(map[string]any, len(settings))
+	for k, v := range settings {
+		out[k] = redactValue(k, v, secrets)
+	}
+	return out
+}
+
+// redactValue masks the value of a key, dotted keys are matched
+// by their last segment.
+func redactValue(key string, v any, secrets []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return Redact(val, secrets...)
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = redactValue("", item, secrets)
+		}
+		return out
+	case string:
+		if val == "" {
+			return val
+		}
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+		if secretKey.MatchString(key) || containsSecret(val, secrets) {
+			return Redacted
+		}
+		return val
+	case nil:
+		return nil
+	}
+	if secretKey.MatchString(key[strings.LastIndex(key, ".")+1:]) {
+		return Redacted
+	}
+	return v
+}
+
+func containsSecret(s string, secrets []string) bool {
+	for _, secret := range secrets {
+		if secret != "" && strings.Contains(s, secret) {
+			return true
+		}
+	}
+	return false
+}
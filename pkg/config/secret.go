@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Secret Providers
+const (
+	ProviderFile  = "file"
+	ProviderEnv   = "env"
+	ProviderVault = "vault"
+)
+
+// placeholder matches the ${provider:ref} secret references
+var placeholder = regexp.MustCompile(`\$\{([a-z]+):([^}]+)\}`)
+
+// SecretProvider resolves the references of a secret placeholder
+type SecretProvider interface {
+	Secret(ref string) (string, error)
+}
+
+// ProviderFunc adapts a function to a SecretProvider
+type ProviderFunc func(ref string) (string, error)
+
+// Secret implements SecretProvider
+func (f ProviderFunc) Secret(ref string) (string, error) { return f(ref) }
+
+// FileProvider reads the secret from the file of the reference,
+// the trailing newline is dropped.
+func FileProvider() SecretProvider {
+	return ProviderFunc(func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	})
+}
+
+// EnvProvider reads the secret from the environment variable of the reference
+func EnvProvider() SecretProvider {
+	return ProviderFunc(func(name string) (string, error) {
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	})
+}
+
+// Resolver replaces the ${provider:ref} placeholders of config values
+// with the secrets and remembers them, so that they can be masked.
+type Resolver struct {
+	providers map[string]SecretProvider
+	secrets   []string
+}
+
+// NewResolver creates a new Resolver with the named providers
+func NewResolver(providers map[string]SecretProvider) *Resolver {
+	return &Resolver{providers: providers}
+}
+
+// Secrets returns all resolved secret values
+func (r *Resolver) Secrets() []string {
+	return r.secrets
+}
+
+// Resolve replaces all placeholders of s, the errors name the
+// references only and never the secrets.
+func (r *Resolver) Resolve(s string) (string, error) {
+	var errs []string
+	out := placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		sub := placeholder.FindStringSubmatch(m)
+		name, ref := sub[1], sub[2]
+		provider, ok := r.providers[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown secret provider %q", name))
+			return m
+		}
+		secret, err := provider.Secret(ref)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("resolve ${%s:%s}: %v", name, ref, err))
+			return m
+		}
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+		return secret
+	})
+	if len(errs) > 0 {
+		return s, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return out, nil
+}
+
+// ResolveStruct resolves the placeholders of all string fields of the
+// struct pointed to, nested in structs, slices and maps, the problems
+// are recorded per config key.
+func (r *Resolver) ResolveStruct(target any, p *Problems) {
+	r.resolveValue(reflect.ValueOf(target), "", p)
+}
+
+func (r *Resolver) resolveValue(rv reflect.Value, key string, p *Problems) {
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !rv.IsNil() {
+			r.resolveValue(rv.Elem(), key, p)
+		}
+	case reflect.String:
+		if !rv.CanSet() || !strings.Contains(rv.String(), "${") {
+			return
+		}
+		s, err := r.Resolve(rv.String())
+		if err != nil {
+			p.Addf(key, "%v", err)
+			return
+		}
+		rv.SetString(s)
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := range rt.NumField() {
+			field := rt.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get(tagName), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			fieldKey := joinKey(key, name)
+			if opts == "squash" {
+				fieldKey = key
+			}
+			r.resolveValue(rv.Field(i), fieldKey, p)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range rv.Len() {
+			r.resolveValue(rv.Index(i), fmt.Sprintf("%s.%d", key, i), p)
+		}
+	case reflect.Map:
+		// * Map values are not addressable, they are resolved on a copy.
+		iter := rv.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			r.resolveValue(value, joinKey(key, fmt.Sprint(iter.Key())), p)
+			rv.SetMapIndex(iter.Key(), value)
+		}
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secretConf struct {
+	DSN   string            `mapstructure:"dsn"`
+	Hosts []string          `mapstructure:"hosts"`
+	DB    map[string]secret `mapstructure:"db"`
+}
+
+type secret struct {
+	Password string `mapstructure:"password"`
+}
+
+func TestResolver(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jwt")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret error = %v", err)
+	}
+	t.Setenv("TROVE_TEST_PASS", "from-env")
+	r := NewResolver(map[string]SecretProvider{
+		ProviderFile: FileProvider(),
+		ProviderEnv:  EnvProvider(),
+	})
+
+	c := &secretConf{
+		DSN:   "user:${env:TROVE_TEST_PASS}@tcp(db)/app",
+		Hosts: []string{"${file:" + file + "}", "plain"},
+		DB:    map[string]secret{"user": {Password: "${env:TROVE_TEST_PASS}"}},
+	}
+	var p Problems
+	r.ResolveStruct(c, &p)
+	if err := p.Err(); err != nil {
+		t.Fatalf("ResolveStruct() error = %v", err)
+	}
+	if c.DSN != "user:from-env@tcp(db)/app" || c.Hosts[0] != "from-file" || c.DB["user"].Password != "from-env" {
+		t.Errorf("resolved = %+v", c)
+	}
+
+	redacted := Redact(ToMap(c), r.Secrets()...)
+	if hosts := redacted["hosts"].([]any); hosts[0] != Redacted || hosts[1] != "plain" {
+		t.Errorf("redacted hosts = %v, want the secret masked", hosts)
+	}
+}
+
+func TestResolver_Problems(t *testing.T) {
+	r := NewResolver(map[string]SecretProvider{ProviderEnv: EnvProvider()})
+	c := &secretConf{DSN: "${env:TROVE_TEST_MISSING}", Hosts: []string{"${nope:x}"}}
+	var p Problems
+	r.ResolveStruct(c, &p)
+
+	err := p.Err()
+	if err == nil {
+		t.Fatal("ResolveStruct() error = nil, want problems")
+	}
+	for _, want := range []string{"dsn: resolve ${env:TROVE_TEST_MISSING}", `hosts.0: unknown secret provider "nope"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q misses %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding config,
+// APP_HTTP_PORT overrides http.port for instance.
+const EnvPrefix = "APP"
+
+// tagName is the struct tag naming the config keys
+const tagName = "mapstructure"
+
+// ToMap converts a config struct into nested settings keyed by the
+// mapstructure tags, durations turn into their string form.
+func ToMap(v any) map[string]any {
+	m, _ := toValue(reflect.ValueOf(v)).(map[string]any)
+	return m
+}
+
+func toValue(rv reflect.Value) any {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if d, ok := rv.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		m := make(map[string]any, rv.NumField())
+		structFields(rv, m)
+		return m
+	case reflect.Map:
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[strings.ToLower(iter.Key().String())] = toValue(iter.Value())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return []any{}
+		}
+		list := make([]any, rv.Len())
+		for i := range rv.Len() {
+			list[i] = toValue(rv.Index(i))
+		}
+		return list
+	}
+	return rv.Interface()
+}
+
+func structFields(rv reflect.Value, m map[string]any) {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get(tagName), ",")
+		if name == "-" {
+			continue
+		}
+		if opts == "squash" {
+			structFields(reflect.Indirect(rv.Field(i)), m)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		m[name] = toValue(rv.Field(i))
+	}
+}
+
+// SetDefaults registers every leaf of the defaults struct under the key
+// on viper, an empty key stands for the root. All keys are then known to
+// viper and the environment overrides apply to them.
+func SetDefaults(conf *viper.Viper, key string, defaults any) {
+	for k, value := range Flatten(ToMap(defaults)) {
+		if key != "" {
+			k = key + "." + k
+		}
+		conf.SetDefault(k, value)
+	}
+}
+
+// Flatten flattens nested settings into dotted keys,
+// empty maps and nil values have no key.
+func Flatten(settings map[string]any) map[string]any {
+	flat := make(map[string]any)
+	flatten("", settings, flat)
+	return flat
+}
+
+func flatten(prefix string, settings, flat map[string]any) {
+	for k, v := range settings {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if m, ok := v.(map[string]any); ok {
+			flatten(key, m, flat)
+			continue
+		}
+		if v != nil {
+			flat[key] = v
+		}
+	}
+}
+
+// BindEnv enables the environment overrides of the given prefix,
+// nested keys are joined by underscores.
+func BindEnv(conf *viper.Viper, prefix string) {
+	conf.SetEnvPrefix(prefix)
+	conf.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	conf.AutomaticEnv()
+}
+
+// Change is a changed config key, Old or New is nil for an added or
+// removed key.
+type Change struct {
+	Key string
+	Old any
+	New any
+}
+
+// String formats the change with the secret values masked
+func (c Change) String() string {
+	return c.Format()
+}
+
+// Format formats the change with the values of secret keys and the
+// values containing any of the given secrets masked.
+func (c Change) Format(secrets ...string) string {
+	return fmt.Sprintf("%s: %v -> %v", c.Key,
+		redactValue(c.Key, c.Old, secrets), redactValue(c.Key, c.New, secrets))
+}
+
+// Diff returns the changed keys between two settings sorted by key
+func Diff(old, new map[string]any) []Change {
+	before, after := Flatten(old), Flatten(new)
+	var changes []Change
+	for key, v := range before {
+		if nv, ok := after[key]; !ok || !reflect.DeepEqual(v, nv) {
+			changes = append(changes, Change{Key: key, Old: v, New: after[key]})
+		}
+	}
+	for key, nv := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, Change{Key: key, New: nv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Problems collects the validation problems of a config,
+// so that all of them are reported at once.
+type Problems []string
+
+// Addf records a problem of the given key
+func (p *Problems) Addf(key, format string, args ...any) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+// Required records a problem when the value of the key is empty
+func (p *Problems) Required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		p.Addf(key, "is required")
+	}
+}
+
+// Port records a problem when the port of the key is out of range
+func (p *Problems) Port(key string, port int) {
+	if port < 1 || port > 65535 {
+		p.Addf(key, "port %d is out of range 1-65535", port)
+	}
+}
+
+// MinLength records a problem when the value of the key is too short
+func (p *Problems) MinLength(key, value string, n int) {
+	if len(value) < n {
+		p.Addf(key, "must be at least %d bytes, got %d", n, len(value))
+	}
+}
+
+// OneOf records a problem when the value of the key is not allowed
+func (p *Problems) OneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	p.Addf(key, "%q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+// Err returns the problems sorted by key as an error, nil without problems
+func (p Problems) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: slices.Sorted(slices.Values(p))}
+}
+
+// ValidationError lists all problems of an invalid config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid config, %d problem(s):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - " + p)
+	}
+	return b.String()
+}
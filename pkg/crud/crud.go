@@ -0,0 +1,314 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultKey is the column a record is found by
+const DefaultKey = "id"
+
+// CRUD Errors
+var (
+	ErrNotFound       = errors.New("record not found")
+	ErrUnknownField   = errors.New("unknown field")
+	ErrNotSoftDeleted = errors.New("model has no soft delete field")
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrCursorOrder    = errors.New("sort not usable with a cursor, order by a column")
+	ErrConflict       = errors.New("record was changed concurrently")
+)
+
+// ConflictError is returned when a versioned record changed since it was
+// read, it is ErrConflict.
+type ConflictError struct {
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("record was changed since version %d", e.Version)
+}
+
+// Is reports the error as ErrConflict
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// DB returns the gorm.DB of a context, the transaction of the context or
+// else the connection. repository.Repository is one.
+type DB interface {
+	DB(ctx context.Context) *gorm.DB
+}
+
+// Option configures a Repository
+type Option func(*options)
+
+type options struct {
+	key      string
+	version  string
+	notFound error
+}
+
+// WithKey sets the column the ID of a record is matched against,
+// the primary key id by default.
+func WithKey(column string) Option {
+	return func(o *options) { o.key = column }
+}
+
+// WithVersion sets the integer version column of the model, an update
+// checks the version it read and bumps it.
+func WithVersion(column string) Option {
+	return func(o *options) { o.version = column }
+}
+
+// WithNotFound sets the error returned when no record matches,
+// ErrNotFound by default.
+func WithNotFound(err error) Option {
+	return func(o *options) { o.notFound = err }
+}
+
+// Repository is the type-safe CRUD of the model T found by an ID of type ID
+type Repository[T any, ID comparable] struct {
+	db       DB
+	key      string
+	version  string
+	notFound error
+}
+
+// New creates the Repository of the model T on the DB
+func New[T any, ID comparable](db DB, opts ...Option) *Repository[T, ID] {
+	o := options{key: DefaultKey, notFound: ErrNotFound}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Repository[T, ID]{db: db, key: o.key, version: o.version, notFound: o.notFound}
+}
+
+// DB returns the gorm.DB of the context on the model T
+func (r *Repository[T, ID]) DB(ctx context.Context) *gorm.DB {
+	return r.db.DB(ctx).Model(new(T))
+}
+
+// Create inserts the record
+func (r *Repository[T, ID]) Create(ctx context.Context, v *T) error {
+	return r.db.DB(ctx).Create(v).Error
+}
+
+// Update saves every field of the record. A versioned record saves only
+// when it is still at the version read, a ConflictError otherwise.
+func (r *Repository[T, ID]) Update(ctx context.Context, v *T) error {
+	if r.version == "" {
+		return r.db.DB(ctx).Save(v).Error
+	}
+	tx := r.db.DB(ctx)
+	version, err := r.versionOf(tx, v)
+	if err != nil {
+		return err
+	}
+	read := version.Int()
+	version.SetInt(read + 1)
+	res := tx.Model(v).Where(clause.Eq{Column: clause.Column{Name: r.version}, Value: read}).Select("*").Updates(v)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = &ConflictError{Version: read}
+	}
+	if res.Error != nil {
+		version.SetInt(read)
+		return res.Error
+	}
+	return nil
+}
+
+// Patch updates the fields of the mask to their values in v, the mask
+// takes field or column names. Without a mask it updates the non-zero
+// fields of v. A versioned record gets its version bumped, a v carrying
+// a version patches only when the record is still at it, a ConflictError
+// otherwise. Without a version the last patch wins.
+func (r *Repository[T, ID]) Patch(ctx context.Context, id ID, v *T, mask ...string) error {
+	tx := r.DB(ctx).Where(r.eq(id))
+	s, err := r.schema(tx)
+	if err != nil {
+		return err
+	}
+	values := map[string]any{}
+	rv := reflect.ValueOf(v).Elem()
+	if len(mask) > 0 {
+		for _, name := range mask {
+			field := s.LookUpField(name)
+			if field == nil || field.DBName == "" {
+				return fmt.Errorf("%w %s", ErrUnknownField, name)
+			}
+			values[field.DBName], _ = field.ValueOf(ctx, rv)
+		}
+	} else {
+		for _, field := range s.Fields {
+			if value, zero := field.ValueOf(ctx, rv); field.DBName != "" && !field.PrimaryKey && !zero {
+				values[field.DBName] = value
+			}
+		}
+	}
+	var version reflect.Value
+	var read int64
+	if r.version != "" {
+		if version, err = r.versionOf(tx, v); err != nil {
+			return err
+		}
+		if read = version.Int(); read != 0 {
+			tx = tx.Where(clause.Eq{Column: clause.Column{Name: r.version}, Value: read})
+		}
+		values[r.version] = gorm.Expr("? + 1", clause.Column{Name: r.version})
+	}
+
+	res := tx.Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	// * Some drivers count the changed rows only, an unchanged record
+	// * still exists. A bumped version always changes the record.
+	if res.RowsAffected == 0 {
+		if err = r.exists(ctx, id); err != nil || read == 0 {
+			return err
+		}
+		return &ConflictError{Version: read}
+	}
+	if read != 0 {
+		version.SetInt(read + 1)
+	}
+	return nil
+}
+
+// Delete deletes the record, softly when the model has a soft delete field
+func (r *Repository[T, ID]) Delete(ctx context.Context, id ID) error {
+	res := r.db.DB(ctx).Where(r.eq(id)).Delete(new(T))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return r.notFound
+	}
+	return nil
+}
+
+// Restore undoes the soft delete of the record
+func (r *Repository[T, ID]) Restore(ctx context.Context, id ID) error {
+	tx := r.DB(ctx).Unscoped()
+	deletedAt, err := r.deletedAt(tx)
+	if err != nil {
+		return err
+	}
+
+	deleted := clause.Expr{SQL: "? IS NOT NULL", Vars: []any{clause.Column{Name: deletedAt}}}
+	res := tx.Where(r.eq(id)).Where(deleted).Update(deletedAt, nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return r.notFound
+	}
+	return nil
+}
+
+// Purge hard deletes the records soft deleted before the time, it returns
+// how many were deleted.
+func (r *Repository[T, ID]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	tx := r.DB(ctx).Unscoped()
+	deletedAt, err := r.deletedAt(tx)
+	if err != nil {
+		return 0, err
+	}
+	res := tx.Where(clause.Lt{Column: clause.Column{Name: deletedAt}, Value: before}).Delete(new(T))
+	return res.RowsAffected, res.Error
+}
+
+// deletedAt returns the column of the soft delete field of the model
+func (r *Repository[T, ID]) deletedAt(tx *gorm.DB) (string, error) {
+	s, err := r.schema(tx)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range s.Fields {
+		if field.FieldType == reflect.TypeFor[gorm.DeletedAt]() {
+			return field.DBName, nil
+		}
+	}
+	return "", ErrNotSoftDeleted
+}
+
+// FindByID returns the record of the ID matching the specs
+func (r *Repository[T, ID]) FindByID(ctx context.Context, id ID, specs ...Spec) (*T, error) {
+	return r.FindOne(ctx, append(slices.Clip(specs), Eq(r.key, id))...)
+}
+
+// FindOne returns the first record matching the specs
+func (r *Repository[T, ID]) FindOne(ctx context.Context, specs ...Spec) (*T, error) {
+	var v T
+	if err := apply(r.db.DB(ctx), specs).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, r.notFound
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List returns the records matching the specs
+func (r *Repository[T, ID]) List(ctx context.Context, specs ...Spec) ([]*T, error) {
+	var list []*T
+	if err := apply(r.db.DB(ctx), specs).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Count counts the records matching the specs
+func (r *Repository[T, ID]) Count(ctx context.Context, specs ...Spec) (int64, error) {
+	var total int64
+	if err := apply(r.DB(ctx), specs).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *Repository[T, ID]) eq(id ID) clause.Expression {
+	return clause.Eq{Column: clause.Column{Name: r.key}, Value: id}
+}
+
+func (r *Repository[T, ID]) exists(ctx context.Context, id ID) error {
+	var n int64
+	if err := r.DB(ctx).Where(r.eq(id)).Limit(1).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.notFound
+	}
+	return nil
+}
+
+// versionOf returns the settable version field of the record
+func (r *Repository[T, ID]) versionOf(tx *gorm.DB, v *T) (reflect.Value, error) {
+	s, err := r.schema(tx)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	field := s.LookUpField(r.version)
+	if field == nil {
+		return reflect.Value{}, fmt.Errorf("%w %s", ErrUnknownField, r.version)
+	}
+	value := reflect.Indirect(field.ReflectValueOf(tx.Statement.Context, reflect.ValueOf(v).Elem()))
+	if !value.CanInt() {
+		return reflect.Value{}, fmt.Errorf("version field %s is not an integer", field.Name)
+	}
+	return value, nil
+}
+
+func (r *Repository[T, ID]) schema(tx *gorm.DB) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
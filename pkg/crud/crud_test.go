@@ -0,0 +1,282 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type note struct {
+	ID        int64
+	Slug      string `gorm:"unique"`
+	Title     string
+	Rank      int
+	Tags      []tag
+	Version   int64
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+type tag struct {
+	ID     int64
+	NoteID int64
+	Name   string
+}
+
+// conn is a DB without transactions
+type conn struct{ db *gorm.DB }
+
+func (c conn) DB(ctx context.Context) *gorm.DB { return c.db.WithContext(ctx) }
+
+func newNotes(t *testing.T, opts ...Option) *Repository[note, int64] {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite error = %v", err)
+	}
+	if err = db.AutoMigrate(&note{}, &tag{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	return New[note, int64](conn{db}, opts...)
+}
+
+func seed(t *testing.T, r *Repository[note, int64], ranks ...int) {
+	t.Helper()
+	for i, rank := range ranks {
+		n := &note{Slug: string(rune('a' + i)), Title: "note", Rank: rank, Tags: []tag{{Name: "go"}}}
+		if err := r.Create(context.Background(), n); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+}
+
+func slugs(list []*note) string {
+	var s string
+	for _, n := range list {
+		s += n.Slug
+	}
+	return s
+}
+
+func TestRepository(t *testing.T) {
+	r := newNotes(t)
+	ctx := context.Background()
+	seed(t, r, 1, 2)
+
+	n, err := r.FindByID(ctx, 1, Preload("Tags"))
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if n.Slug != "a" || len(n.Tags) != 1 {
+		t.Errorf("FindByID() = %+v, want note a and its tag", n)
+	}
+	if _, err = r.FindByID(ctx, 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindByID() of a missing note error = %v, want ErrNotFound", err)
+	}
+
+	n.Title = "saved"
+	if err = r.Update(ctx, n); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// * The mask updates the zero rank, the title is not in it.
+	if err = r.Patch(ctx, 1, &note{Title: "patched", Rank: 0}, "rank"); err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	if n, _ = r.FindOne(ctx, Eq("slug", "a")); n.Title != "saved" || n.Rank != 0 {
+		t.Errorf("after Patch() = %+v, want title saved and rank 0", n)
+	}
+	if err = r.Patch(ctx, 1, &note{}, "secret"); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("Patch() of an unknown field error = %v, want ErrUnknownField", err)
+	}
+	if err = r.Patch(ctx, 1, &note{Rank: 0}, "Rank"); err != nil {
+		t.Errorf("Patch() of an unchanged note error = %v", err)
+	}
+	if err = r.Patch(ctx, 9, &note{Rank: 1}, "rank"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Patch() of a missing note error = %v, want ErrNotFound", err)
+	}
+
+	if err = r.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err = r.Delete(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+	}
+	if total, _ := r.Count(ctx); total != 1 {
+		t.Errorf("Count() after Delete() = %d, want 1", total)
+	}
+	if list, _ := r.List(ctx, Unscoped(), OrderBy("rank", true)); slugs(list) != "ba" {
+		t.Errorf("List(Unscoped) = %s, want ba", slugs(list))
+	}
+	if err = r.Restore(ctx, 1); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if err = r.Restore(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore() of a live note error = %v, want ErrNotFound", err)
+	}
+	if list, _ := r.List(ctx, In("slug", "a", "b")); len(list) != 2 {
+		t.Errorf("List() after Restore() = %s, want ab", slugs(list))
+	}
+
+	_ = r.Delete(ctx, 2)
+	if n, err := r.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("Purge() of the recent deletes = %d, %v, want none", n, err)
+	}
+	if n, err := r.Purge(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("Purge() = %d, %v, want the deleted note", n, err)
+	}
+	if list, _ := r.List(ctx, Unscoped()); slugs(list) != "a" {
+		t.Errorf("List(Unscoped) after Purge() = %s, want a", slugs(list))
+	}
+
+	tags := New[tag, int64](r.db)
+	if err = tags.Restore(ctx, 1); !errors.Is(err, ErrNotSoftDeleted) {
+		t.Errorf("Restore() of a hard deleted model error = %v, want ErrNotSoftDeleted", err)
+	}
+	if _, err = tags.Purge(ctx, time.Now()); !errors.Is(err, ErrNotSoftDeleted) {
+		t.Errorf("Purge() of a hard deleted model error = %v, want ErrNotSoftDeleted", err)
+	}
+}
+
+func TestRepository_Key(t *testing.T) {
+	notFound := errors.New("no such note")
+	r := newNotes(t)
+	seed(t, r, 1)
+	bySlug := New[note, string](r.db, WithKey("slug"), WithNotFound(notFound))
+
+	n, err := bySlug.FindByID(context.Background(), "a")
+	if err != nil || n.ID != 1 {
+		t.Errorf("FindByID(a) = %+v, %v, want note 1", n, err)
+	}
+	if _, err = bySlug.FindByID(context.Background(), "z"); !errors.Is(err, notFound) {
+		t.Errorf("FindByID(z) error = %v, want the configured error", err)
+	}
+}
+
+func TestRepository_Version(t *testing.T) {
+	r := newNotes(t)
+	seed(t, r, 1)
+	versioned := New[note, int64](r.db, WithVersion("version"))
+	ctx := context.Background()
+
+	first, _ := versioned.FindByID(ctx, 1)
+	second, _ := versioned.FindByID(ctx, 1)
+	first.Title = "first"
+	if err := versioned.Update(ctx, first); err != nil || first.Version != 1 {
+		t.Fatalf("Update() = version %d, %v, want version 1", first.Version, err)
+	}
+	second.Title = "second"
+	err := versioned.Update(ctx, second)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) || conflict.Version != 0 || second.Version != 0 {
+		t.Errorf("Update() of a stale read error = %v, version %d, want a conflict at version 0", err, second.Version)
+	}
+
+	if err = versioned.Patch(ctx, 1, &note{Rank: 7}, "rank"); err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	n, _ := versioned.FindByID(ctx, 1)
+	if n.Title != "first" || n.Rank != 7 || n.Version != 2 {
+		t.Errorf("after Patch() = %+v, want title first, rank 7 and version 2", n)
+	}
+	if err = versioned.Update(ctx, first); !errors.Is(err, ErrConflict) {
+		t.Errorf("Update() after Patch() error = %v, want ErrConflict", err)
+	}
+
+	// * A patch carrying a version checks it like Update.
+	stale := &note{Rank: 8, Version: 1}
+	if err = versioned.Patch(ctx, 1, stale, "rank"); !errors.As(err, &conflict) || conflict.Version != 1 {
+		t.Errorf("Patch() of a stale version error = %v, want a conflict at version 1", err)
+	}
+	current := &note{Rank: 9, Version: 2}
+	if err = versioned.Patch(ctx, 1, current, "rank"); err != nil || current.Version != 3 {
+		t.Errorf("Patch() of the current version = version %d, %v, want version 3", current.Version, err)
+	}
+	if err = versioned.Patch(ctx, 9, current, "rank"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Patch() of a missing note error = %v, want ErrNotFound", err)
+	}
+	if n, _ = versioned.FindByID(ctx, 1); n.Rank != 9 || n.Version != 3 {
+		t.Errorf("after versioned Patch() = %+v, want rank 9 and version 3", n)
+	}
+}
+
+func TestRepository_ListOffset(t *testing.T) {
+	r := newNotes(t)
+	seed(t, r, 3, 1, 2, 5, 4)
+
+	list, total, err := r.ListOffset(context.Background(), 1, 2, Where("rank > ?", 1), OrderBy("rank", false))
+	if err != nil {
+		t.Fatalf("ListOffset() error = %v", err)
+	}
+	if total != 4 || slugs(list) != "ae" {
+		t.Errorf("ListOffset() = %s of %d, want ae of 4", slugs(list), total)
+	}
+}
+
+func TestRepository_ListCursor(t *testing.T) {
+	r := newNotes(t)
+	// * Ranks repeat, the key breaks the ties.
+	seed(t, r, 2, 1, 2, 3, 1, 2)
+
+	tests := []struct {
+		name  string
+		order []Order
+		specs []Spec
+		want  []string
+	}{
+		{"key", nil, nil, []string{"ab", "cd", "ef"}},
+		{"rank", []Order{{Column: "rank"}}, nil, []string{"be", "ac", "fd"}},
+		{"rank desc", []Order{{Column: "Rank", Desc: true}}, nil, []string{"da", "cf", "be"}},
+		{"filtered", []Order{{Column: "rank"}, {Column: "id", Desc: true}}, []Spec{Where("rank < ?", 3)}, []string{"eb", "fc", "a"}},
+		{"order spec", nil, []Spec{OrderBy("rank", true)}, []string{"da", "cf", "be"}},
+		{"order spec first", []Order{{Column: "id", Desc: true}}, []Spec{OrderBy("rank", false)}, []string{"eb", "fc", "ad"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages []string
+			cursor := ""
+			for {
+				list, next, err := r.ListCursor(context.Background(), Keyset{Cursor: cursor, Limit: 2, Order: tt.order}, tt.specs...)
+				if err != nil {
+					t.Fatalf("ListCursor() error = %v", err)
+				}
+				pages = append(pages, slugs(list))
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Errorf("ListCursor() pages = %v, want %v", pages, tt.want)
+			}
+		})
+	}
+
+	// * Time columns round trip the cursor.
+	list, next, err := r.ListCursor(context.Background(), Keyset{Limit: 4, Order: []Order{{Column: "created_at"}}})
+	if err != nil || slugs(list) != "abcd" {
+		t.Fatalf("ListCursor(created_at) = %s, %v", slugs(list), err)
+	}
+	if list, _, _ = r.ListCursor(context.Background(), Keyset{Cursor: next, Limit: 4, Order: []Order{{Column: "created_at"}}}); slugs(list) != "ef" {
+		t.Errorf("ListCursor(created_at) next page = %s, want ef", slugs(list))
+	}
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "WzFd"} {
+		if _, _, err = r.ListCursor(context.Background(), Keyset{Cursor: cursor, Limit: 2, Order: []Order{{Column: "rank"}}}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ListCursor(%q) error = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+	raw := func(tx *gorm.DB) *gorm.DB { return tx.Order("rank desc") }
+	if _, _, err = r.ListCursor(context.Background(), Keyset{Limit: 2}, raw); !errors.Is(err, ErrCursorOrder) {
+		t.Errorf("ListCursor() of a raw sort error = %v, want ErrCursorOrder", err)
+	}
+	if _, _, err = r.ListCursor(context.Background(), Keyset{Limit: 2, Order: []Order{{Column: "secret"}}}); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("ListCursor() of an unknown column error = %v, want ErrUnknownField", err)
+	}
+}
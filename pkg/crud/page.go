@@ -0,0 +1,170 @@
+package crud
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Order is a sort column of a keyset page
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// Keyset is a page after a cursor, the records sort by the order and then
+// by the key. An empty cursor starts at the first record.
+type Keyset struct {
+	Cursor string
+	Limit  int
+	Order  []Order
+}
+
+// ListOffset returns a page of the records matching the specs and the
+// total of the records matching them.
+func (r *Repository[T, ID]) ListOffset(ctx context.Context, offset, limit int, specs ...Spec) ([]*T, int64, error) {
+	total, err := r.Count(ctx, specs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := r.List(ctx, append(slices.Clip(specs), func(tx *gorm.DB) *gorm.DB {
+		return tx.Offset(offset).Limit(limit)
+	})...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// ListCursor returns the page of the keyset and the opaque cursor of the
+// next page, an empty one after the last page. The OrderBy sorts of the
+// specs come first in the keyset order.
+func (r *Repository[T, ID]) ListCursor(ctx context.Context, page Keyset, specs ...Spec) ([]*T, string, error) {
+	tx := apply(r.db.DB(ctx), specs)
+	s, err := r.schema(tx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	order, err := specOrder(tx)
+	if err != nil {
+		return nil, "", err
+	}
+	order = append(order, page.Order...)
+	if !slices.ContainsFunc(order, func(o Order) bool { return o.Column == r.key }) {
+		order = append(order, Order{Column: r.key})
+	}
+	fields := make([]*schema.Field, len(order))
+	for i, o := range order {
+		if fields[i] = s.LookUpField(o.Column); fields[i] == nil || fields[i].DBName == "" {
+			return nil, "", fmt.Errorf("%w %s", ErrUnknownField, o.Column)
+		}
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: fields[i].DBName}, Desc: o.Desc})
+	}
+
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, fields)
+		if err != nil {
+			return nil, "", err
+		}
+		tx = tx.Where(after(fields, order, values))
+	}
+
+	limit := max(page.Limit, 1)
+	var list []*T
+	if err = tx.Limit(limit + 1).Find(&list).Error; err != nil {
+		return nil, "", err
+	}
+	if len(list) <= limit {
+		return list, "", nil
+	}
+	list = list[:limit]
+	next, err := encodeCursor(ctx, fields, list[limit-1])
+	if err != nil {
+		return nil, "", err
+	}
+	return list, next, nil
+}
+
+// specOrder takes the sorts of the specs out of the query, the cursor
+// pages right only when every sort is part of the keyset order.
+func specOrder(tx *gorm.DB) ([]Order, error) {
+	c, ok := tx.Statement.Clauses["ORDER BY"]
+	if !ok {
+		return nil, nil
+	}
+	delete(tx.Statement.Clauses, "ORDER BY")
+	by, ok := c.Expression.(clause.OrderBy)
+	if !ok || by.Expression != nil {
+		return nil, ErrCursorOrder
+	}
+	order := make([]Order, 0, len(by.Columns))
+	for _, column := range by.Columns {
+		if column.Column.Raw || column.Reorder {
+			return nil, ErrCursorOrder
+		}
+		order = append(order, Order{Column: column.Column.Name, Desc: column.Desc})
+	}
+	return order, nil
+}
+
+// after matches the records sorting after the values of the columns,
+// (a > x) OR (a = x AND b > y) ...
+func after(fields []*schema.Field, order []Order, values []any) clause.Expression {
+	ors := make([]clause.Expression, 0, len(fields))
+	for i, field := range fields {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := range i {
+			ands = append(ands, clause.Eq{Column: clause.Column{Name: fields[j].DBName}, Value: values[j]})
+		}
+		column := clause.Column{Name: field.DBName}
+		if order[i].Desc {
+			ands = append(ands, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	return clause.Or(ors...)
+}
+
+// encodeCursor encodes the sort values of the record
+func encodeCursor[T any](ctx context.Context, fields []*schema.Field, v *T) (string, error) {
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		values[i], _ = field.ValueOf(ctx, reflect.ValueOf(v).Elem())
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes the sort values of a cursor into the types of the fields
+func decodeCursor(cursor string, fields []*schema.Field) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raw []json.RawMessage
+	if err = json.Unmarshal(data, &raw); err != nil || len(raw) != len(fields) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		v := reflect.New(field.FieldType)
+		if err = json.Unmarshal(raw[i], v.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
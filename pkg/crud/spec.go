@@ -0,0 +1,55 @@
+package crud
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Spec narrows a query, the specs of a call apply in order
+type Spec func(tx *gorm.DB) *gorm.DB
+
+// Where filters by a condition, as gorm.DB.Where
+func Where(query any, args ...any) Spec {
+	return func(tx *gorm.DB) *gorm.DB { return tx.Where(query, args...) }
+}
+
+// Eq filters the records whose column equals the value
+func Eq(column string, value any) Spec {
+	return Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+}
+
+// In filters the records whose column is one of the values
+func In(column string, values ...any) Spec {
+	return Where(clause.IN{Column: clause.Column{Name: column}, Values: values})
+}
+
+// OrderBy sorts by the column, after the sorts of the previous specs
+func OrderBy(column string, desc bool) Spec {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
+	}
+}
+
+// Limit returns at most n records
+func Limit(n int) Spec {
+	return func(tx *gorm.DB) *gorm.DB { return tx.Limit(n) }
+}
+
+// Preload loads the association with the records
+func Preload(association string, args ...any) Spec {
+	return func(tx *gorm.DB) *gorm.DB { return tx.Preload(association, args...) }
+}
+
+// Unscoped includes the soft deleted records
+func Unscoped() Spec {
+	return func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }
+}
+
+// apply applies the specs now, not when the query runs as gorm scopes,
+// so that Count still drops their sorts.
+func apply(tx *gorm.DB, specs []Spec) *gorm.DB {
+	for _, spec := range specs {
+		tx = spec(tx)
+	}
+	return tx
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event is a domain event, the ID orders the events of an outbox and keys
+// the idempotent consumers.
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	Key        string          `json:"key"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// Decode decodes the payload of the event into v
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Handler consumes an event, an error has the event delivered again
+type Handler func(ctx context.Context, e Event) error
+
+// Publisher delivers the events to their subscribers, the in-process Bus
+// or a client of an external broker.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// PublisherFunc adapts a function to a Publisher
+type PublisherFunc func(ctx context.Context, e Event) error
+
+// Publish calls f
+func (f PublisherFunc) Publish(ctx context.Context, e Event) error { return f(ctx, e) }
+
+// subscriber is a named handler of an event type
+type subscriber struct {
+	name   string
+	handle Handler
+}
+
+// Bus is an in-process Publisher, it hands an event to every handler
+// subscribed to its type in turn.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+}
+
+// NewBus creates an empty Bus
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]subscriber)}
+}
+
+// Subscribe adds the named handler of the event type
+func (b *Bus) Subscribe(eventType, name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[eventType] = append(b.subscribers[eventType], subscriber{name: name, handle: h})
+}
+
+// Publish hands the event to the handlers of its type. Every handler runs,
+// the failed ones are joined in the error and the event delivered again
+// goes to all of them, which is why the handlers should be idempotent.
+func (b *Bus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	subscribers := b.subscribers[e.Type]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subscribers {
+		if err := sub.run(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run calls the handler, a panic fails the delivery
+func (s subscriber) run(ctx context.Context, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("event handler panicked", "handler", s.name, "event", e.ID, "panic", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.handle(ctx, e)
+}
@@ -0,0 +1,193 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var errHandler = errors.New("handler failed")
+
+// memoryStore is a Store of the events of a test
+type memoryStore struct {
+	pending []Delivery
+	dead    []int64
+}
+
+func (s *memoryStore) add(ids ...int64) {
+	for _, id := range ids {
+		s.pending = append(s.pending, Delivery{Event: Event{ID: id, Type: "note.added"}})
+	}
+}
+
+func (s *memoryStore) Pending(_ context.Context, limit int) ([]Delivery, error) {
+	return slices.Clone(s.pending[:min(limit, len(s.pending))]), nil
+}
+
+func (s *memoryStore) Delivered(_ context.Context, id int64) error {
+	s.pending = slices.DeleteFunc(s.pending, func(d Delivery) bool { return d.ID == id })
+	return nil
+}
+
+func (s *memoryStore) Failed(_ context.Context, id int64, _ error, retryAt time.Time, dead bool) error {
+	if dead {
+		s.dead = append(s.dead, id)
+		return s.Delivered(context.Background(), id)
+	}
+	for i := range s.pending {
+		if s.pending[i].ID == id {
+			s.pending[i].Attempts++
+			s.pending[i].RetryAt = retryAt
+		}
+	}
+	return nil
+}
+
+func TestBus_Publish(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	bus.Subscribe("note.added", "audit", func(_ context.Context, e Event) error {
+		got = append(got, "audit")
+		return nil
+	})
+	bus.Subscribe("note.added", "broken", func(context.Context, Event) error { panic("boom") })
+	bus.Subscribe("note.added", "index", func(context.Context, Event) error {
+		got = append(got, "index")
+		return errHandler
+	})
+
+	err := bus.Publish(context.Background(), Event{ID: 1, Type: "note.added"})
+	if !errors.Is(err, errHandler) {
+		t.Errorf("Publish() error = %v, want errHandler joined", err)
+	}
+	if !reflect.DeepEqual(got, []string{"audit", "index"}) {
+		t.Errorf("handlers run = %v, want every handler past the panic", got)
+	}
+	if err = bus.Publish(context.Background(), Event{ID: 2, Type: "note.removed"}); err != nil {
+		t.Errorf("Publish() without subscribers error = %v", err)
+	}
+}
+
+func TestRelay_Flush(t *testing.T) {
+	store := &memoryStore{}
+	store.add(1, 2, 3)
+	now := time.Now()
+	var delivered []int64
+	failing := map[int64]int{2: 1, 3: 3}
+	pub := PublisherFunc(func(_ context.Context, e Event) error {
+		if failing[e.ID] > 0 {
+			failing[e.ID]--
+			return errHandler
+		}
+		delivered = append(delivered, e.ID)
+		return nil
+	})
+	metrics := NewMetrics(prometheus.NewRegistry())
+	relay := NewRelay(store, pub, WithMaxAttempts(3), WithBackoff(time.Second, time.Second*3), WithMetrics(metrics))
+	relay.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// * A failed event holds the later ones back until its retry.
+	if n, err := relay.Flush(ctx); n != 1 || err != nil {
+		t.Fatalf("Flush() = %d, %v, want 1 delivered", n, err)
+	}
+	if n, _ := relay.Flush(ctx); n != 0 || !reflect.DeepEqual(delivered, []int64{1}) {
+		t.Fatalf("Flush() before the retry = %d, delivered %v, want [1]", n, delivered)
+	}
+	now = now.Add(time.Second)
+	_, _ = relay.Flush(ctx)
+	if !reflect.DeepEqual(delivered, []int64{1, 2}) {
+		t.Fatalf("delivered = %v, want [1 2]", delivered)
+	}
+
+	// * The max attempts dead letter an event.
+	for range 3 {
+		now = now.Add(time.Second * 3)
+		_, _ = relay.Flush(ctx)
+	}
+	if !reflect.DeepEqual(store.dead, []int64{3}) || len(store.pending) != 0 {
+		t.Errorf("dead = %v, pending %v, want 3 dead lettered", store.dead, store.pending)
+	}
+	for result, want := range map[string]float64{ResultDelivered: 2, ResultRetry: 3, ResultDead: 1} {
+		if got := testutil.ToFloat64(metrics.deliveries.WithLabelValues("note.added", result)); got != want {
+			t.Errorf("deliveries{result=%s} = %v, want %v", result, got, want)
+		}
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(&memoryStore{}, NewBus(), WithBackoff(time.Second, time.Second*5))
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: time.Second * 2, 3: time.Second * 4, 9: time.Second * 5} {
+		if got := relay.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRelay_StartStop(t *testing.T) {
+	store := &memoryStore{}
+	store.add(1, 2)
+	bus := NewBus()
+	delivered := make(chan int64, 2)
+	bus.Subscribe("note.added", "notify", func(_ context.Context, e Event) error {
+		delivered <- e.ID
+		return nil
+	})
+	relay := NewRelay(store, bus, WithInterval(time.Millisecond*10))
+	go func() { _ = relay.Start(context.Background()) }()
+
+	for _, want := range []int64{1, 2} {
+		select {
+		case id := <-delivered:
+			if id != want {
+				t.Errorf("delivered %d, want %d", id, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not delivered", want)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := relay.Stop(ctx); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	inbox := NewMemoryInbox()
+	calls := 0
+	fail := true
+	h := Idempotent(inbox, "welcome", func(context.Context, Event) error {
+		calls++
+		if fail {
+			fail = false
+			return errHandler
+		}
+		return nil
+	})
+	ctx := context.Background()
+	e := Event{ID: 7, Type: "user.registered"}
+
+	if err := h(ctx, e); !errors.Is(err, errHandler) {
+		t.Fatalf("first delivery error = %v, want errHandler", err)
+	}
+	for range 2 {
+		if err := h(ctx, e); err != nil {
+			t.Fatalf("redelivery error = %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2, the failed one and the first success", calls)
+	}
+	other := Idempotent(inbox, "audit", func(context.Context, Event) error { calls++; return nil })
+	_ = other(ctx, e)
+	if calls != 3 {
+		t.Errorf("calls = %d, want another consumer to handle the event", calls)
+	}
+}
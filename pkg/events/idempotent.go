@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Inbox records the events the consumers processed
+type Inbox interface {
+	// Once runs fn unless the consumer processed the event already, the
+	// event is recorded as processed when fn succeeds.
+	Once(ctx context.Context, consumer string, eventID int64, fn func(ctx context.Context) error) error
+}
+
+// Idempotent makes the named consumer handle every event once, however
+// often it is delivered.
+func Idempotent(inbox Inbox, consumer string, h Handler) Handler {
+	return func(ctx context.Context, e Event) error {
+		return inbox.Once(ctx, consumer, e.ID, func(ctx context.Context) error {
+			return h(ctx, e)
+		})
+	}
+}
+
+// inboxKey is an event processed by a consumer
+type inboxKey struct {
+	consumer string
+	eventID  int64
+}
+
+// MemoryInbox is an Inbox within the process, it forgets the events on
+// restart.
+type MemoryInbox struct {
+	mu        sync.Mutex
+	processed map[inboxKey]bool
+}
+
+// NewMemoryInbox creates an empty MemoryInbox
+func NewMemoryInbox() *MemoryInbox {
+	return &MemoryInbox{processed: make(map[inboxKey]bool)}
+}
+
+// Once runs fn unless the consumer processed the event already, the calls
+// run one at a time.
+func (b *MemoryInbox) Once(ctx context.Context, consumer string, eventID int64, fn func(ctx context.Context) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := inboxKey{consumer: consumer, eventID: eventID}
+	if b.processed[key] {
+		return nil
+	}
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	b.processed[key] = true
+	return nil
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// Delivery Results
+const (
+	ResultDelivered = "delivered"
+	ResultRetry     = "retry"
+	ResultDead      = "dead"
+)
+
+// Metrics counts the deliveries of the relay
+type Metrics struct {
+	deliveries *prometheus.CounterVec
+}
+
+// NewMetrics creates the Metrics registered on the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{}
+	m.deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "events",
+		Name:      "deliveries_total",
+		Help:      "How many events the outbox relay delivered, with labels type and result, delivered, retry or dead.",
+	}, []string{"type", "result"})
+	reg.MustRegister(m.deliveries)
+	return m
+}
+
+func (m *Metrics) observe(eventType, result string) {
+	if m != nil {
+		m.deliveries.WithLabelValues(eventType, result).Inc()
+	}
+}
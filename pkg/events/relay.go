@@ -0,0 +1,203 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store is the outbox the Relay delivers
+type Store interface {
+	// Pending returns the oldest pending events, at most limit
+	Pending(ctx context.Context, limit int) ([]Delivery, error)
+	// Delivered marks the event as delivered
+	Delivered(ctx context.Context, id int64) error
+	// Failed records a failed attempt, the event is retried at retryAt or
+	// dead lettered when dead is set.
+	Failed(ctx context.Context, id int64, cause error, retryAt time.Time, dead bool) error
+}
+
+// Delivery is a pending event of the outbox
+type Delivery struct {
+	Event
+	Attempts int
+	RetryAt  time.Time
+}
+
+// RelayOption configures a Relay
+type RelayOption func(*relayOptions)
+
+type relayOptions struct {
+	interval    time.Duration
+	batch       int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	metrics     *Metrics
+}
+
+// WithInterval sets how often the outbox is polled
+func WithInterval(interval time.Duration) RelayOption {
+	return func(o *relayOptions) { o.interval = interval }
+}
+
+// WithBatch sets how many events a poll reads
+func WithBatch(n int) RelayOption {
+	return func(o *relayOptions) { o.batch = n }
+}
+
+// WithMaxAttempts sets the attempts after which an event is dead lettered
+func WithMaxAttempts(n int) RelayOption {
+	return func(o *relayOptions) { o.maxAttempts = n }
+}
+
+// WithBackoff sets the delay of the first retry, it doubles on every
+// attempt up to max.
+func WithBackoff(backoff, max time.Duration) RelayOption {
+	return func(o *relayOptions) { o.backoff, o.maxBackoff = backoff, max }
+}
+
+// WithMetrics counts the deliveries
+func WithMetrics(m *Metrics) RelayOption {
+	return func(o *relayOptions) { o.metrics = m }
+}
+
+// Relay delivers the events of the outbox to the publisher in order, at
+// least once. A failed event holds the later ones back until its retry,
+// after the max attempts it is dead lettered and skipped. The relays of
+// several instances may deliver an event twice.
+type Relay struct {
+	store Store
+	pub   Publisher
+	opts  relayOptions
+	now   func() time.Time
+
+	flush   sync.Mutex
+	started atomic.Bool
+	stop    chan struct{}
+	stopped sync.Once
+	done    chan struct{}
+}
+
+// NewRelay creates the Relay of the outbox store to the publisher
+func NewRelay(store Store, pub Publisher, opts ...RelayOption) *Relay {
+	o := relayOptions{
+		interval:    time.Second,
+		batch:       100,
+		maxAttempts: 10,
+		backoff:     time.Second,
+		maxBackoff:  time.Minute * 5,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Relay{
+		store: store,
+		pub:   pub,
+		opts:  o,
+		now:   time.Now,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start delivers the pending events every interval until Stop
+func (r *Relay) Start(ctx context.Context) error {
+	r.started.Store(true)
+	defer close(r.done)
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+	for {
+		// * A full batch is followed by the next one right away.
+		n, err := r.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("relay events failed", "error", err)
+		}
+		if err == nil && n == r.opts.batch {
+			continue
+		}
+		select {
+		case <-r.stop:
+			return nil
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the deliveries, the current event is delivered first
+func (r *Relay) Stop(ctx context.Context) error {
+	r.stopped.Do(func() { close(r.stop) })
+	if !r.started.Load() {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush delivers the due pending events in order, it returns how many
+// events were delivered or dead lettered.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	r.flush.Lock()
+	defer r.flush.Unlock()
+	deliveries, err := r.store.Pending(ctx, r.opts.batch)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, d := range deliveries {
+		if r.stopping() || r.now().Before(d.RetryAt) {
+			break
+		}
+		err = r.pub.Publish(ctx, d.Event)
+		if err == nil {
+			if err = r.store.Delivered(ctx, d.ID); err != nil {
+				return n, err
+			}
+			r.opts.metrics.observe(d.Type, ResultDelivered)
+			n++
+			continue
+		}
+
+		attempts := d.Attempts + 1
+		dead := attempts >= r.opts.maxAttempts
+		if ferr := r.store.Failed(ctx, d.ID, err, r.now().Add(r.backoff(attempts)), dead); ferr != nil {
+			return n, ferr
+		}
+		if !dead {
+			r.opts.metrics.observe(d.Type, ResultRetry)
+			slog.Warn("event delivery failed", "event", d.ID, "type", d.Type, "attempts", attempts, "error", err)
+			break
+		}
+		r.opts.metrics.observe(d.Type, ResultDead)
+		slog.Error("event dead lettered", "event", d.ID, "type", d.Type, "attempts", attempts, "error", err)
+		n++
+	}
+	return n, nil
+}
+
+func (r *Relay) stopping() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay of the retry after the attempts
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.opts.backoff
+	for i := 1; i < attempts && backoff < r.opts.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, r.opts.maxBackoff)
+}
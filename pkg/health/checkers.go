@@ -0,0 +1,28 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// PingDB checks the database is reachable
+func PingDB(db *sql.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+}
+
+// DiskSpace checks the filesystem holding the path has at least minFree bytes
+func DiskSpace(path string, minFree uint64) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		free, err := freeSpace(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("disk space of %s is low: %d bytes free, want %d", path, free, minFree)
+		}
+		return nil
+	})
+}
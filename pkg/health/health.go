@@ -0,0 +1,240 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check Status
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+	StatusDraining = "draining"
+)
+
+// ErrDraining reports the application is shutting down
+var ErrDraining = errors.New("application is shutting down")
+
+// Checker checks the health of a dependency
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error { return f(ctx) }
+
+// Result is the outcome of a single check
+type Result struct {
+	Name      string        `json:"name"`
+	Status    string        `json:"status"`
+	Critical  bool          `json:"critical"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Cached    bool          `json:"cached"`
+}
+
+// Report is the outcome of all checks of a probe
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Healthy reports whether the probe passes
+func (r Report) Healthy() bool { return r.Status != StatusDown && r.Status != StatusDraining }
+
+type check struct {
+	name     string
+	checker  Checker
+	timeout  time.Duration
+	cacheTTL time.Duration
+	critical bool
+	liveness bool
+
+	mu   sync.Mutex
+	last Result
+}
+
+func (c *check) run(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.last.CheckedAt.IsZero() && time.Since(c.last.CheckedAt) < c.cacheTTL {
+		res := c.last
+		res.Cached = true
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.checker.Check(ctx)
+	res := Result{
+		Name:      c.name,
+		Status:    StatusUp,
+		Critical:  c.critical,
+		Duration:  time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	c.last = res
+	return res
+}
+
+// CheckOption setup a check
+type CheckOption func(c *check)
+
+// WithTimeout setup the timeout of a check
+func WithTimeout(timeout time.Duration) CheckOption {
+	return func(c *check) { c.timeout = timeout }
+}
+
+// WithCacheTTL setup how long a check result is reused
+func WithCacheTTL(ttl time.Duration) CheckOption {
+	return func(c *check) { c.cacheTTL = ttl }
+}
+
+// NonCritical marks a check as non-critical, its failure only degrades the probe
+func NonCritical() CheckOption {
+	return func(c *check) { c.critical = false }
+}
+
+// ForLiveness runs a check on the liveness probe instead of the readiness one,
+// only checks which a restart could fix belong there.
+func ForLiveness() CheckOption {
+	return func(c *check) { c.liveness = true }
+}
+
+// Registry holds the named checkers of the application
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*check
+
+	timeout    time.Duration
+	cacheTTL   time.Duration
+	drainDelay time.Duration
+	draining   atomic.Bool
+}
+
+// Option setup the registry
+type Option func(r *Registry)
+
+// WithDefaultTimeout setup the timeout of checks registered without one
+func WithDefaultTimeout(timeout time.Duration) Option {
+	return func(r *Registry) { r.timeout = timeout }
+}
+
+// WithDefaultCacheTTL setup the cache ttl of checks registered without one
+func WithDefaultCacheTTL(ttl time.Duration) Option {
+	return func(r *Registry) { r.cacheTTL = ttl }
+}
+
+// WithDrainDelay setup how long Shutdown waits after readiness flips,
+// it gives load balancers the time to notice and drain the instance.
+func WithDrainDelay(delay time.Duration) Option {
+	return func(r *Registry) { r.drainDelay = delay }
+}
+
+// NewRegistry creates a new Registry instance
+func NewRegistry(opts ...Option) *Registry {
+	r := &Registry{timeout: time.Second * 2, cacheTTL: time.Second}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register adds a named checker, checks are critical by default
+func (r *Registry) Register(name string, checker Checker, opts ...CheckOption) {
+	c := &check{
+		name:     name,
+		checker:  checker,
+		timeout:  r.timeout,
+		cacheTTL: r.cacheTTL,
+		critical: true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, c)
+}
+
+// Live runs the liveness checks
+func (r *Registry) Live(ctx context.Context) Report {
+	return r.probe(ctx, true)
+}
+
+// Ready runs the readiness checks, it fails as soon as shutdown starts
+func (r *Registry) Ready(ctx context.Context) Report {
+	report := r.probe(ctx, false)
+	if r.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// Shutdown flips readiness to failing and waits for the drain delay
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.draining.Store(true)
+	if r.drainDelay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(r.drainDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Registry) probe(ctx context.Context, liveness bool) Report {
+	r.mu.RLock()
+	checks := make([]*check, 0, len(r.checks))
+	for _, c := range r.checks {
+		if c.liveness == liveness {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusDown {
+			continue
+		}
+		if res.Critical {
+			report.Status = StatusDown
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
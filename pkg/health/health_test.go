@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Ready(t *testing.T) {
+	failing := CheckerFunc(func(context.Context) error { return errors.New("unreachable") })
+	passing := CheckerFunc(func(context.Context) error { return nil })
+
+	tests := []struct {
+		name   string
+		setup  func(r *Registry)
+		status string
+	}{
+		{"all up", func(r *Registry) {
+			r.Register("db", passing)
+		}, StatusUp},
+		{"critical down", func(r *Registry) {
+			r.Register("db", failing)
+			r.Register("disk", passing)
+		}, StatusDown},
+		{"non-critical down", func(r *Registry) {
+			r.Register("db", passing)
+			r.Register("redis", failing, NonCritical())
+		}, StatusDegraded},
+		{"liveness excluded", func(r *Registry) {
+			r.Register("deadlock", failing, ForLiveness())
+		}, StatusUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			tt.setup(r)
+			if got := r.Ready(context.Background()); got.Status != tt.status {
+				t.Errorf("Ready() status = %s, want %s", got.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestRegistry_Cache(t *testing.T) {
+	var calls atomic.Int32
+	r := NewRegistry(WithDefaultCacheTTL(time.Minute))
+	r.Register("db", CheckerFunc(func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	first := r.Ready(context.Background())
+	second := r.Ready(context.Background())
+	if calls.Load() != 1 {
+		t.Errorf("checker calls = %d, want 1", calls.Load())
+	}
+	if first.Checks[0].Cached || !second.Checks[0].Cached {
+		t.Errorf("cached = %v, %v, want false, true", first.Checks[0].Cached, second.Checks[0].Cached)
+	}
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	r := NewRegistry()
+	r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), WithTimeout(10*time.Millisecond))
+
+	report := r.Ready(context.Background())
+	if report.Healthy() || report.Checks[0].Error == "" {
+		t.Errorf("Ready() = %+v, want timed out check", report)
+	}
+}
+
+func TestRegistry_Shutdown(t *testing.T) {
+	r := NewRegistry()
+	r.Register("db", CheckerFunc(func(context.Context) error { return nil }))
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if got := r.Ready(context.Background()); got.Status != StatusDraining || got.Healthy() {
+		t.Errorf("Ready() status = %s, want %s", got.Status, StatusDraining)
+	}
+	if got := r.Live(context.Background()); !got.Healthy() {
+		t.Errorf("Live() status = %s, want %s", got.Status, StatusUp)
+	}
+}
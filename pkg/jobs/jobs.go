@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Job Statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+	StatusCanceled  = "canceled"
+)
+
+// Job Errors
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrDuplicate = errors.New("job of the unique key already queued")
+	ErrState     = errors.New("job status does not allow it")
+)
+
+// Job is a unit of background work, its unique key is released once it
+// finishes.
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	UniqueKey   string          `json:"unique_key,omitempty"`
+	Priority    int             `json:"priority"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedBy    string          `json:"locked_by,omitempty"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Decode decodes the payload of the job into v
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Filter narrows a job listing, the empty fields match every job
+type Filter struct {
+	Status string
+	Type   string
+	Offset int
+	Limit  int
+}
+
+// Store is the persistent queue of the jobs
+type Store interface {
+	// Enqueue inserts the job, a queued job of the same unique key is
+	// returned with ErrDuplicate instead.
+	Enqueue(ctx context.Context, job *Job) (*Job, error)
+	// Claim leases the next due job of the types to the worker, the jobs
+	// of an ended lease are claimed again. The claim counts as an attempt,
+	// it returns nil without a due job.
+	Claim(ctx context.Context, worker string, types []string, lease time.Duration) (*Job, error)
+	// Succeeded finishes the job leased to the worker
+	Succeeded(ctx context.Context, id int64, worker string) error
+	// Failed records a failed attempt of the job leased to the worker, it
+	// is retried at retryAt or dead when dead is set.
+	Failed(ctx context.Context, id int64, worker string, cause error, retryAt time.Time, dead bool) error
+	// List returns the jobs of the filter, the latest first, and their total
+	List(ctx context.Context, filter Filter) ([]*Job, int64, error)
+	// Retry queues a dead or canceled job again with its attempts reset
+	Retry(ctx context.Context, id int64) (*Job, error)
+	// Cancel cancels a pending job
+	Cancel(ctx context.Context, id int64) (*Job, error)
+}
+
+// permanentError fails a job without retrying it
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps the error of a job which can never succeed, the job is
+// dead right away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// EnqueueOption configures an enqueued job
+type EnqueueOption func(j *Job)
+
+// Delay runs the job after the delay
+func Delay(d time.Duration) EnqueueOption {
+	return func(j *Job) { j.RunAt = time.Now().Add(d) }
+}
+
+// At runs the job at the time
+func At(t time.Time) EnqueueOption {
+	return func(j *Job) { j.RunAt = t }
+}
+
+// Unique queues the job only when no queued job has the key
+func Unique(key string) EnqueueOption {
+	return func(j *Job) { j.UniqueKey = key }
+}
+
+// Priority runs the job before the due jobs of a lower priority
+func Priority(p int) EnqueueOption {
+	return func(j *Job) { j.Priority = p }
+}
+
+// MaxAttempts sets the attempts after which the job is dead
+func MaxAttempts(n int) EnqueueOption {
+	return func(j *Job) { j.MaxAttempts = n }
+}
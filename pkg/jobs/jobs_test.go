@@ -0,0 +1,279 @@
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var errHandler = errors.New("handler failed")
+
+// memoryStore is a Store of the jobs of a test
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+func (s *memoryStore) Enqueue(_ context.Context, job *Job) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if job.UniqueKey != "" && j.UniqueKey == job.UniqueKey {
+			return j, ErrDuplicate
+		}
+	}
+	job.ID = int64(len(s.jobs) + 1)
+	s.jobs = append(s.jobs, job)
+	return job, nil
+}
+
+func (s *memoryStore) Claim(_ context.Context, worker string, types []string, lease time.Duration) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var due []*Job
+	for _, j := range s.jobs {
+		if slices.Contains(types, j.Type) && (j.Status == StatusPending && !j.RunAt.After(now) ||
+			j.Status == StatusRunning && j.LockedUntil.Before(now)) {
+			due = append(due, j)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	job := slices.MinFunc(due, func(a, b *Job) int {
+		return cmp.Or(cmp.Compare(b.Priority, a.Priority), a.RunAt.Compare(b.RunAt), cmp.Compare(a.ID, b.ID))
+	})
+	until := now.Add(lease)
+	job.Status, job.LockedBy, job.LockedUntil = StatusRunning, worker, &until
+	job.Attempts++
+	claimed := *job
+	return &claimed, nil
+}
+
+func (s *memoryStore) finish(id int64, worker, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id-1]
+	if job.Status != StatusRunning || job.LockedBy != worker {
+		return ErrState
+	}
+	job.Status, job.LockedBy, job.LockedUntil = status, "", nil
+	if status != StatusPending {
+		job.UniqueKey = ""
+	}
+	return nil
+}
+
+func (s *memoryStore) Succeeded(_ context.Context, id int64, worker string) error {
+	return s.finish(id, worker, StatusSucceeded)
+}
+
+func (s *memoryStore) Failed(_ context.Context, id int64, worker string, cause error, retryAt time.Time, dead bool) error {
+	status := StatusPending
+	if dead {
+		status = StatusDead
+	}
+	if err := s.finish(id, worker, status); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id-1].LastError, s.jobs[id-1].RunAt = cause.Error(), retryAt
+	return nil
+}
+
+func (s *memoryStore) List(context.Context, Filter) ([]*Job, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, len(s.jobs))
+	for i, j := range s.jobs {
+		job := *j
+		jobs[i] = &job
+	}
+	return jobs, int64(len(jobs)), nil
+}
+
+func (s *memoryStore) Retry(context.Context, int64) (*Job, error)  { return nil, ErrState }
+func (s *memoryStore) Cancel(context.Context, int64) (*Job, error) { return nil, ErrState }
+
+// statuses returns the status of every job of the type, once none is queued
+func (s *memoryStore) statuses(t *testing.T, jobType string) []string {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); time.Sleep(time.Millisecond * 5) {
+		jobs, _, _ := s.List(context.Background(), Filter{})
+		var got []string
+		for _, j := range jobs {
+			if j.Type != jobType {
+				continue
+			}
+			if j.Status == StatusPending || j.Status == StatusRunning {
+				got = nil
+				break
+			}
+			got = append(got, j.Status)
+		}
+		if got != nil {
+			return got
+		}
+	}
+	t.Fatal("jobs still queued")
+	return nil
+}
+
+type mail struct {
+	To string `json:"to"`
+}
+
+func TestQueue_Run(t *testing.T) {
+	store := &memoryStore{}
+	reg := prometheus.NewRegistry()
+	q := New(store, WithWorkers(2), WithPoll(time.Millisecond*5), WithBackoff(time.Millisecond, time.Millisecond), WithMetrics(NewMetrics(reg)))
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	Register(q, "mail", func(_ context.Context, m mail) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[m.To]++
+		switch {
+		case m.To == "flaky" && attempts[m.To] == 1:
+			return errHandler
+		case m.To == "broken":
+			return errHandler
+		case m.To == "invalid":
+			return Permanent(errHandler)
+		case m.To == "panic":
+			panic("boom")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	for _, to := range []string{"ok", "flaky", "broken", "invalid", "panic"} {
+		if _, err := q.Enqueue(ctx, "mail", mail{To: to}, MaxAttempts(3)); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	_, _ = q.Enqueue(ctx, "mail", "not a mail")
+	_, _ = q.Enqueue(ctx, "sms", mail{To: "ok"})
+	go func() { _ = q.Start(ctx) }()
+	defer func() { _ = q.Stop(ctx) }()
+
+	got := store.statuses(t, "mail")
+	want := []string{StatusSucceeded, StatusSucceeded, StatusDead, StatusDead, StatusDead, StatusDead}
+	if !slices.Equal(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	// * The job of an unregistered type is never claimed.
+	if jobs, _, _ := store.List(ctx, Filter{}); jobs[6].Status != StatusPending {
+		t.Errorf("sms job status = %s, want pending", jobs[6].Status)
+	}
+	mu.Lock()
+	if attempts["flaky"] != 2 || attempts["broken"] != 3 || attempts["invalid"] != 1 {
+		t.Errorf("attempts = %v, want flaky 2, broken 3 and invalid 1", attempts)
+	}
+	mu.Unlock()
+	if n := testutil.ToFloat64(q.opts.metrics.processed.WithLabelValues("mail", ResultDead)); n != 4 {
+		t.Errorf("dead jobs = %v, want 4", n)
+	}
+}
+
+func TestQueue_Enqueue(t *testing.T) {
+	store := &memoryStore{}
+	q := New(store, WithMaxAttempts(7))
+	ctx := context.Background()
+
+	first, err := q.Enqueue(ctx, "export", mail{To: "a"}, Unique("export:a"), Priority(2), Delay(time.Hour))
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if first.MaxAttempts != 7 || first.Priority != 2 || time.Until(first.RunAt) < time.Minute*59 {
+		t.Errorf("Enqueue() = %+v, want 7 attempts, priority 2 and run in an hour", first)
+	}
+	dup, err := q.Enqueue(ctx, "export", mail{To: "a"}, Unique("export:a"))
+	if !errors.Is(err, ErrDuplicate) || dup.ID != first.ID {
+		t.Errorf("Enqueue() of the unique key = %+v, %v, want job %d and ErrDuplicate", dup, err, first.ID)
+	}
+	if _, err = q.Enqueue(ctx, "export", func() {}); err == nil {
+		t.Error("Enqueue() of an unencodable payload succeeded")
+	}
+}
+
+func TestQueue_Priority(t *testing.T) {
+	store := &memoryStore{}
+	q := New(store)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	_, _ = q.Enqueue(ctx, "report", nil)
+	_, _ = q.Enqueue(ctx, "report", nil, At(past))
+	_, _ = q.Enqueue(ctx, "report", nil, Priority(1))
+	_, _ = q.Enqueue(ctx, "report", nil, Delay(time.Hour), Priority(9))
+
+	var order []int64
+	for {
+		job, _ := store.Claim(ctx, "w", []string{"report"}, time.Minute)
+		if job == nil {
+			break
+		}
+		order = append(order, job.ID)
+	}
+	if want := []int64{3, 2, 1}; !slices.Equal(order, want) {
+		t.Errorf("claim order = %v, want %v", order, want)
+	}
+}
+
+func TestQueue_Backoff(t *testing.T) {
+	q := New(nil, WithBackoff(time.Second, time.Second*5))
+	for attempts, want := range []time.Duration{time.Second, time.Second, time.Second * 2, time.Second * 4, time.Second * 5, time.Second * 5} {
+		if got := q.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestQueue_StartStop(t *testing.T) {
+	store := &memoryStore{}
+	q := New(store, WithWorkers(1), WithPoll(time.Hour))
+	running, release := make(chan struct{}), make(chan struct{})
+	q.Handle("export", func(context.Context, *Job) error {
+		close(running)
+		<-release
+		return nil
+	})
+	ctx := context.Background()
+	started := make(chan error)
+	go func() { started <- q.Start(ctx) }()
+
+	// * An enqueued job wakes the idle worker before the poll.
+	_, _ = q.Enqueue(ctx, "export", nil)
+	select {
+	case <-running:
+	case <-time.After(time.Second * 5):
+		t.Fatal("job not run on enqueue")
+	}
+
+	stopped := make(chan error)
+	go func() { stopped <- q.Stop(ctx) }()
+	select {
+	case <-stopped:
+		t.Fatal("Stop() returned before the running job")
+	case <-time.After(time.Millisecond * 50):
+	}
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if err := <-started; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+	if got := store.statuses(t, "export"); !slices.Equal(got, []string{StatusSucceeded}) {
+		t.Errorf("statuses = %v, want the running job finished", got)
+	}
+}
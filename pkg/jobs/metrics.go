@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// Job Results
+const (
+	ResultSucceeded = "succeeded"
+	ResultRetry     = "retry"
+	ResultDead      = "dead"
+)
+
+// Metrics counts and times the jobs of the queue
+type Metrics struct {
+	processed *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+}
+
+// NewMetrics creates the Metrics registered on the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{}
+	m.processed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "jobs",
+		Name:      "processed_total",
+		Help:      "How many jobs the workers ran, with labels type and result, succeeded, retry or dead.",
+	}, []string{"type", "result"})
+	m.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "jobs",
+		Name:      "duration_seconds",
+		Help:      "How long the jobs ran in seconds, with label type.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 4, 10),
+	}, []string{"type"})
+	reg.MustRegister(m.processed, m.duration)
+	return m
+}
+
+func (m *Metrics) observe(jobType, result string, took time.Duration) {
+	if m != nil {
+		m.processed.WithLabelValues(jobType, result).Inc()
+		m.duration.WithLabelValues(jobType).Observe(took.Seconds())
+	}
+}
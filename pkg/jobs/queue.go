@@ -0,0 +1,311 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Handler runs a job, an error fails the attempt and the job is retried
+type Handler func(ctx context.Context, job *Job) error
+
+// Register registers the handler of the job type on the queue, the
+// payload of the job is decoded into T. A payload which does not decode
+// is never retried.
+func Register[T any](q *Queue, jobType string, fn func(ctx context.Context, payload T) error) {
+	q.Handle(jobType, func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := job.Decode(&payload); err != nil {
+			return Permanent(fmt.Errorf("decode %s payload: %w", jobType, err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Option configures a Queue
+type Option func(*options)
+
+type options struct {
+	workers     int
+	poll        time.Duration
+	lease       time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	metrics     *Metrics
+}
+
+// WithWorkers sets how many jobs run at once
+func WithWorkers(n int) Option {
+	return func(o *options) { o.workers = n }
+}
+
+// WithPoll sets how often an idle worker polls the store
+func WithPoll(poll time.Duration) Option {
+	return func(o *options) { o.poll = poll }
+}
+
+// WithLease sets how long a job may run, past it the job is canceled and
+// another worker may claim it again.
+func WithLease(lease time.Duration) Option {
+	return func(o *options) { o.lease = lease }
+}
+
+// WithMaxAttempts sets the attempts after which a job is dead, unless the
+// job was enqueued with its own.
+func WithMaxAttempts(n int) Option {
+	return func(o *options) { o.maxAttempts = n }
+}
+
+// WithBackoff sets the delay of the first retry, it doubles on every
+// attempt up to max.
+func WithBackoff(backoff, max time.Duration) Option {
+	return func(o *options) { o.backoff, o.maxBackoff = backoff, max }
+}
+
+// WithMetrics counts and times the jobs
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// Queue runs the jobs of the store on its workers, at least once. It is
+// the server.Server of the workers, Stop waits for the running jobs.
+type Queue struct {
+	store    Store
+	opts     options
+	now      func() time.Time
+	name     string
+	handlers map[string]Handler
+
+	wake    chan struct{}
+	started atomic.Bool
+	stop    chan struct{}
+	stopped sync.Once
+	done    chan struct{}
+}
+
+// New creates the Queue of the store
+func New(store Store, opts ...Option) *Queue {
+	o := options{
+		workers:     4,
+		poll:        time.Second,
+		lease:       time.Minute * 5,
+		maxAttempts: 5,
+		backoff:     time.Second * 10,
+		maxBackoff:  time.Hour,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	host, _ := os.Hostname()
+	return &Queue{
+		store:    store,
+		opts:     o,
+		now:      time.Now,
+		name:     fmt.Sprintf("%s-%d", host, os.Getpid()),
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Handle registers the handler of the job type, the workers only claim
+// the types registered before Start.
+func (q *Queue) Handle(jobType string, h Handler) {
+	q.handlers[jobType] = h
+}
+
+// Enqueue queues the job of the type with the JSON of the payload, it runs
+// right away unless delayed. The queued job of the same unique key is
+// returned with ErrDuplicate instead.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any, opts ...EnqueueOption) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := q.now()
+	job := &Job{
+		Type:        jobType,
+		Payload:     data,
+		Status:      StatusPending,
+		MaxAttempts: q.opts.maxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if job, err = q.store.Enqueue(ctx, job); err == nil && !job.RunAt.After(now) {
+		q.notify()
+	}
+	return job, err
+}
+
+// List returns the jobs of the filter and their total
+func (q *Queue) List(ctx context.Context, filter Filter) ([]*Job, int64, error) {
+	return q.store.List(ctx, filter)
+}
+
+// Retry queues a dead or canceled job again
+func (q *Queue) Retry(ctx context.Context, id int64) (*Job, error) {
+	job, err := q.store.Retry(ctx, id)
+	if err == nil {
+		q.notify()
+	}
+	return job, err
+}
+
+// Cancel cancels a pending job
+func (q *Queue) Cancel(ctx context.Context, id int64) (*Job, error) {
+	return q.store.Cancel(ctx, id)
+}
+
+// Start runs the workers until Stop
+func (q *Queue) Start(ctx context.Context) error {
+	q.started.Store(true)
+	defer close(q.done)
+	types := make([]string, 0, len(q.handlers))
+	for t := range q.handlers {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	if len(types) == 0 {
+		select {
+		case <-q.stop:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+
+	slog.Info("job workers started", "workers", q.opts.workers, "types", types)
+	var wg sync.WaitGroup
+	for i := range q.opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, fmt.Sprintf("%s-%d", q.name, i), types)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Stop stops claiming jobs and waits for the running ones
+func (q *Queue) Stop(ctx context.Context) error {
+	q.stopped.Do(func() { close(q.stop) })
+	if !q.started.Load() {
+		return nil
+	}
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work claims and runs the jobs of the types until Stop, an idle worker
+// waits for the poll or an enqueued job.
+func (q *Queue) work(ctx context.Context, worker string, types []string) {
+	for !q.stopping() {
+		job, err := q.store.Claim(ctx, worker, types, q.opts.lease)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("claim job failed", "worker", worker, "error", err)
+		}
+		if job != nil {
+			q.run(ctx, worker, job)
+			continue
+		}
+		select {
+		case <-q.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.opts.poll):
+		}
+	}
+}
+
+// run runs the claimed job within its lease and records the outcome
+func (q *Queue) run(ctx context.Context, worker string, job *Job) {
+	jobCtx, cancel := context.WithTimeout(ctx, q.opts.lease)
+	start := q.now()
+	err := q.call(jobCtx, job)
+	took := q.now().Sub(start)
+	cancel()
+
+	// * The outcome is recorded even when the run context is done.
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		if err = q.store.Succeeded(ctx, job.ID, worker); err != nil {
+			slog.Error("finish job failed", "job", job.ID, "type", job.Type, "error", err)
+			return
+		}
+		q.opts.metrics.observe(job.Type, ResultSucceeded, took)
+		return
+	}
+
+	var permanent *permanentError
+	dead := job.Attempts >= job.MaxAttempts || errors.As(err, &permanent)
+	retryAt := q.now().Add(q.backoff(job.Attempts))
+	if ferr := q.store.Failed(ctx, job.ID, worker, err, retryAt, dead); ferr != nil {
+		slog.Error("fail job failed", "job", job.ID, "type", job.Type, "error", ferr)
+		return
+	}
+	if !dead {
+		q.opts.metrics.observe(job.Type, ResultRetry, took)
+		slog.Warn("job failed", "job", job.ID, "type", job.Type, "attempts", job.Attempts, "retry_at", retryAt, "error", err)
+		return
+	}
+	q.opts.metrics.observe(job.Type, ResultDead, took)
+	slog.Error("job dead", "job", job.ID, "type", job.Type, "attempts", job.Attempts, "error", err)
+}
+
+// call runs the handler of the job, a panic fails the attempt
+func (q *Queue) call(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s panicked: %v", job.Type, r)
+		}
+	}()
+	h, ok := q.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler of job type %s", job.Type))
+	}
+	return h(ctx, job)
+}
+
+// notify wakes an idle worker
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) stopping() bool {
+	select {
+	case <-q.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay of the retry after the attempts
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.opts.backoff
+	for i := 1; i < attempts && backoff < q.opts.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, q.opts.maxBackoff)
+}
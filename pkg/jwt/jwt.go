@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/spf13/viper"
 )
 
 type JWT struct {
@@ -18,8 +17,9 @@ type MyCustomClaims struct {
 	jwt.RegisteredClaims
 }
 
-func NewJwt(conf *viper.Viper) *JWT {
-	return &JWT{key: []byte(conf.GetString("security.jwt.key"))}
+// NewJwt creates a new JWT signing the tokens with the HMAC key
+func NewJwt(key string) *JWT {
+	return &JWT{key: []byte(key)}
 }
 
 func (j *JWT) GenToken(userId string, expiresAt time.Time) (string, error) {
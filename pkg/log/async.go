@@ -0,0 +1,96 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// errAsyncClosed reports a write after the async writer closed
+var errAsyncClosed = errors.New("async log writer closed")
+
+// asyncWriter hands writes over to a background goroutine, which batches
+// them into a buffered writer flushed on interval and on close.
+type asyncWriter struct {
+	mu      sync.RWMutex
+	closed  bool
+	entries chan []byte
+	done    chan struct{}
+	out     *bufio.Writer
+	err     error
+}
+
+func newAsyncWriter(w io.Writer, opts Async) *asyncWriter {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 1024
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	aw := &asyncWriter{
+		entries: make(chan []byte, opts.Buffer),
+		done:    make(chan struct{}),
+		out:     bufio.NewWriterSize(w, 64*1024),
+	}
+	go aw.run(opts.FlushInterval)
+	return aw
+}
+
+// Write queues a copy of p, it blocks while the queue is full
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0, errAsyncClosed
+	}
+	entry := make([]byte, len(p))
+	copy(entry, p)
+	w.entries <- entry
+	return len(p), nil
+}
+
+// Close flushes the queued writes and stops the background goroutine
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.entries)
+	w.mu.Unlock()
+
+	<-w.done
+	return w.err
+}
+
+func (w *asyncWriter) run(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-w.entries:
+			if !ok {
+				w.record(w.out.Flush())
+				return
+			}
+			_, err := w.out.Write(entry)
+			w.record(err)
+			if len(w.entries) == 0 {
+				w.record(w.out.Flush())
+			}
+		case <-ticker.C:
+			w.record(w.out.Flush())
+		}
+	}
+}
+
+func (w *asyncWriter) record(err error) {
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+}
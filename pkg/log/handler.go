@@ -0,0 +1,135 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// sinkHandler is the handler of a sink with its own level
+type sinkHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+// fanoutHandler dispatches records to every sink accepting their level
+type fanoutHandler struct {
+	sinks []sinkHandler
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, s := range h.sinks {
+		if l >= s.level {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, s := range h.sinks {
+		if r.Level >= s.level {
+			errs = append(errs, s.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	sinks := make([]sinkHandler, len(h.sinks))
+	for i, s := range h.sinks {
+		sinks[i] = sinkHandler{Handler: s.WithAttrs(attrs), level: s.level}
+	}
+	return &fanoutHandler{sinks: sinks}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	sinks := make([]sinkHandler, len(h.sinks))
+	for i, s := range h.sinks {
+		sinks[i] = sinkHandler{Handler: s.WithGroup(name), level: s.level}
+	}
+	return &fanoutHandler{sinks: sinks}
+}
+
+// samplingCounters is the number of message counters, messages sharing
+// a counter by hash collision are sampled together.
+const samplingCounters = 4096
+
+type samplingCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+// inc counts a record of the tick starting at the given time
+func (c *samplingCounter) inc(now time.Time, tick time.Duration) uint64 {
+	tn := now.UnixNano()
+	resetAt := c.resetAt.Load()
+	if resetAt > tn {
+		return c.count.Add(1)
+	}
+	c.count.Store(1)
+	next := tn + tick.Nanoseconds()
+	if !c.resetAt.CompareAndSwap(resetAt, next) {
+		// * Another goroutine reset the counter first
+		return c.count.Add(1)
+	}
+	return 1
+}
+
+// samplingHandler drops repeated records of the same level and message,
+// errors are never sampled.
+type samplingHandler struct {
+	inner    slog.Handler
+	opts     Sampling
+	counters *[samplingCounters]samplingCounter
+}
+
+func newSamplingHandler(inner slog.Handler, opts Sampling) *samplingHandler {
+	if opts.Tick <= 0 {
+		opts.Tick = time.Second
+	}
+	if opts.First == 0 {
+		opts.First = 100
+	}
+	return &samplingHandler{
+		inner:    inner,
+		opts:     opts,
+		counters: new([samplingCounters]samplingCounter),
+	}
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		return h.inner.Handle(ctx, r)
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(r.Level.String()))
+	hash.Write([]byte(r.Message))
+	counter := &h.counters[hash.Sum32()%samplingCounters]
+
+	n := counter.inc(r.Time, h.opts.Tick)
+	if n <= h.opts.First {
+		return h.inner.Handle(ctx, r)
+	}
+	if h.opts.Thereafter > 0 && (n-h.opts.First)%h.opts.Thereafter == 0 {
+		return h.inner.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{inner: h.inner.WithAttrs(attrs), opts: h.opts, counters: h.counters}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{inner: h.inner.WithGroup(name), opts: h.opts, counters: h.counters}
+}
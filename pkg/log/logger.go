@@ -1,13 +1,15 @@
 package log
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/spf13/viper"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
@@ -20,43 +22,271 @@ var slogLevels = map[string]slog.Level{
 	"error": slog.LevelError,
 }
 
-// SetupSlog setting slog default logger
-func SetupSlog(conf *viper.Viper) {
-	logFile := &lumberjack.Logger{
-		Filename:   conf.GetString("log.log_file"),
-		Compress:   conf.GetBool("log.compress"),
-		MaxAge:     conf.GetInt("log.max_age"),
-		MaxSize:    conf.GetInt("log.max_size"),
-		MaxBackups: conf.GetInt("log.max_backups"),
+// Log Encodings
+const (
+	EncodingJSON    = "json"
+	EncodingText    = "text"
+	EncodingConsole = "console"
+)
+
+// Sink Types
+const (
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkFile   = "file"
+)
+
+// level is the runtime adjustable level of the default logger
+var level = new(slog.LevelVar)
+
+// ParseLevel returns the slog level of the level name
+func ParseLevel(name string) (slog.Level, error) {
+	lvl, ok := slogLevels[strings.ToLower(name)]
+	if !ok {
+		return lvl, fmt.Errorf("unknown log level %q", name)
+	}
+	return lvl, nil
+}
+
+// SetLevel changes the level of the default logger at runtime
+func SetLevel(name string) error {
+	lvl, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(lvl)
+	return nil
+}
+
+// GetLevel returns the level name of the default logger
+func GetLevel() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// Sink is an output of the logs, records below its level are dropped
+type Sink struct {
+	Type     string `mapstructure:"type"`
+	Path     string `mapstructure:"path"`
+	Level    string `mapstructure:"level"`
+	Encoding string `mapstructure:"encoding"`
+}
+
+// Rotation is the rotation policy of the file sinks
+type Rotation struct {
+	MaxAge     int  `mapstructure:"max_age"`
+	MaxSize    int  `mapstructure:"max_size"`
+	MaxBackups int  `mapstructure:"max_backups"`
+	Compress   bool `mapstructure:"compress"`
+}
+
+// Sampling drops repeated messages, within each tick the first records
+// of a message are logged, then only every thereafter-th one.
+type Sampling struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Tick       time.Duration `mapstructure:"tick"`
+	First      uint64        `mapstructure:"first"`
+	Thereafter uint64        `mapstructure:"thereafter"`
+}
+
+// Async buffers the writes of the sinks in a background goroutine
+type Async struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Buffer        int           `mapstructure:"buffer"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+}
+
+// Config is the config of the default logger
+type Config struct {
+	Encoding string            `mapstructure:"encoding"`
+	Level    string            `mapstructure:"log_level"`
+	Modules  map[string]string `mapstructure:"modules"`
+	DebugKey string            `mapstructure:"debug_key"`
+	LogFile  string            `mapstructure:"log_file"`
+	Sinks    []Sink            `mapstructure:"sinks"`
+	Sampling Sampling          `mapstructure:"sampling"`
+	Redact   Redaction         `mapstructure:"redact"`
+	Async    Async             `mapstructure:"async"`
+	Rotation `mapstructure:",squash"`
+}
+
+// Options builds the handler of the default logger
+type Options struct {
+	Encoding string
+	Sinks    []Sink
+	Rotation Rotation
+	Sampling Sampling
+	Async    Async
+	Redactor *Redactor
+}
+
+// OptionsFromConfig builds the log options from config, without sinks
+// configured it logs to stdout and to the log file like it used to.
+func OptionsFromConfig(conf Config) (Options, error) {
+	opts := Options{
+		Encoding: conf.Encoding,
+		Sinks:    conf.Sinks,
+		Rotation: conf.Rotation,
+		Sampling: conf.Sampling,
+		Async:    conf.Async,
+	}
+	switch {
+	case conf.Redact.Enabled:
+		redactor, err := NewRedactor(conf.Redact.Keys, conf.Redact.Patterns)
+		if err != nil {
+			return opts, err
+		}
+		opts.Redactor = redactor
+	case len(conf.Redact.Values) > 0:
+		opts.Redactor = &Redactor{keys: map[string]struct{}{}}
+	}
+	if opts.Redactor != nil {
+		opts.Redactor.AddValues(conf.Redact.Values...)
+	}
+
+	if len(opts.Sinks) == 0 {
+		opts.Sinks = []Sink{{Type: SinkStdout}}
+		if conf.LogFile != "" {
+			opts.Sinks = append(opts.Sinks, Sink{Type: SinkFile, Path: conf.LogFile})
+		}
+	}
+	return opts, nil
+}
+
+// SetupSlog setting slog default logger,
+// the returned func flushes and closes the sinks.
+func SetupSlog(conf Config) func() {
+	if err := ApplyLevels(conf); err != nil {
+		slog.Warn("invalid log levels in config", "error", err)
+		level.Set(slog.LevelInfo)
+	}
+
+	opts, err := OptionsFromConfig(conf)
+	if err != nil {
+		slog.Error("invalid log options in config", "error", err)
+		os.Exit(1)
+	}
+	handler, closer, err := NewHandler(opts)
+	if err != nil {
+		slog.Error("setup log sinks failed", "error", err)
+		os.Exit(1)
+	}
+
+	// * Level gating happens in front of the handler, per named module,
+	// * so the handler itself accepts everything the sinks accept.
+	setRoot(handler)
+	slog.SetDefault(slog.New(&moduleHandler{level: level}))
+	return func() {
+		if err := closer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "close log sinks failed: %v\n", err)
+		}
+	}
+}
+
+// NewHandler builds the handler writing to all sinks of the options
+func NewHandler(opts Options) (slog.Handler, io.Closer, error) {
+	var closers multiCloser
+	handlers := make([]sinkHandler, 0, len(opts.Sinks))
+	for _, sink := range opts.Sinks {
+		w, c, err := openSink(sink, opts.Rotation)
+		if err != nil {
+			_ = closers.Close()
+			return nil, nil, err
+		}
+		if opts.Async.Enabled {
+			aw := newAsyncWriter(w, opts.Async)
+			// * The async writer must be flushed before the sink underneath closes.
+			w, c = aw, multiCloser{aw, c}
+		}
+		closers = append(closers, c)
+
+		lvl := slog.LevelDebug
+		if sink.Level != "" {
+			var ok bool
+			if lvl, ok = slogLevels[strings.ToLower(sink.Level)]; !ok {
+				_ = closers.Close()
+				return nil, nil, fmt.Errorf("unknown level %q of sink %s", sink.Level, sink.Type)
+			}
+		}
+		encoding := sink.Encoding
+		if encoding == "" {
+			encoding = opts.Encoding
+		}
+		handlers = append(handlers, sinkHandler{
+			level:   lvl,
+			Handler: newEncoder(encoding, w, opts.Redactor),
+		})
+	}
+
+	var handler slog.Handler = &fanoutHandler{sinks: handlers}
+	if opts.Sampling.Enabled {
+		handler = newSamplingHandler(handler, opts.Sampling)
 	}
-	defer logFile.Close()
+	return handler, closers, nil
+}
 
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
+func newEncoder(encoding string, w io.Writer, redactor *Redactor) slog.Handler {
+	ho := &slog.HandlerOptions{
+		AddSource:   true,
+		ReplaceAttr: replaceAttr,
+		Level:       slog.LevelDebug,
+	}
+	if redactor != nil {
+		ho.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			return redactor.ReplaceAttr(groups, replaceAttr(groups, a))
+		}
+	}
+	if strings.ToLower(encoding) == EncodingJSON {
+		return slog.NewJSONHandler(w, ho)
+	}
+	return slog.NewTextHandler(w, ho)
+}
 
-	replace := func(groups []string, a slog.Attr) slog.Attr {
-		if a.Key == slog.SourceKey {
-			source := a.Value.Any().(*slog.Source)
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey {
+		if source, ok := a.Value.Any().(*slog.Source); ok {
 			source.File = filepath.Base(source.File)
 		}
-		if a.Value.Kind() == slog.KindTime {
-			return slog.String(a.Key, a.Value.Time().Format(logTime))
+	}
+	if a.Value.Kind() == slog.KindTime {
+		return slog.String(a.Key, a.Value.Time().Format(logTime))
+	}
+	return a
+}
+
+// nopCloser closes nothing, the standard streams stay open
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func openSink(sink Sink, rotation Rotation) (io.Writer, io.Closer, error) {
+	switch strings.ToLower(sink.Type) {
+	case SinkStdout, "":
+		return os.Stdout, nopCloser{}, nil
+	case SinkStderr:
+		return os.Stderr, nopCloser{}, nil
+	case SinkFile:
+		if sink.Path == "" {
+			return nil, nil, errors.New("file sink requires a path")
+		}
+		file := &lumberjack.Logger{
+			Filename:   sink.Path,
+			Compress:   rotation.Compress,
+			MaxAge:     rotation.MaxAge,
+			MaxSize:    rotation.MaxSize,
+			MaxBackups: rotation.MaxBackups,
 		}
-		return a
+		return file, file, nil
 	}
+	return nil, nil, fmt.Errorf("unknown sink type %q", sink.Type)
+}
 
-	level, ok := slogLevels[strings.ToLower(conf.GetString("log.log_level"))]
-	if !ok {
-		level = slog.LevelInfo
-	}
-
-	// * Text Log Format
-	logger := slog.New(slog.NewTextHandler(
-		multiWriter,
-		&slog.HandlerOptions{
-			AddSource:   true,
-			ReplaceAttr: replace,
-			Level:       level,
-		},
-	))
-	slog.SetDefault(logger)
+// multiCloser closes all closers in order, it returns the joined errors
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var errs []error
+	for _, c := range m {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
 }
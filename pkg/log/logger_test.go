@@ -0,0 +1,168 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s error = %v", path, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func newTestLogger(t *testing.T, opts Options) *slog.Logger {
+	t.Helper()
+	handler, closer, err := NewHandler(opts)
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	t.Cleanup(func() { _ = closer.Close() })
+	return slog.New(handler)
+}
+
+func TestNewHandler_Encoding(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile, textFile := filepath.Join(dir, "json.log"), filepath.Join(dir, "text.log")
+	handler, closer, err := NewHandler(Options{
+		Encoding: EncodingJSON,
+		Sinks: []Sink{
+			{Type: SinkFile, Path: jsonFile},
+			{Type: SinkFile, Path: textFile, Encoding: EncodingConsole},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	slog.New(handler).Info("user login", "user", "u1", "attempt", 2)
+	if err = closer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := readLines(t, jsonFile)
+	if len(lines) != 1 {
+		t.Fatalf("json lines = %d, want 1", len(lines))
+	}
+	var record map[string]any
+	if err = json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("json line %q error = %v", lines[0], err)
+	}
+	for key, want := range map[string]any{"level": "INFO", "msg": "user login", "user": "u1", "attempt": 2.0} {
+		if record[key] != want {
+			t.Errorf("json %s = %v, want %v", key, record[key], want)
+		}
+	}
+	if _, ok := record["time"].(string); !ok {
+		t.Errorf("json time = %v, want formatted string", record["time"])
+	}
+
+	lines = readLines(t, textFile)
+	pattern := regexp.MustCompile(`^time=\S+ level=INFO source=logger_test.go:\d+ msg="user login" user=u1 attempt=2$`)
+	if len(lines) != 1 || !pattern.MatchString(lines[0]) {
+		t.Errorf("text lines = %q, want match %s", lines, pattern)
+	}
+}
+
+func TestNewHandler_SinkLevels(t *testing.T) {
+	dir := t.TempDir()
+	all, errs := filepath.Join(dir, "trove.log"), filepath.Join(dir, "trove.error.log")
+	handler, closer, err := NewHandler(Options{Sinks: []Sink{
+		{Type: SinkFile, Path: all, Level: "info"},
+		{Type: SinkFile, Path: errs, Level: "error"},
+	}})
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	logger := slog.New(handler)
+	logger.Debug("dropped")
+	logger.Info("served")
+	logger.Error("failed")
+	if err = closer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := strings.Join(readLines(t, all), "\n"); strings.Contains(got, "dropped") ||
+		!strings.Contains(got, "served") || !strings.Contains(got, "failed") {
+		t.Errorf("info sink = %s, want served and failed", got)
+	}
+	if got := readLines(t, errs); len(got) != 1 || !strings.Contains(got[0], "failed") {
+		t.Errorf("error sink = %q, want only failed", got)
+	}
+}
+
+func TestNewHandler_Sampling(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trove.log")
+	logger := newTestLogger(t, Options{
+		Sinks:    []Sink{{Type: SinkFile, Path: file}},
+		Sampling: Sampling{Enabled: true, First: 2, Thereafter: 3},
+	})
+	for range 10 {
+		logger.Info("hot path")
+		logger.Error("always")
+	}
+	logger.Info("cold path")
+
+	var hot, always, cold int
+	for _, line := range readLines(t, file) {
+		switch {
+		case strings.Contains(line, "hot path"):
+			hot++
+		case strings.Contains(line, "always"):
+			always++
+		case strings.Contains(line, "cold path"):
+			cold++
+		}
+	}
+	// * First 2 records, then the 5th and 8th ones
+	if hot != 4 || always != 10 || cold != 1 {
+		t.Errorf("sampled hot, always, cold = %d, %d, %d, want 4, 10, 1", hot, always, cold)
+	}
+}
+
+func TestNewHandler_Async(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trove.log")
+	handler, closer, err := NewHandler(Options{
+		Sinks: []Sink{{Type: SinkFile, Path: file}},
+		Async: Async{Enabled: true, Buffer: 8},
+	})
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	logger := slog.New(handler)
+	for range 100 {
+		logger.Info("buffered")
+	}
+	if err = closer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := len(readLines(t, file)); got != 100 {
+		t.Errorf("flushed lines = %d, want 100", got)
+	}
+	if err = handler.Handle(t.Context(), slog.Record{Message: "late"}); err == nil {
+		t.Error("Handle() after close error = nil, want closed error")
+	}
+}
+
+func TestNewHandler_InvalidSink(t *testing.T) {
+	for _, sink := range []Sink{{Type: "syslog"}, {Type: SinkFile}, {Type: SinkStdout, Level: "loud"}} {
+		if _, _, err := NewHandler(Options{Sinks: []Sink{sink}}); err == nil {
+			t.Errorf("NewHandler(%+v) error = nil, want error", sink)
+		}
+	}
+}
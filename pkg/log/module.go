@@ -0,0 +1,312 @@
+package log
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Module Names
+const (
+	ModuleHTTP    = "http"
+	ModuleORM     = "orm"
+	ModuleService = "service"
+	ModuleGRPC    = "grpc"
+	ModuleAuth    = "auth"
+)
+
+// ForceDebugKey is the context key forcing debug logs of a single request
+type ForceDebugKey struct{}
+
+// WithForceDebug returns a context whose logs are emitted at debug level
+func WithForceDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ForceDebugKey{}, true)
+}
+
+func forceDebug(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	forced, _ := ctx.Value(ForceDebugKey{}).(bool)
+	return forced
+}
+
+// moduleLevel is the level of a named logger, it follows the default
+// level until explicitly set, a temporary override wins until it expires.
+type moduleLevel struct {
+	explicit atomic.Bool
+	level    slog.LevelVar
+	override atomic.Pointer[temporary]
+}
+
+type temporary struct {
+	level slog.Level
+	until time.Time
+}
+
+func (m *moduleLevel) Level() slog.Level {
+	if t := m.override.Load(); t != nil {
+		if time.Now().Before(t.until) {
+			return t.level
+		}
+		m.override.CompareAndSwap(t, nil)
+	}
+	if m.explicit.Load() {
+		return m.level.Level()
+	}
+	return level.Level()
+}
+
+var (
+	modulesMu sync.RWMutex
+	modules   = map[string]*moduleLevel{}
+)
+
+func init() {
+	for _, name := range []string{ModuleHTTP, ModuleORM, ModuleService, ModuleGRPC, ModuleAuth} {
+		modules[name] = new(moduleLevel)
+	}
+}
+
+func lookupModule(name string) (*moduleLevel, bool) {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	m, ok := modules[name]
+	return m, ok
+}
+
+func registerModule(name string) *moduleLevel {
+	if m, ok := lookupModule(name); ok {
+		return m
+	}
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if m, ok := modules[name]; ok {
+		return m
+	}
+	m := new(moduleLevel)
+	modules[name] = m
+	return m
+}
+
+// Named returns the logger of the named module, its level is adjustable
+// at runtime and it writes through the handler installed by SetupSlog.
+func Named(module string) *slog.Logger {
+	return slog.New(&moduleHandler{
+		level: registerModule(module),
+		ops:   []func(slog.Handler) slog.Handler{withModule(module)},
+	})
+}
+
+// SetModuleLevel changes the level of the named module at runtime,
+// an empty module name changes the default level.
+func SetModuleLevel(module, name string) error {
+	if module == "" {
+		return SetLevel(name)
+	}
+	m, ok := lookupModule(module)
+	if !ok {
+		return fmt.Errorf("unknown log module %q", module)
+	}
+	lvl, ok := slogLevels[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	m.level.Set(lvl)
+	m.explicit.Store(true)
+	return nil
+}
+
+// ResetModuleLevel makes the named module follow the default level again
+func ResetModuleLevel(module string) error {
+	m, ok := lookupModule(module)
+	if !ok {
+		return fmt.Errorf("unknown log module %q", module)
+	}
+	m.explicit.Store(false)
+	m.override.Store(nil)
+	return nil
+}
+
+// DebugFor enables debug logs of the named module for the given duration,
+// the module falls back to its own level once the override expires.
+func DebugFor(module string, d time.Duration) error {
+	m, ok := lookupModule(module)
+	if !ok {
+		return fmt.Errorf("unknown log module %q", module)
+	}
+	m.override.Store(&temporary{level: slog.LevelDebug, until: time.Now().Add(d)})
+	return nil
+}
+
+// ModuleInfo is the level state of a named module
+type ModuleInfo struct {
+	Module   string     `json:"module"`
+	Level    string     `json:"level"`
+	Explicit bool       `json:"explicit"`
+	Until    *time.Time `json:"override_until,omitempty"`
+}
+
+// Modules returns the level state of all named modules
+func Modules() []ModuleInfo {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+
+	infos := make([]ModuleInfo, 0, len(modules))
+	for name, m := range modules {
+		info := ModuleInfo{
+			Module:   name,
+			Level:    strings.ToLower(m.Level().String()),
+			Explicit: m.explicit.Load(),
+		}
+		if t := m.override.Load(); t != nil && time.Now().Before(t.until) {
+			until := t.until
+			info.Until = &until
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Module < infos[j].Module })
+	return infos
+}
+
+// ApplyLevels sets the default and module levels from config, modules
+// missing from config follow the default level again. An unexpired
+// DebugFor override is kept.
+func ApplyLevels(conf Config) error {
+	name := conf.Level
+	if name == "" {
+		name = slog.LevelInfo.String()
+	}
+	if err := SetLevel(name); err != nil {
+		return err
+	}
+	configured := conf.Modules
+	modulesMu.RLock()
+	mods := make(map[string]*moduleLevel, len(modules))
+	for name, m := range modules {
+		mods[name] = m
+	}
+	modulesMu.RUnlock()
+
+	for name, m := range mods {
+		lvl, ok := configured[name]
+		if !ok {
+			m.explicit.Store(false)
+			continue
+		}
+		if err := SetModuleLevel(name, lvl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SignDebugToken returns the value of the debug header valid until expiry
+func SignDebugToken(key []byte, expiry time.Time) string {
+	ts := strconv.FormatInt(expiry.Unix(), 10)
+	return ts + "." + signature(key, ts)
+}
+
+// VerifyDebugToken reports whether the debug header value is signed
+// with the key and not yet expired.
+func VerifyDebugToken(key []byte, token string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	ts, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expiry, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signature(key, ts)))
+}
+
+func signature(key []byte, msg string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// root is the handler installed by SetupSlog, named loggers write through it
+var (
+	root       atomic.Pointer[slog.Handler]
+	generation atomic.Uint64
+)
+
+func setRoot(h slog.Handler) {
+	root.Store(&h)
+	generation.Add(1)
+}
+
+func rootHandler() slog.Handler {
+	if h := root.Load(); h != nil {
+		return *h
+	}
+	return slog.Default().Handler()
+}
+
+func withModule(module string) func(slog.Handler) slog.Handler {
+	return func(h slog.Handler) slog.Handler {
+		return h.WithAttrs([]slog.Attr{slog.String("module", module)})
+	}
+}
+
+// moduleHandler gates records by the module level and replays its
+// attrs and groups on the root handler, so it survives SetupSlog.
+type moduleHandler struct {
+	level interface{ Level() slog.Level }
+	ops   []func(slog.Handler) slog.Handler
+	cache atomic.Pointer[derived]
+}
+
+type derived struct {
+	generation uint64
+	handler    slog.Handler
+}
+
+func (h *moduleHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.level.Level() || forceDebug(ctx)
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithAttrs(attrs) })
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithGroup(name) })
+}
+
+func (h *moduleHandler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &moduleHandler{level: h.level, ops: append(ops, op)}
+}
+
+func (h *moduleHandler) handler() slog.Handler {
+	gen := generation.Load()
+	if d := h.cache.Load(); d != nil && d.generation == gen {
+		return d.handler
+	}
+	inner := rootHandler()
+	for _, op := range h.ops {
+		inner = op(inner)
+	}
+	h.cache.Store(&derived{generation: gen, handler: inner})
+	return inner
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func captureRoot(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	setRoot(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	level.Set(slog.LevelInfo)
+	t.Cleanup(func() {
+		for _, info := range Modules() {
+			_ = ResetModuleLevel(info.Module)
+		}
+	})
+	return buf
+}
+
+func TestNamed_Levels(t *testing.T) {
+	buf := captureRoot(t)
+	orm, http := Named(ModuleORM), Named(ModuleHTTP)
+
+	if err := SetModuleLevel(ModuleORM, "warn"); err != nil {
+		t.Fatalf("SetModuleLevel() error = %v", err)
+	}
+	orm.Info("orm info")
+	orm.Warn("orm warn")
+	http.Info("http info")
+	http.Debug("http debug")
+
+	out := buf.String()
+	for _, want := range []string{"orm warn", "http info", "module=orm", "module=http"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"orm info", "http debug"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output contains %q:\n%s", unwanted, out)
+		}
+	}
+
+	if err := SetModuleLevel("nope", "debug"); err == nil {
+		t.Error("SetModuleLevel() of unknown module error = nil")
+	}
+}
+
+func TestNamed_FollowsDefault(t *testing.T) {
+	buf := captureRoot(t)
+	logger := Named(ModuleService)
+
+	logger.Debug("before")
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	logger.Debug("after")
+
+	if out := buf.String(); strings.Contains(out, "before") || !strings.Contains(out, "after") {
+		t.Errorf("output = %s, want only the record after SetLevel", out)
+	}
+}
+
+func TestDebugFor(t *testing.T) {
+	buf := captureRoot(t)
+	logger := Named(ModuleAuth)
+
+	if err := DebugFor(ModuleAuth, 20*time.Millisecond); err != nil {
+		t.Fatalf("DebugFor() error = %v", err)
+	}
+	logger.Debug("during override")
+	time.Sleep(30 * time.Millisecond)
+	logger.Debug("after override")
+
+	if out := buf.String(); !strings.Contains(out, "during override") || strings.Contains(out, "after override") {
+		t.Errorf("output = %s, want only the record during the override", out)
+	}
+}
+
+func TestApplyLevels_KeepsOverride(t *testing.T) {
+	buf := captureRoot(t)
+	logger := Named(ModuleAuth)
+
+	if err := SetModuleLevel(ModuleAuth, "error"); err != nil {
+		t.Fatalf("SetModuleLevel() error = %v", err)
+	}
+	if err := DebugFor(ModuleAuth, time.Minute); err != nil {
+		t.Fatalf("DebugFor() error = %v", err)
+	}
+	if err := ApplyLevels(Config{Level: "info"}); err != nil {
+		t.Fatalf("ApplyLevels() error = %v", err)
+	}
+	logger.Debug("override kept")
+
+	if out := buf.String(); !strings.Contains(out, "override kept") {
+		t.Errorf("output = %s, want the override to survive ApplyLevels", out)
+	}
+	for _, info := range Modules() {
+		if info.Module == ModuleAuth && (info.Explicit || info.Until == nil) {
+			t.Errorf("auth module = %+v, want the explicit level reset and the override kept", info)
+		}
+	}
+}
+
+func TestForceDebug(t *testing.T) {
+	buf := captureRoot(t)
+	logger := Named(ModuleHTTP)
+
+	logger.DebugContext(context.Background(), "plain")
+	logger.DebugContext(WithForceDebug(context.Background()), "forced")
+
+	if out := buf.String(); strings.Contains(out, "plain") || !strings.Contains(out, "forced") {
+		t.Errorf("output = %s, want only the forced record", out)
+	}
+}
+
+func TestDebugToken(t *testing.T) {
+	key := []byte("debug-key")
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"valid", SignDebugToken(key, time.Now().Add(time.Minute)), true},
+		{"expired", SignDebugToken(key, time.Now().Add(-time.Minute)), false},
+		{"other key", SignDebugToken([]byte("other"), time.Now().Add(time.Minute)), false},
+		{"malformed", "garbage", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyDebugToken(key, tt.token); got != tt.want {
+				t.Errorf("VerifyDebugToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -28,10 +28,44 @@ const (
 	Info
 )
 
+var ormLevels = map[string]Level{
+	"silent": Silent,
+	"error":  Error,
+	"warn":   Warn,
+	"info":   Info,
+}
+
+// OrmConfig is the ORM logging config of a database connection
+type OrmConfig struct {
+	Level          string        `mapstructure:"level"`
+	SlowThreshold  time.Duration `mapstructure:"slow_threshold"`
+	Parameterized  bool          `mapstructure:"parameterized"`
+	IgnoreNotFound bool          `mapstructure:"ignore_not_found"`
+}
+
+// Option converts the config into the ORM log option
+func (c OrmConfig) Option() (Option, error) {
+	lvl, ok := ormLevels[strings.ToLower(c.Level)]
+	if !ok {
+		return Option{}, fmt.Errorf("unknown orm log level %q", c.Level)
+	}
+	return Option{
+		LogLevel:                  lvl,
+		SlowThreshold:             c.SlowThreshold,
+		ParameterizedQueries:      c.Parameterized,
+		IgnoreRecordNotFoundError: c.IgnoreNotFound,
+	}, nil
+}
+
+// SlowHook is called with every query slower than the threshold,
+// whatever the log level is.
+type SlowHook func(ctx context.Context, sql string, elapsed time.Duration)
+
 // Ormlogger records ORM logs with a structured logger
 type Ormlogger struct {
 	*slog.Logger
 	option logger.Config
+	onSlow SlowHook
 }
 
 // DefaultOrmlogger create a default Ormlogger instance
@@ -46,15 +80,33 @@ func DefaultOrmlogger() *Ormlogger {
 // NewOrmlogger creates a new Ormlogger instance
 func NewOrmlogger(logger *slog.Logger, opts Option) *Ormlogger {
 	if logger == nil {
-		logger = slog.Default()
+		logger = Named(ModuleORM)
 	}
 	return &Ormlogger{Logger: logger, option: opts}
 }
 
+// OnSlow returns a copy of the logger reporting slow queries to the hook
+func (o *Ormlogger) OnSlow(hook SlowHook) *Ormlogger {
+	l := NewOrmlogger(o.Logger, o.option)
+	l.onSlow = hook
+	return l
+}
+
 // LogMode log mode
 func (o *Ormlogger) LogMode(level logger.LogLevel) logger.Interface {
-	o.option.LogLevel = level
-	return NewOrmlogger(o.Logger, o.option)
+	l := NewOrmlogger(o.Logger, o.option)
+	l.option.LogLevel = level
+	l.onSlow = o.onSlow
+	return l
+}
+
+// ParamsFilter drops the query params from the logged SQL
+// when the logger runs in parameterized mode.
+func (o *Ormlogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if o.option.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
 }
 
 // Info prints info message
@@ -81,12 +133,19 @@ func (o *Ormlogger) Error(ctx context.Context, msg string, data ...any) {
 // Trace logs SQL queries and execution details
 func (o *Ormlogger) Trace(ctx context.Context, begin time.Time,
 	fc func() (sql string, rowsAffected int64), err error) {
-	if o.option.LogLevel <= logger.Silent {
+	elapsed := time.Since(begin)
+	slow := o.option.SlowThreshold > 0 && elapsed > o.option.SlowThreshold
+	if o.option.LogLevel <= logger.Silent && !(slow && o.onSlow != nil) {
 		return
 	}
 
-	elapsed := time.Since(begin)
 	sql, rows := fc()
+	if slow && o.onSlow != nil {
+		o.onSlow(ctx, sql, elapsed)
+	}
+	if o.option.LogLevel <= logger.Silent {
+		return
+	}
 	attrs := []any{
 		"caller", shortCaller(utils.FileWithLineNum()),
 		"sql", sql,
@@ -102,8 +161,7 @@ func (o *Ormlogger) Trace(ctx context.Context, begin time.Time,
 		}
 		o.Logger.ErrorContext(ctx, "Query Error", append(attrs, "error", err)...)
 
-	case elapsed > o.option.SlowThreshold && o.option.SlowThreshold > 0 &&
-		o.option.LogLevel >= logger.Warn:
+	case slow && o.option.LogLevel >= logger.Warn:
 		msg := fmt.Sprintf("SLOW SQL >= %v", o.option.SlowThreshold)
 		o.Logger.WarnContext(ctx, msg, attrs...)
 
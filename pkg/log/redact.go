@@ -0,0 +1,179 @@
+package log
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mask replaces the redacted values
+const Mask = "******"
+
+// redactTag marks struct fields masked when logged with slog.Any
+const redactTag = "redact"
+
+// DefaultRedactKeys are the attribute keys always masked
+var DefaultRedactKeys = []string{
+	"password", "passwd", "secret", "token", "access_token", "refresh_token",
+	"authorization", "cookie", "api_key", "dsn", "email",
+}
+
+// DefaultRedactPatterns are the values masked wherever they appear
+var DefaultRedactPatterns = []string{
+	// * Email addresses
+	`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`,
+	// * Bearer tokens and JWTs
+	`(?i)bearer\s+[a-z0-9\-._~+/]+=*`,
+	`eyJ[a-zA-Z0-9_\-]+\.[a-zA-Z0-9_\-]+\.[a-zA-Z0-9_\-]+`,
+	// * Bcrypt password hashes
+	`\$2[aby]?\$\d{2}\$[./A-Za-z0-9]{53}`,
+}
+
+// Redaction configures the redactor of the sinks, the values are the
+// secrets resolved at runtime, they are masked even when not enabled.
+type Redaction struct {
+	Enabled  bool     `mapstructure:"enabled"`
+	Keys     []string `mapstructure:"keys"`
+	Patterns []string `mapstructure:"patterns"`
+	Values   []string `mapstructure:"-"`
+}
+
+// Redactor masks secrets and PII of log attributes, by key name,
+// by value pattern and by the redact:"true" tag of struct fields.
+type Redactor struct {
+	keys     map[string]struct{}
+	patterns []*regexp.Regexp
+
+	mu     sync.RWMutex
+	values []string
+	types  sync.Map // reflect.Type -> bool, whether it has redacted fields
+}
+
+// NewRedactor creates a new Redactor with the default keys and patterns
+// extended by the given ones.
+func NewRedactor(keys, patterns []string) (*Redactor, error) {
+	r := &Redactor{keys: make(map[string]struct{})}
+	for _, key := range append(DefaultRedactKeys, keys...) {
+		r.keys[strings.ToLower(key)] = struct{}{}
+	}
+	for _, p := range append(DefaultRedactPatterns, patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// AddValues masks the given literal values wherever they appear,
+// it is meant for secrets only known at runtime.
+func (r *Redactor) AddValues(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range values {
+		if v != "" {
+			r.values = append(r.values, v)
+		}
+	}
+}
+
+// ReplaceAttr masks the attribute, it fits slog.HandlerOptions.ReplaceAttr
+func (r *Redactor) ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if _, ok := r.keys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, Mask)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.String(a.Value.String()))
+	case slog.KindAny:
+		if v, ok := r.redactAny(a.Value.Any()); ok {
+			return slog.Attr{Key: a.Key, Value: v}
+		}
+	}
+	return a
+}
+
+// String masks the secret values and the pattern matches of s
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+	r.mu.RUnlock()
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// redactAny rewrites structs having redacted fields into groups
+func (r *Redactor) redactAny(v any) (slog.Value, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return slog.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || !r.hasRedacted(rv.Type()) {
+		return slog.Value{}, false
+	}
+	return r.group(rv), true
+}
+
+func (r *Redactor) group(rv reflect.Value) slog.Value {
+	rt := rv.Type()
+	attrs := make([]slog.Attr, 0, rt.NumField())
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		fv := rv.Field(i)
+		switch {
+		case field.Tag.Get(redactTag) == "true":
+			attrs = append(attrs, slog.String(name, Mask))
+		default:
+			attr := r.ReplaceAttr(nil, slog.Any(name, fv.Interface()))
+			attrs = append(attrs, attr)
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (r *Redactor) hasRedacted(rt reflect.Type) bool {
+	if v, ok := r.types.Load(rt); ok {
+		return v.(bool)
+	}
+	// * Mark first, so that recursive types terminate
+	r.types.Store(rt, false)
+	found := false
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Tag.Get(redactTag) == "true" || (ft.Kind() == reflect.Struct && r.hasRedacted(ft)) {
+			found = true
+			break
+		}
+	}
+	r.types.Store(rt, found)
+	return found
+}
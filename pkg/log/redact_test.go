@@ -0,0 +1,107 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type account struct {
+	Name     string `json:"name"`
+	Password string `json:"password" redact:"true"`
+	Card     string `redact:"true"`
+	Profile  *profile
+}
+
+type profile struct {
+	Nickname string
+	Phone    string `redact:"true"`
+}
+
+func newRedactedLogger(t *testing.T, buf *bytes.Buffer) *slog.Logger {
+	t.Helper()
+	redactor, err := NewRedactor([]string{"ssn"}, []string{`\b\d{3}-\d{2}-\d{4}\b`})
+	if err != nil {
+		t.Fatalf("NewRedactor() error = %v", err)
+	}
+	redactor.AddValues("hunter2")
+	return slog.New(newEncoder(EncodingJSON, buf, redactor))
+}
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    func(l *slog.Logger)
+		key    string
+		want   any
+		absent []string
+	}{
+		{"key name", func(l *slog.Logger) { l.Info("login", "password", "p@ss") }, "password", Mask, []string{"p@ss"}},
+		{"key case", func(l *slog.Logger) { l.Info("req", "Authorization", "Basic abc") }, "Authorization", Mask, nil},
+		{"configured key", func(l *slog.Logger) { l.Info("req", "ssn", 42) }, "ssn", Mask, nil},
+		{"email value", func(l *slog.Logger) {
+			l.Info("query", "sql", "SELECT * FROM users WHERE email = 'alan@trove.io'")
+		}, "sql", "SELECT * FROM users WHERE email = '******'", []string{"alan@trove.io"}},
+		{"configured pattern", func(l *slog.Logger) { l.Info("form", "note", "ssn 123-45-6789") }, "note", "ssn ******", nil},
+		{"runtime secret", func(l *slog.Logger) { l.Info("dump", "line", "pass=hunter2") }, "line", "pass=******", nil},
+		{"message", func(l *slog.Logger) { l.Info("sent mail to alan@trove.io") }, "msg", "sent mail to ******", nil},
+		{"untouched", func(l *slog.Logger) { l.Info("ok", "user", "u1") }, "user", "u1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			tt.log(newRedactedLogger(t, buf))
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("json %q error = %v", buf.String(), err)
+			}
+			if record[tt.key] != tt.want {
+				t.Errorf("%s = %v, want %v", tt.key, record[tt.key], tt.want)
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(buf.String(), s) {
+					t.Errorf("output %s leaks %q", buf.String(), s)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactor_StructTag(t *testing.T) {
+	buf := new(bytes.Buffer)
+	newRedactedLogger(t, buf).Info("created", "account", &account{
+		Name:     "alan",
+		Password: "s3cret",
+		Card:     "4111111111111111",
+		Profile:  &profile{Nickname: "al", Phone: "555-0100"},
+	})
+
+	var record struct {
+		Account map[string]any `json:"account"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("json %q error = %v", buf.String(), err)
+	}
+	got := record.Account
+	if got["name"] != "alan" || got["password"] != Mask || got["Card"] != Mask {
+		t.Errorf("account = %v, want name kept and secrets masked", got)
+	}
+	nested, _ := got["Profile"].(map[string]any)
+	if nested["Nickname"] != "al" || nested["Phone"] != Mask {
+		t.Errorf("profile = %v, want phone masked", nested)
+	}
+	for _, leak := range []string{"s3cret", "4111111111111111", "555-0100"} {
+		if strings.Contains(buf.String(), leak) {
+			t.Errorf("output %s leaks %q", buf.String(), leak)
+		}
+	}
+}
+
+func TestNewRedactor_InvalidPattern(t *testing.T) {
+	if _, err := NewRedactor(nil, []string{"("}); err == nil {
+		t.Error("NewRedactor() error = nil, want invalid pattern error")
+	}
+}
@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"context"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Drift Kinds
+const (
+	DriftMissingTable  = "missing_table"
+	DriftMissingColumn = "missing_column"
+	DriftExtraColumn   = "extra_column"
+	DriftMissingIndex  = "missing_index"
+	DriftNullable      = "nullable"
+	DriftUnique        = "unique"
+)
+
+// Drift is a difference between the live schema and a GORM model
+type Drift struct {
+	Table  string `json:"table"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Drift compares the live schema against the models, it reports the
+// missing tables, columns, indexes and unique constraints, the columns
+// no model maps and the nullability mismatches. Column types are dialect
+// specific and not compared.
+func (m *Migrator) Drift(ctx context.Context) ([]Drift, error) {
+	db := m.db.WithContext(ctx)
+	migrator := db.Migrator()
+	var list []Drift
+	for _, model := range m.models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		s := stmt.Schema
+		if !migrator.HasTable(model) {
+			list = append(list, Drift{Table: s.Table, Kind: DriftMissingTable})
+			continue
+		}
+		columns, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, columnDrift(s, columns)...)
+		for _, idx := range s.ParseIndexes() {
+			if !migrator.HasIndex(model, idx.Name) {
+				list = append(list, Drift{Table: s.Table, Kind: DriftMissingIndex, Name: idx.Name})
+			}
+		}
+	}
+	return list, nil
+}
+
+func columnDrift(s *schema.Schema, columns []gorm.ColumnType) []Drift {
+	var list []Drift
+	live := make(map[string]gorm.ColumnType, len(columns))
+	for _, column := range columns {
+		live[column.Name()] = column
+	}
+	for _, name := range s.DBNames {
+		column, ok := live[name]
+		if !ok {
+			list = append(list, Drift{Table: s.Table, Kind: DriftMissingColumn, Name: name})
+			continue
+		}
+		field := s.FieldsByDBName[name]
+		if nullable, ok := column.Nullable(); ok && !field.PrimaryKey && nullable == field.NotNull {
+			detail := "model not null, column nullable"
+			if !nullable {
+				detail = "model nullable, column not null"
+			}
+			list = append(list, Drift{Table: s.Table, Kind: DriftNullable, Name: name, Detail: detail})
+		}
+		if unique, ok := column.Unique(); ok && field.Unique && !unique {
+			list = append(list, Drift{Table: s.Table, Kind: DriftUnique, Name: name, Detail: "model unique, column not"})
+		}
+	}
+	for _, column := range columns {
+		if !slices.Contains(s.DBNames, column.Name()) {
+			list = append(list, Drift{Table: s.Table, Kind: DriftExtraColumn, Name: column.Name()})
+		}
+	}
+	return list
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// locker is the migration lock of a dialect, it is taken and released
+// on the same connection.
+type locker interface {
+	tryLock(db *gorm.DB) (bool, error)
+	unlock(db *gorm.DB) error
+	release(db *gorm.DB) error
+}
+
+func newLocker(dialect, table string) locker {
+	switch dialect {
+	case "postgres":
+		h := fnv.New64a()
+		h.Write([]byte(table))
+		return &pgLock{key: int64(h.Sum64())}
+	case "mysql":
+		return &mysqlLock{name: table}
+	}
+	host, _ := os.Hostname()
+	return &tableLock{
+		table: table + "_lock",
+		owner: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// pgLock is a session advisory lock of postgres
+type pgLock struct {
+	key int64
+}
+
+func (l *pgLock) tryLock(db *gorm.DB) (ok bool, err error) {
+	err = db.Raw("SELECT pg_try_advisory_lock(?)", l.key).Scan(&ok).Error
+	return ok, err
+}
+
+func (l *pgLock) unlock(db *gorm.DB) error {
+	return db.Exec("SELECT pg_advisory_unlock(?)", l.key).Error
+}
+
+func (l *pgLock) release(*gorm.DB) error { return nil }
+
+// mysqlLock is a named lock of mysql
+type mysqlLock struct {
+	name string
+}
+
+func (l *mysqlLock) tryLock(db *gorm.DB) (bool, error) {
+	var got *int
+	err := db.Raw("SELECT GET_LOCK(?, 0)", l.name).Scan(&got).Error
+	return got != nil && *got == 1, err
+}
+
+func (l *mysqlLock) unlock(db *gorm.DB) error {
+	return db.Exec("SELECT RELEASE_LOCK(?)", l.name).Error
+}
+
+func (l *mysqlLock) release(*gorm.DB) error { return nil }
+
+// tableLock is a single row lock table for the dialects without advisory
+// locks, a crashed holder leaves the row behind until released.
+type tableLock struct {
+	table string
+	owner string
+}
+
+func (l *tableLock) tryLock(db *gorm.DB) (bool, error) {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS ? (
+		id INTEGER NOT NULL PRIMARY KEY,
+		owner VARCHAR(255) NOT NULL,
+		locked_at TIMESTAMP NOT NULL
+	)`, l.tableExpr()).Error
+	if err != nil {
+		return false, err
+	}
+	res := db.Exec("INSERT INTO ? (id, owner, locked_at) SELECT 1, ?, ? WHERE NOT EXISTS (SELECT 1 FROM ?)",
+		l.tableExpr(), l.owner, time.Now().UTC(), l.tableExpr())
+	return res.RowsAffected == 1, res.Error
+}
+
+func (l *tableLock) unlock(db *gorm.DB) error {
+	return db.Exec("DELETE FROM ? WHERE owner = ?", l.tableExpr(), l.owner).Error
+}
+
+func (l *tableLock) release(db *gorm.DB) error {
+	if !db.Migrator().HasTable(l.table) {
+		return nil
+	}
+	return db.Exec("DELETE FROM ?", l.tableExpr()).Error
+}
+
+func (l *tableLock) tableExpr() clause.Table {
+	return clause.Table{Name: l.table}
+}
@@ -0,0 +1,438 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultTable is the table recording the applied migrations
+const DefaultTable = "schema_migrations"
+
+// Migration States
+const (
+	StatePending  = "pending"
+	StateApplied  = "applied"
+	StateModified = "modified"
+	StateMissing  = "missing"
+)
+
+// Step Directions
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Migrate Errors
+var (
+	ErrModified     = errors.New("applied migration was modified")
+	ErrMissing      = errors.New("applied migration is missing")
+	ErrIrreversible = errors.New("migration has no down script")
+	ErrLocked       = errors.New("migration lock is held by another instance")
+)
+
+// fileName matches the migration files, <version>_<name>.<up|down>.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change and its rollback
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status is the state of a migration in the database
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Step is a migration script to run
+type Step struct {
+	Version   int64  `json:"version"`
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	SQL       string `json:"sql"`
+
+	checksum string
+}
+
+// record is a row of the migrations table
+type record struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Load reads the migrations of a directory sorted by version,
+// the down script of a migration is optional.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s: want <version>_<name>.<up|down>.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s: invalid version", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has two names, %s and %s", version, m.Name, match[2])
+		}
+		script := strings.ReplaceAll(string(data), "\r\n", "\n")
+		if match[3] == DirectionUp {
+			m.Up = script
+		} else {
+			m.Down = script
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		m.Checksum = checksum(m.Up)
+		list = append(list, *m)
+	}
+	slices.SortFunc(list, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return list, nil
+}
+
+// checksum hashes the up script, editing the down script of an applied
+// migration is allowed to fix its rollback.
+func checksum(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// Option configures the Migrator
+type Option func(*Migrator)
+
+// WithTable sets the table recording the applied migrations
+func WithTable(name string) Option {
+	return func(m *Migrator) { m.table = name }
+}
+
+// WithLockTimeout sets how long to wait for the migration lock
+func WithLockTimeout(d time.Duration) Option {
+	return func(m *Migrator) { m.lockTimeout = d }
+}
+
+// WithModels sets the GORM models the drift check compares against
+func WithModels(models ...any) Option {
+	return func(m *Migrator) { m.models = models }
+}
+
+// Migrator applies the versioned migrations, the applied versions and
+// their checksums are recorded in the migrations table. Up and Down hold
+// a lock so that concurrent instances do not apply a migration twice.
+type Migrator struct {
+	db          *gorm.DB
+	migrations  []Migration
+	models      []any
+	table       string
+	lockTimeout time.Duration
+	lock        locker
+}
+
+// New creates a new Migrator of the migrations
+func New(db *gorm.DB, migrations []Migration, opts ...Option) *Migrator {
+	m := &Migrator{
+		db:          db,
+		migrations:  migrations,
+		table:       DefaultTable,
+		lockTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.lock = newLocker(db.Dialector.Name(), m.table)
+	return m
+}
+
+// Migrations returns the known migrations sorted by version
+func (m *Migrator) Migrations() []Migration { return m.migrations }
+
+// Status reports the state of every known and applied migration
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	records, err := m.records(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return m.status(records), nil
+}
+
+// PlanUp returns the steps applying the pending migrations up to the
+// target version, zero targets the latest version.
+func (m *Migrator) PlanUp(ctx context.Context, target int64) ([]Step, error) {
+	records, err := m.records(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return m.planUp(records, target)
+}
+
+// PlanDown returns the steps rolling back the last n applied migrations
+func (m *Migrator) PlanDown(ctx context.Context, n int) ([]Step, error) {
+	records, err := m.records(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return m.planDown(records, n)
+}
+
+// Up applies the pending migrations up to the target version,
+// zero targets the latest version. It returns the applied steps.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Step, error) {
+	return m.run(ctx, func(records []record) ([]Step, error) { return m.planUp(records, target) })
+}
+
+// Down rolls back the last n applied migrations, it returns the
+// rolled back steps.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Step, error) {
+	return m.run(ctx, func(records []record) ([]Step, error) { return m.planDown(records, n) })
+}
+
+// Unlock force releases a migration lock left by a crashed instance,
+// the advisory locks of mysql and postgres are released on disconnect.
+func (m *Migrator) Unlock(ctx context.Context) error {
+	return m.lock.release(m.db.WithContext(ctx))
+}
+
+// run plans and applies the steps on a single connection holding the lock,
+// each step runs in its own transaction with its migrations table row.
+func (m *Migrator) run(ctx context.Context, plan func([]record) ([]Step, error)) ([]Step, error) {
+	var done []Step
+	err := m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := m.ensureTable(conn); err != nil {
+			return err
+		}
+		if err := m.acquire(ctx, conn); err != nil {
+			return err
+		}
+		defer m.lock.unlock(conn)
+
+		// * Plan under the lock, another instance may have migrated meanwhile.
+		records, err := m.records(conn)
+		if err != nil {
+			return err
+		}
+		steps, err := plan(records)
+		if err != nil {
+			return err
+		}
+		for _, step := range steps {
+			if err = m.apply(conn, step); err != nil {
+				return fmt.Errorf("migration %d_%s %s: %w", step.Version, step.Name, step.Direction, err)
+			}
+			done = append(done, step)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// acquire polls the lock until the lock timeout
+func (m *Migrator) acquire(ctx context.Context, conn *gorm.DB) error {
+	deadline := time.Now().Add(m.lockTimeout)
+	for {
+		ok, err := m.lock.tryLock(conn)
+		if err != nil || ok {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w, waited %s", ErrLocked, m.lockTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+}
+
+func (m *Migrator) apply(conn *gorm.DB, step Step) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range Statements(step.SQL) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		if step.Direction == DirectionDown {
+			return tx.Exec("DELETE FROM ? WHERE version = ?", m.tableExpr(), step.Version).Error
+		}
+		return tx.Exec("INSERT INTO ? (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+			m.tableExpr(), step.Version, step.Name, step.checksum, time.Now().UTC()).Error
+	})
+}
+
+func (m *Migrator) planUp(records []record, target int64) ([]Step, error) {
+	if err := m.verify(records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]bool, len(records))
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+	var steps []Step
+	for _, mg := range m.migrations {
+		if target > 0 && mg.Version > target {
+			break
+		}
+		if !applied[mg.Version] {
+			steps = append(steps, Step{
+				Version: mg.Version, Name: mg.Name, Direction: DirectionUp, SQL: mg.Up, checksum: mg.Checksum,
+			})
+		}
+	}
+	return steps, nil
+}
+
+func (m *Migrator) planDown(records []record, n int) ([]Step, error) {
+	if err := m.verify(records); err != nil {
+		return nil, err
+	}
+	var steps []Step
+	for i := len(records) - 1; i >= 0 && len(steps) < n; i-- {
+		mg, _ := m.find(records[i].Version)
+		if strings.TrimSpace(mg.Down) == "" {
+			return nil, fmt.Errorf("%w: %d_%s", ErrIrreversible, mg.Version, mg.Name)
+		}
+		steps = append(steps, Step{Version: mg.Version, Name: mg.Name, Direction: DirectionDown, SQL: mg.Down})
+	}
+	return steps, nil
+}
+
+// verify fails when an applied migration was edited or removed
+func (m *Migrator) verify(records []record) error {
+	for _, r := range records {
+		mg, ok := m.find(r.Version)
+		if !ok {
+			return fmt.Errorf("%w: %d_%s", ErrMissing, r.Version, r.Name)
+		}
+		if mg.Checksum != r.Checksum {
+			return fmt.Errorf("%w: %d_%s checksum %.12s, applied %.12s",
+				ErrModified, mg.Version, mg.Name, mg.Checksum, r.Checksum)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) status(records []record) []Status {
+	applied := make(map[int64]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	list := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := Status{Version: mg.Version, Name: mg.Name, State: StatePending}
+		if r, ok := applied[mg.Version]; ok {
+			s.State, s.AppliedAt = StateApplied, &r.AppliedAt
+			if r.Checksum != mg.Checksum {
+				s.State = StateModified
+			}
+		}
+		list = append(list, s)
+	}
+	for _, r := range records {
+		if _, ok := m.find(r.Version); !ok {
+			list = append(list, Status{Version: r.Version, Name: r.Name, State: StateMissing, AppliedAt: &r.AppliedAt})
+		}
+	}
+	slices.SortFunc(list, func(a, b Status) int { return cmp.Compare(a.Version, b.Version) })
+	return list
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	i, ok := slices.BinarySearchFunc(m.migrations, version, func(mg Migration, v int64) int {
+		return cmp.Compare(mg.Version, v)
+	})
+	if !ok {
+		return Migration{}, false
+	}
+	return m.migrations[i], true
+}
+
+// records reads the applied migrations, none when the table is missing
+func (m *Migrator) records(db *gorm.DB) ([]record, error) {
+	if !db.Migrator().HasTable(m.table) {
+		return nil, nil
+	}
+	var records []record
+	err := db.Raw("SELECT version, name, checksum, applied_at FROM ? ORDER BY version", m.tableExpr()).
+		Scan(&records).Error
+	return records, err
+}
+
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS ? (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`, m.tableExpr()).Error
+}
+
+func (m *Migrator) tableExpr() clause.Table {
+	return clause.Table{Name: m.table}
+}
+
+// Statements splits a script into its statements, a statement ends with
+// a semicolon at the end of a line. Comment lines are dropped.
+func Statements(script string) []string {
+	var list []string
+	var stmt strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		stmt.WriteString(line)
+		stmt.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			list = append(list, strings.TrimSpace(stmt.String()))
+			stmt.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(stmt.String()); rest != "" {
+		list = append(list, rest)
+	}
+	return list
+}
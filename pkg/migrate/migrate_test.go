@@ -0,0 +1,287 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var files = fstest.MapFS{
+	"sql/00001_create_notes.up.sql":   {Data: []byte("-- notes\nCREATE TABLE notes (\n  id integer PRIMARY KEY,\n  body text NOT NULL\n);\n")},
+	"sql/00001_create_notes.down.sql": {Data: []byte("DROP TABLE notes;\n")},
+	"sql/00002_add_title.up.sql":      {Data: []byte("ALTER TABLE notes ADD COLUMN title text;\nCREATE INDEX idx_notes_title ON notes (title);\n")},
+	"sql/00002_add_title.down.sql":    {Data: []byte("DROP INDEX idx_notes_title;\nALTER TABLE notes DROP COLUMN title;\n")},
+	"sql/00003_seed.up.sql":           {Data: []byte("INSERT INTO notes (body) VALUES ('a;b');\n")},
+	"sql/README.md":                   {Data: []byte("ignored")},
+}
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite error = %v", err)
+	}
+	return db
+}
+
+func load(t *testing.T, fsys fstest.MapFS) []Migration {
+	t.Helper()
+	list, err := Load(fsys, "sql")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return list
+}
+
+func states(t *testing.T, m *Migrator) []string {
+	t.Helper()
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	var list []string
+	for _, s := range status {
+		list = append(list, s.State)
+	}
+	return list
+}
+
+func versions(steps []Step) []int64 {
+	var list []int64
+	for _, s := range steps {
+		list = append(list, s.Version)
+	}
+	return list
+}
+
+func TestLoad(t *testing.T) {
+	list := load(t, files)
+	if got := len(list); got != 3 {
+		t.Fatalf("Load() = %d migrations, want 3", got)
+	}
+	if m := list[1]; m.Version != 2 || m.Name != "add_title" || m.Down == "" || len(m.Checksum) != 64 {
+		t.Errorf("Load() second migration = %+v", m)
+	}
+	if list[2].Down != "" {
+		t.Errorf("Load() seed down = %q, want none", list[2].Down)
+	}
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"bad name", fstest.MapFS{"sql/1-notes.up.sql": {Data: []byte("SELECT 1;")}}, "want <version>"},
+		{"two names", fstest.MapFS{
+			"sql/1_a.up.sql": {Data: []byte("SELECT 1;")},
+			"sql/1_b.up.sql": {Data: []byte("SELECT 1;")},
+		}, "two names"},
+		{"no up", fstest.MapFS{"sql/1_a.down.sql": {Data: []byte("SELECT 1;")}}, "no up script"},
+		{"zero version", fstest.MapFS{"sql/0_a.up.sql": {Data: []byte("SELECT 1;")}}, "invalid version"},
+	}
+	for _, tt := range tests {
+		if _, err := Load(tt.files, "sql"); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load() %s error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := "-- comment\nCREATE TABLE a (\n  id int\n);\n\nINSERT INTO a VALUES (1); \nSELECT 1"
+	want := []string{"CREATE TABLE a (\n  id int\n);", "INSERT INTO a VALUES (1);", "SELECT 1"}
+	if got := Statements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statements() = %q, want %q", got, want)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m := New(db, load(t, files))
+
+	if got := states(t, m); !reflect.DeepEqual(got, []string{StatePending, StatePending, StatePending}) {
+		t.Errorf("states before = %v", got)
+	}
+
+	plan, err := m.PlanUp(ctx, 2)
+	if err != nil || !reflect.DeepEqual(versions(plan), []int64{1, 2}) {
+		t.Fatalf("PlanUp(2) = %v, %v, want 1 and 2", versions(plan), err)
+	}
+	if db.Migrator().HasTable("notes") {
+		t.Errorf("PlanUp() created the table, want a dry run")
+	}
+
+	steps, err := m.Up(ctx, 2)
+	if err != nil || !reflect.DeepEqual(versions(steps), []int64{1, 2}) {
+		t.Fatalf("Up(2) = %v, %v", versions(steps), err)
+	}
+	if steps, err = m.Up(ctx, 0); err != nil || !reflect.DeepEqual(versions(steps), []int64{3}) {
+		t.Fatalf("Up(0) = %v, %v, want 3", versions(steps), err)
+	}
+	if steps, err = m.Up(ctx, 0); err != nil || len(steps) != 0 {
+		t.Fatalf("Up(0) again = %v, %v, want nothing", versions(steps), err)
+	}
+	if got := states(t, m); !reflect.DeepEqual(got, []string{StateApplied, StateApplied, StateApplied}) {
+		t.Errorf("states after up = %v", got)
+	}
+
+	if _, err = m.Down(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("Down() of the seed error = %v, want ErrIrreversible", err)
+	}
+
+	// * The seed script is edited after it was applied.
+	edited := fstest.MapFS{}
+	for name, file := range files {
+		edited[name] = file
+	}
+	edited["sql/00003_seed.up.sql"] = &fstest.MapFile{Data: []byte("INSERT INTO notes (body) VALUES ('c');\n")}
+	em := New(db, load(t, edited))
+	if got := states(t, em); got[2] != StateModified {
+		t.Errorf("state of the edited seed = %s, want modified", got[2])
+	}
+	if _, err = em.Up(ctx, 0); !errors.Is(err, ErrModified) {
+		t.Errorf("Up() of an edited migration error = %v, want ErrModified", err)
+	}
+
+	// * The seed is rolled back by hand and dropped from the files.
+	delete(edited, "sql/00003_seed.up.sql")
+	mm := New(db, load(t, edited))
+	if got := states(t, mm); got[2] != StateMissing {
+		t.Errorf("state of the removed seed = %s, want missing", got[2])
+	}
+	if _, err = mm.PlanDown(ctx, 1); !errors.Is(err, ErrMissing) {
+		t.Errorf("PlanDown() with a missing migration error = %v, want ErrMissing", err)
+	}
+	if err = db.Exec("DELETE FROM schema_migrations WHERE version = 3").Error; err != nil {
+		t.Fatalf("delete error = %v", err)
+	}
+
+	if steps, err = mm.Down(ctx, 2); err != nil || !reflect.DeepEqual(versions(steps), []int64{2, 1}) {
+		t.Fatalf("Down(2) = %v, %v, want 2 then 1", versions(steps), err)
+	}
+	if db.Migrator().HasTable("notes") {
+		t.Errorf("notes table exists after down")
+	}
+	if got := states(t, mm); !reflect.DeepEqual(got, []string{StatePending, StatePending}) {
+		t.Errorf("states after down = %v", got)
+	}
+}
+
+func TestMigrator_FailedStep(t *testing.T) {
+	broken := fstest.MapFS{
+		"sql/1_a.up.sql": {Data: []byte("CREATE TABLE a (id int);\n")},
+		"sql/2_b.up.sql": {Data: []byte("CREATE TABLE b (id int);\nINSERT INTO nope VALUES (1);\n")},
+	}
+	db := openDB(t)
+	m := New(db, load(t, broken))
+	steps, err := m.Up(context.Background(), 0)
+	if err == nil || !strings.Contains(err.Error(), "2_b up") {
+		t.Fatalf("Up() error = %v, want the failed migration named", err)
+	}
+	if !reflect.DeepEqual(versions(steps), []int64{1}) {
+		t.Errorf("Up() applied %v, want 1", versions(steps))
+	}
+	if db.Migrator().HasTable("b") {
+		t.Errorf("table of the failed migration exists, want it rolled back")
+	}
+	if got := states(t, m); !reflect.DeepEqual(got, []string{StateApplied, StatePending}) {
+		t.Errorf("states = %v", got)
+	}
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	db := openDB(t)
+	list := load(t, files)
+
+	var wg sync.WaitGroup
+	applied := make([][]int64, 4)
+	errs := make([]error, 4)
+	for i := range applied {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			steps, err := New(db, list).Up(context.Background(), 0)
+			applied[i], errs[i] = versions(steps), err
+		}()
+	}
+	wg.Wait()
+
+	var total int
+	for i := range applied {
+		if errs[i] != nil {
+			t.Errorf("concurrent Up() error = %v", errs[i])
+		}
+		total += len(applied[i])
+	}
+	if total != 3 {
+		t.Errorf("concurrent Up() applied %v, want each migration once", applied)
+	}
+
+	// * A lock left by a crashed instance.
+	held := New(db, list, WithLockTimeout(time.Millisecond*200))
+	if ok, err := held.lock.tryLock(db); !ok || err != nil {
+		t.Fatalf("tryLock() = %v, %v", ok, err)
+	}
+	other := New(db, list, WithLockTimeout(time.Millisecond*200))
+	if _, err := other.Up(context.Background(), 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("Up() of a held lock error = %v, want ErrLocked", err)
+	}
+	if err := other.Unlock(context.Background()); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if _, err := other.Up(context.Background(), 0); err != nil {
+		t.Errorf("Up() after Unlock() error = %v", err)
+	}
+}
+
+type note struct {
+	ID    int32  `gorm:"primaryKey"`
+	Body  string `gorm:"not null;unique"`
+	Title string `gorm:"index:idx_notes_title"`
+	Pages int
+}
+
+func TestMigrator_Drift(t *testing.T) {
+	db := openDB(t)
+	m := New(db, load(t, files), WithModels(&note{}))
+	ctx := context.Background()
+
+	drift, err := m.Drift(ctx)
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	if want := []Drift{{Table: "notes", Kind: DriftMissingTable}}; !reflect.DeepEqual(drift, want) {
+		t.Errorf("Drift() before = %+v, want %+v", drift, want)
+	}
+
+	if _, err = m.Up(ctx, 1); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if err = db.Exec("ALTER TABLE notes ADD COLUMN legacy text").Error; err != nil {
+		t.Fatalf("alter error = %v", err)
+	}
+	if drift, err = m.Drift(ctx); err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	want := []Drift{
+		{Table: "notes", Kind: DriftUnique, Name: "body", Detail: "model unique, column not"},
+		{Table: "notes", Kind: DriftMissingColumn, Name: "title"},
+		{Table: "notes", Kind: DriftMissingColumn, Name: "pages"},
+		{Table: "notes", Kind: DriftExtraColumn, Name: "legacy"},
+		{Table: "notes", Kind: DriftMissingIndex, Name: "idx_notes_title"},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("Drift() = %+v, want %+v", drift, want)
+	}
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Locker grants a named lock to one owner at a time, the lock expires
+// after its ttl unless the owner acquires it again.
+type Locker interface {
+	// Acquire takes or extends the lock of the key for the ttl, it
+	// reports false while another owner holds the lock.
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Release releases the lock of the key when the owner holds it
+	Release(ctx context.Context, key, owner string) error
+}
+
+// MemoryLocker is a Locker of a single process
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+	now   func() time.Time
+}
+
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemoryLocker creates a MemoryLocker
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]memoryLock), now: time.Now}
+}
+
+// Acquire takes or extends the lock of the key for the ttl
+func (l *MemoryLocker) Acquire(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if lock, ok := l.locks[key]; ok && lock.owner != owner && now.Before(lock.expires) {
+		return false, nil
+	}
+	l.locks[key] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release releases the lock of the key when the owner holds it
+func (l *MemoryLocker) Release(_ context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock, ok := l.locks[key]; ok && lock.owner == owner {
+		delete(l.locks, key)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/trove-fiber/pkg/version"
+)
+
+// Metrics counts the runs of the tasks and tracks the leadership
+type Metrics struct {
+	runs   *prometheus.CounterVec
+	leader prometheus.Gauge
+}
+
+// NewMetrics creates the Metrics registered on the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{}
+	m.runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "scheduler",
+		Name:      "runs_total",
+		Help:      "How many times the periodic tasks ran, with labels task and result, succeeded, failed or skipped.",
+	}, []string{"task", "result"})
+	m.leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: version.Namespace(),
+		Subsystem: "scheduler",
+		Name:      "leader",
+		Help:      "Whether the instance holds the leader lock and runs the periodic tasks.",
+	})
+	reg.MustRegister(m.runs, m.leader)
+	return m
+}
+
+func (m *Metrics) observe(task, result string) {
+	if m != nil {
+		m.runs.WithLabelValues(task, result).Inc()
+	}
+}
+
+func (m *Metrics) lead(leader bool) {
+	if m != nil {
+		v := 0.0
+		if leader {
+			v = 1
+		}
+		m.leader.Set(v)
+	}
+}
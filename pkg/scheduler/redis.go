@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// extendScript extends the lock of KEYS[1] held by ARGV[1] by ARGV[2] ms
+	extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	// releaseScript deletes the lock of KEYS[1] held by ARGV[1]
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// RedisLocker is a Locker on a Redis server shared by the instances, a
+// lock is a key holding its owner which expires with the lock.
+type RedisLocker struct {
+	client redis.UniversalClient
+}
+
+// NewRedisLocker creates a RedisLocker on the client
+func NewRedisLocker(client redis.UniversalClient) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+// Acquire takes or extends the lock of the key for the ttl
+func (l *RedisLocker) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	ok, err := l.client.SetNX(ctx, key, owner, ttl).Result()
+	if err != nil || ok {
+		return ok, err
+	}
+	n, err := extendScript.Run(ctx, l.client, []string{key}, owner, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Release releases the lock of the key when the owner holds it
+func (l *RedisLocker) Release(ctx context.Context, key, owner string) error {
+	return releaseScript.Run(ctx, l.client, []string{key}, owner).Err()
+}
@@ -0,0 +1,435 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// LeaderKey is the lock of the leader, the instance holding it runs the tasks
+const LeaderKey = "scheduler:leader"
+
+// Overlap Policies
+const (
+	// OverlapSkip skips a run due while the previous one is running
+	OverlapSkip = "skip"
+	// OverlapQueue runs it once the previous one finished, the runs due
+	// meanwhile are skipped.
+	OverlapQueue = "queue"
+)
+
+// Run Results
+const (
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+	ResultSkipped   = "skipped"
+)
+
+// Scheduler Errors
+var (
+	ErrInvalidTask   = errors.New("invalid task")
+	ErrDuplicateTask = errors.New("task already added")
+)
+
+// parser parses the standard five field cron expressions and the
+// descriptors such as @daily or @every 1h.
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseSpec parses the cron expression of a task
+func ParseSpec(spec string) (cron.Schedule, error) {
+	return parser.Parse(spec)
+}
+
+// Task is a periodic task
+type Task struct {
+	Name string
+	// Spec is the cron expression of the runs
+	Spec string
+	// Jitter delays every run by a random duration up to it, it spreads
+	// the runs of the tasks due at the same time.
+	Jitter time.Duration
+	// Overlap is the policy of a run due while the previous one is
+	// running, OverlapSkip when empty.
+	Overlap string
+	// Timeout cancels a run past it, zero for none
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// Run is a run of a task in the history
+type Run struct {
+	Task   string    `json:"task"`
+	Result string    `json:"result"`
+	Owner  string    `json:"owner"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// TaskStatus is the schedule of a task
+type TaskStatus struct {
+	Name    string    `json:"name"`
+	Spec    string    `json:"spec"`
+	Overlap string    `json:"overlap"`
+	NextRun time.Time `json:"next_run"`
+	Running bool      `json:"running"`
+	Queued  bool      `json:"queued"`
+	LastRun *Run      `json:"last_run,omitempty"`
+}
+
+// Status is the state of the scheduler
+type Status struct {
+	Owner  string       `json:"owner"`
+	Leader bool         `json:"leader"`
+	Tasks  []TaskStatus `json:"tasks"`
+}
+
+// Option configures a Scheduler
+type Option func(*options)
+
+type options struct {
+	locker    Locker
+	leaderTTL time.Duration
+	owner     string
+	history   int
+	metrics   *Metrics
+}
+
+// WithLocker elects the leader through the locker, the leader renews its
+// lock every third of the ttl. Without a locker the instance always leads.
+func WithLocker(locker Locker, ttl time.Duration) Option {
+	return func(o *options) { o.locker, o.leaderTTL = locker, ttl }
+}
+
+// WithOwner sets the owner of the leader lock, the host and pid by default
+func WithOwner(owner string) Option {
+	return func(o *options) { o.owner = owner }
+}
+
+// WithHistory sets how many runs the history keeps
+func WithHistory(n int) Option {
+	return func(o *options) { o.history = n }
+}
+
+// WithMetrics counts the runs and tracks the leadership
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// task is a Task and the state of its schedule
+type task struct {
+	Task
+	schedule cron.Schedule
+
+	mu      sync.Mutex
+	next    time.Time
+	running bool
+	queued  bool
+	last    *Run
+}
+
+// Scheduler runs the periodic tasks on their cron schedules. With several
+// instances only the leader runs them, a leader lost during a run lets it
+// finish. It is the server.Server of the schedules, Stop waits for the
+// running tasks.
+type Scheduler struct {
+	opts   options
+	now    func() time.Time
+	tasks  []*task
+	leader atomic.Bool
+
+	mu      sync.Mutex
+	history []Run
+
+	runs    sync.WaitGroup
+	started atomic.Bool
+	stop    chan struct{}
+	stopped sync.Once
+	done    chan struct{}
+}
+
+// New creates a Scheduler
+func New(opts ...Option) *Scheduler {
+	host, _ := os.Hostname()
+	o := options{
+		leaderTTL: time.Second * 30,
+		owner:     fmt.Sprintf("%s-%d", host, os.Getpid()),
+		history:   100,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Scheduler{
+		opts: o,
+		now:  time.Now,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Add adds the task, the tasks added after Start are never scheduled
+func (s *Scheduler) Add(t Task) error {
+	if t.Name == "" || t.Run == nil {
+		return fmt.Errorf("%w: name and run are required", ErrInvalidTask)
+	}
+	if t.Overlap == "" {
+		t.Overlap = OverlapSkip
+	}
+	if t.Overlap != OverlapSkip && t.Overlap != OverlapQueue {
+		return fmt.Errorf("%w: task %s overlap %q is not skip or queue", ErrInvalidTask, t.Name, t.Overlap)
+	}
+	schedule, err := ParseSpec(t.Spec)
+	if err != nil {
+		return fmt.Errorf("%w: task %s spec: %w", ErrInvalidTask, t.Name, err)
+	}
+	if slices.ContainsFunc(s.tasks, func(added *task) bool { return added.Name == t.Name }) {
+		return fmt.Errorf("%w: %s", ErrDuplicateTask, t.Name)
+	}
+	s.tasks = append(s.tasks, &task{Task: t, schedule: schedule})
+	return nil
+}
+
+// Leader reports whether the instance runs the tasks
+func (s *Scheduler) Leader() bool {
+	return s.leader.Load()
+}
+
+// Status returns the schedules of the tasks
+func (s *Scheduler) Status() Status {
+	status := Status{Owner: s.opts.owner, Leader: s.Leader(), Tasks: make([]TaskStatus, len(s.tasks))}
+	for i, t := range s.tasks {
+		t.mu.Lock()
+		status.Tasks[i] = TaskStatus{
+			Name:    t.Name,
+			Spec:    t.Spec,
+			Overlap: t.Overlap,
+			NextRun: t.next,
+			Running: t.running,
+			Queued:  t.queued,
+			LastRun: t.last,
+		}
+		t.mu.Unlock()
+	}
+	return status
+}
+
+// History returns the latest runs first, of the task unless empty
+func (s *Scheduler) History(name string) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]Run, 0, len(s.history))
+	for _, run := range slices.Backward(s.history) {
+		if name == "" || run.Task == name {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// Start schedules the tasks until Stop
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.started.Store(true)
+	defer close(s.done)
+
+	var wg sync.WaitGroup
+	if s.opts.locker == nil {
+		s.setLeader(true)
+	} else {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.elect(ctx)
+		}()
+	}
+	for _, t := range s.tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.schedule(ctx, t)
+		}()
+	}
+	wg.Wait()
+	s.runs.Wait()
+
+	// * The lock is released once the running tasks finished.
+	if s.opts.locker != nil && s.Leader() {
+		if err := s.opts.locker.Release(context.WithoutCancel(ctx), LeaderKey, s.opts.owner); err != nil {
+			slog.Warn("release scheduler leadership failed", "owner", s.opts.owner, "error", err)
+		}
+		s.setLeader(false)
+	}
+	return nil
+}
+
+// Stop stops the schedules and waits for the running tasks
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.stopped.Do(func() { close(s.stop) })
+	if !s.started.Load() {
+		return nil
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// elect campaigns for the leader lock every third of its ttl until Stop,
+// a failed campaign steps down.
+func (s *Scheduler) elect(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.leaderTTL / 3)
+	defer ticker.Stop()
+	for {
+		s.campaign(ctx)
+		select {
+		case <-s.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) campaign(ctx context.Context) {
+	leader, err := s.opts.locker.Acquire(ctx, LeaderKey, s.opts.owner, s.opts.leaderTTL)
+	if err != nil {
+		slog.Warn("scheduler election failed", "owner", s.opts.owner, "error", err)
+		leader = false
+	}
+	if s.Leader() != leader {
+		slog.Info("scheduler leadership changed", "owner", s.opts.owner, "leader", leader)
+	}
+	s.setLeader(leader)
+}
+
+func (s *Scheduler) setLeader(leader bool) {
+	s.leader.Store(leader)
+	s.opts.metrics.lead(leader)
+}
+
+// schedule waits for the next run of the task until Stop, only the
+// leader runs it.
+func (s *Scheduler) schedule(ctx context.Context, t *task) {
+	for {
+		next := t.schedule.Next(s.now())
+		if t.Jitter > 0 {
+			next = next.Add(rand.N(t.Jitter))
+		}
+		t.mu.Lock()
+		t.next = next
+		t.mu.Unlock()
+
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if s.Leader() {
+			s.dispatch(ctx, t)
+		}
+	}
+}
+
+// dispatch runs the due task unless its previous run is still running,
+// then the overlap policy skips or queues the run.
+func (s *Scheduler) dispatch(ctx context.Context, t *task) {
+	t.mu.Lock()
+	if t.running {
+		queue := t.Overlap == OverlapQueue && !t.queued
+		t.queued = t.queued || queue
+		t.mu.Unlock()
+		if !queue {
+			now := s.now()
+			s.record(t, Run{Task: t.Name, Result: ResultSkipped, Owner: s.opts.owner, Start: now, End: now,
+				Error: "previous run still running"})
+		}
+		return
+	}
+	t.running = true
+	t.mu.Unlock()
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		for {
+			s.run(ctx, t)
+			t.mu.Lock()
+			again := t.queued && !s.stopping()
+			t.running, t.queued = again, false
+			t.mu.Unlock()
+			if !again {
+				return
+			}
+		}
+	}()
+}
+
+// run runs the task within its timeout and records the run
+func (s *Scheduler) run(ctx context.Context, t *task) {
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	run := Run{Task: t.Name, Result: ResultSucceeded, Owner: s.opts.owner, Start: s.now()}
+	err := call(ctx, t)
+	run.End = s.now()
+	if err != nil {
+		run.Result, run.Error = ResultFailed, err.Error()
+		slog.Error("task failed", "task", t.Name, "took", run.End.Sub(run.Start), "error", err)
+	} else {
+		slog.Info("task succeeded", "task", t.Name, "took", run.End.Sub(run.Start))
+	}
+	s.record(t, run)
+}
+
+// call runs the task, a panic fails the run
+func call(ctx context.Context, t *task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task %s panicked: %v", t.Name, r)
+		}
+	}()
+	return t.Run(ctx)
+}
+
+// record adds the run to the history, the oldest runs past its size are
+// dropped.
+func (s *Scheduler) record(t *task, run Run) {
+	t.mu.Lock()
+	t.last = &run
+	t.mu.Unlock()
+
+	s.mu.Lock()
+	s.history = append(s.history, run)
+	if n := len(s.history) - s.opts.history; n > 0 {
+		s.history = slices.Delete(s.history, 0, n)
+	}
+	s.mu.Unlock()
+	s.opts.metrics.observe(t.Name, run.Result)
+}
+
+func (s *Scheduler) stopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
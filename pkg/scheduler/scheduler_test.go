@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+var errTask = errors.New("task failed")
+
+func noop(context.Context) error { return nil }
+
+func TestScheduler_Add(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want error
+	}{
+		{"daily", Task{Name: "purge", Spec: "0 3 * * *", Run: noop}, nil},
+		{"descriptor", Task{Name: "rotate", Spec: "@every 1h", Overlap: OverlapQueue, Run: noop}, nil},
+		{"duplicate", Task{Name: "purge", Spec: "@hourly", Run: noop}, ErrDuplicateTask},
+		{"no name", Task{Spec: "@hourly", Run: noop}, ErrInvalidTask},
+		{"no run", Task{Name: "expire", Spec: "@hourly"}, ErrInvalidTask},
+		{"bad spec", Task{Name: "expire", Spec: "0 3 * *", Run: noop}, ErrInvalidTask},
+		{"seconds", Task{Name: "expire", Spec: "0 0 3 * * *", Run: noop}, ErrInvalidTask},
+		{"bad overlap", Task{Name: "expire", Spec: "@hourly", Overlap: "wait", Run: noop}, ErrInvalidTask},
+	}
+	s := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Add(tt.task); !errors.Is(err, tt.want) {
+				t.Errorf("Add() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if status := s.Status(); len(status.Tasks) != 2 || status.Tasks[0].Overlap != OverlapSkip {
+		t.Errorf("Status() = %+v, want purge skipping and rotate", status)
+	}
+}
+
+// blocking adds the task of the overlap, its runs wait for the release
+func blocking(t *testing.T, s *Scheduler, overlap string) (*task, chan struct{}, chan struct{}) {
+	t.Helper()
+	started, release := make(chan struct{}, 4), make(chan struct{})
+	err := s.Add(Task{Name: overlap, Spec: "@hourly", Overlap: overlap, Run: func(context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	return s.tasks[len(s.tasks)-1], started, release
+}
+
+func results(runs []Run) []string {
+	var list []string
+	for _, run := range runs {
+		list = append(list, run.Result)
+	}
+	return list
+}
+
+func TestScheduler_Overlap(t *testing.T) {
+	tests := []struct {
+		overlap string
+		runs    int
+		want    []string
+	}{
+		{OverlapSkip, 1, []string{ResultSucceeded, ResultSkipped, ResultSkipped}},
+		{OverlapQueue, 2, []string{ResultSucceeded, ResultSucceeded, ResultSkipped}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.overlap, func(t *testing.T) {
+			s := New()
+			task, started, release := blocking(t, s, tt.overlap)
+			ctx := context.Background()
+
+			// * Three runs are due while the first one is running.
+			s.dispatch(ctx, task)
+			<-started
+			s.dispatch(ctx, task)
+			s.dispatch(ctx, task)
+			close(release)
+			s.runs.Wait()
+
+			if len(started) != tt.runs-1 {
+				t.Errorf("runs = %d, want %d", len(started)+1, tt.runs)
+			}
+			if got := results(s.History(tt.overlap)); !slices.Equal(got, tt.want) {
+				t.Errorf("History() = %v, want %v", got, tt.want)
+			}
+			if status := s.Status().Tasks[0]; status.Running || status.Queued || status.LastRun == nil {
+				t.Errorf("Status() = %+v, want idle after its last run", status)
+			}
+		})
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s := New(WithHistory(2), WithMetrics(NewMetrics(reg)))
+	_ = s.Add(Task{Name: "fail", Spec: "@hourly", Run: func(context.Context) error { return errTask }})
+	_ = s.Add(Task{Name: "panic", Spec: "@hourly", Run: func(context.Context) error { panic("boom") }})
+	_ = s.Add(Task{Name: "slow", Spec: "@hourly", Timeout: time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	for _, task := range s.tasks {
+		s.run(context.Background(), task)
+	}
+
+	// * The history keeps the latest two runs.
+	runs := s.History("")
+	if len(runs) != 2 || runs[0].Task != "slow" || runs[1].Task != "panic" {
+		t.Fatalf("History() = %+v, want the slow then the panic run", runs)
+	}
+	if runs[0].Error != context.DeadlineExceeded.Error() || runs[1].Result != ResultFailed {
+		t.Errorf("History() = %+v, want both failed", runs)
+	}
+	if n := testutil.ToFloat64(s.opts.metrics.runs.WithLabelValues("fail", ResultFailed)); n != 1 {
+		t.Errorf("failed runs = %v, want 1", n)
+	}
+}
+
+func TestScheduler_Election(t *testing.T) {
+	locker := NewMemoryLocker()
+	ctx := context.Background()
+	a := New(WithLocker(locker, time.Minute), WithOwner("a"))
+	b := New(WithLocker(locker, time.Minute), WithOwner("b"))
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if !a.Leader() || b.Leader() {
+		t.Fatalf("leaders a = %v, b = %v, want a only", a.Leader(), b.Leader())
+	}
+
+	// * The stopped leader releases the lock, the next campaign elects b.
+	done := make(chan struct{})
+	go func() {
+		_ = a.Start(ctx)
+		close(done)
+	}()
+	if err := a.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	<-done
+	if b.campaign(ctx); a.Leader() || !b.Leader() {
+		t.Errorf("leaders after the stop a = %v, b = %v, want b only", a.Leader(), b.Leader())
+	}
+}
+
+func TestScheduler_StartStop(t *testing.T) {
+	s := New()
+	ran := make(chan struct{}, 1)
+	_ = s.Add(Task{Name: "tick", Spec: "@every 1s", Run: func(context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return nil
+	}})
+	ctx := context.Background()
+	go func() { _ = s.Start(ctx) }()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second * 5):
+		t.Fatal("task not run on its schedule")
+	}
+	if next := s.Status().Tasks[0].NextRun; !next.After(time.Now().Add(-time.Second)) {
+		t.Errorf("NextRun = %v, want the next second", next)
+	}
+	if err := s.Stop(ctx); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestLockers(t *testing.T) {
+	memory := NewMemoryLocker()
+	server := miniredis.RunT(t)
+	lockers := []struct {
+		name   string
+		locker Locker
+		expire func(d time.Duration)
+	}{
+		{"memory", memory, func(d time.Duration) {
+			now := time.Now().Add(d)
+			memory.now = func() time.Time { return now }
+		}},
+		{"redis", NewRedisLocker(redis.NewClient(&redis.Options{Addr: server.Addr()})), server.FastForward},
+	}
+	for _, tt := range lockers {
+		t.Run(tt.name, func(t *testing.T) {
+			l, ctx := tt.locker, context.Background()
+			acquire := func(owner string, want bool) {
+				t.Helper()
+				if ok, err := l.Acquire(ctx, "leader", owner, time.Minute); err != nil || ok != want {
+					t.Errorf("Acquire(%s) = %v, %v, want %v", owner, ok, err, want)
+				}
+			}
+			acquire("a", true)
+			acquire("b", false)
+			acquire("a", true)
+			_ = l.Release(ctx, "leader", "b")
+			acquire("b", false)
+			_ = l.Release(ctx, "leader", "a")
+			acquire("b", true)
+
+			// * An expired lock is taken over.
+			tt.expire(time.Minute * 2)
+			acquire("a", true)
+		})
+	}
+}
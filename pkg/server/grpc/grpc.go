@@ -3,11 +3,12 @@ package grpc
 import (
 	"context"
 	"fmt"
-	"log/slog"
 	"net"
 	"time"
 
 	"google.golang.org/grpc"
+
+	"github.com/kelein/trove-fiber/pkg/log"
 )
 
 // Server stands for a gRPC server implementation
@@ -48,10 +49,10 @@ func (s *Server) Start(ctx context.Context) error {
 	addr := fmt.Sprintf("%s:%d", s.host, s.port)
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
-		slog.Error("Failed to listen on", "addr", addr, "error", err)
+		log.Named(log.ModuleGRPC).Error("Failed to listen on", "addr", addr, "error", err)
 	}
 	if err = s.Server.Serve(lis); err != nil {
-		slog.Error("Failed to serve", "addr", addr, "error", err)
+		log.Named(log.ModuleGRPC).Error("Failed to serve", "addr", addr, "error", err)
 	}
 	return nil
 }
@@ -0,0 +1,210 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// header identifies the vault files and their format version
+const header = "TROVE-VAULT/1"
+
+// Key derivation parameters of scrypt
+const (
+	saltSize = 16
+	keySize  = 32
+	scryptN  = 1 << 15
+	scryptR  = 8
+	scryptP  = 1
+)
+
+// Vault Errors
+var (
+	ErrNoPassphrase = errors.New("vault passphrase is empty")
+	ErrDecrypt      = errors.New("vault decryption failed, wrong passphrase or corrupted file")
+	ErrFormat       = errors.New("not a vault file")
+)
+
+// Vault is a local secrets file encrypted with AES-256-GCM,
+// the key is derived from a passphrase with scrypt.
+type Vault struct {
+	path       string
+	passphrase []byte
+	secrets    map[string]string
+}
+
+// Open decrypts the vault file, a missing file opens an empty vault
+// which is created on Save.
+func Open(path string, passphrase []byte) (*Vault, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrNoPassphrase
+	}
+	v := &Vault{path: path, passphrase: passphrase, secrets: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := decrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(plain, &v.secrets); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	return v, nil
+}
+
+// Path returns the path of the vault file
+func (v *Vault) Path() string { return v.path }
+
+// Get returns the secret of the key
+func (v *Vault) Get(key string) (string, bool) {
+	secret, ok := v.secrets[key]
+	return secret, ok
+}
+
+// Set sets the secret of the key, it is written on Save
+func (v *Vault) Set(key, secret string) {
+	v.secrets[key] = secret
+}
+
+// Delete removes the secret of the key, it is written on Save
+func (v *Vault) Delete(key string) {
+	delete(v.secrets, key)
+}
+
+// Keys returns the sorted keys of all secrets
+func (v *Vault) Keys() []string {
+	keys := make([]string, 0, len(v.secrets))
+	for k := range v.secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// All returns a copy of all secrets
+func (v *Vault) All() map[string]string {
+	all := make(map[string]string, len(v.secrets))
+	for k, s := range v.secrets {
+		all[k] = s
+	}
+	return all
+}
+
+// Replace replaces all secrets, it is written on Save
+func (v *Vault) Replace(secrets map[string]string) {
+	v.secrets = make(map[string]string, len(secrets))
+	for k, s := range secrets {
+		v.secrets[k] = s
+	}
+}
+
+// Save encrypts the secrets with a fresh salt and nonce and replaces
+// the vault file atomically.
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	data, err := encrypt(plain, v.passphrase)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), ".vault-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), v.path)
+}
+
+func newAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt returns the vault file of the plaintext, three lines of the
+// header, the base64 salt and the base64 nonce followed by ciphertext.
+func encrypt(plain, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plain, []byte(header))
+
+	var buf bytes.Buffer
+	buf.WriteString(header + "\n")
+	buf.WriteString(base64.StdEncoding.EncodeToString(salt) + "\n")
+	buf.WriteString(base64.StdEncoding.EncodeToString(sealed) + "\n")
+	return buf.Bytes(), nil
+}
+
+func decrypt(data, passphrase []byte) ([]byte, error) {
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 3 || string(lines[0]) != header {
+		return nil, ErrFormat
+	}
+	salt, err := base64.StdEncoding.DecodeString(string(lines[1]))
+	if err != nil {
+		return nil, ErrFormat
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(lines[2]))
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrFormat
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(header))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
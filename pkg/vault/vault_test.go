@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVault_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	v, err := Open(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("Open() missing file error = %v", err)
+	}
+	v.Set("db/password", "s3cr3t")
+	v.Set("jwt/key", "k")
+	if err = v.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read vault error = %v", err)
+	}
+	if strings.Contains(string(data), "s3cr3t") || !strings.HasPrefix(string(data), header) {
+		t.Errorf("vault file %q is not encrypted", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("vault file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	reopened, err := Open(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got, ok := reopened.Get("db/password"); !ok || got != "s3cr3t" {
+		t.Errorf("Get() = %q, %v, want s3cr3t", got, ok)
+	}
+	if keys := reopened.Keys(); strings.Join(keys, ",") != "db/password,jwt/key" {
+		t.Errorf("Keys() = %v", keys)
+	}
+}
+
+func TestVault_OpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.vault")
+	v, _ := Open(path, []byte("passphrase"))
+	v.Set("k", "v")
+	if err := v.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	tampered := filepath.Join(dir, "tampered.vault")
+	lines := strings.Split(string(data), "\n")
+	lines[2] = "A" + lines[2][1:]
+	_ = os.WriteFile(tampered, []byte(strings.Join(lines, "\n")), 0o600)
+	plain := filepath.Join(dir, "plain.vault")
+	_ = os.WriteFile(plain, []byte("k: v\n"), 0o600)
+
+	tests := []struct {
+		name       string
+		path       string
+		passphrase string
+		want       error
+	}{
+		{"wrong passphrase", path, "wrong", ErrDecrypt},
+		{"tampered file", tampered, "passphrase", ErrDecrypt},
+		{"plain file", plain, "passphrase", ErrFormat},
+		{"empty passphrase", path, "", ErrNoPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(tt.path, []byte(tt.passphrase)); !errors.Is(err, tt.want) {
+				t.Errorf("Open() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -54,9 +54,9 @@ func Info() map[string]string {
 	}
 }
 
-// String returns version information string.
-func String() string {
-	m := map[string]string{
+// Build returns all build information
+func Build() map[string]string {
+	return map[string]string{
 		"program":   AppName,
 		"version":   AppVersion,
 		"revision":  Revision,
@@ -66,15 +66,24 @@ func String() string {
 		"goVersion": GoVersion,
 		"platform":  Platform,
 	}
+}
+
+// String returns version information string.
+func String() string {
 	t := template.Must(template.New("version").Parse(versionInfoTmpl))
 
 	var buf bytes.Buffer
-	if err := t.ExecuteTemplate(&buf, "version", m); err != nil {
+	if err := t.ExecuteTemplate(&buf, "version", Build()); err != nil {
 		panic(err)
 	}
 	return strings.TrimSpace(buf.String())
 }
 
+// Namespace returns the AppName in a form usable as metric namespace
+func Namespace() string {
+	return strings.ReplaceAll(AppName, "-", "_")
+}
+
 // NewCollector exports metrics about program build info
 func NewCollector(app string) prometheus.Collector {
 	name := strings.Replace(app, "-", "_", -1)